@@ -0,0 +1,352 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hilsonxhero/napoleon/cache"
+	"github.com/hilsonxhero/napoleon/session"
+)
+
+var (
+	// ErrInvalidCredentials is returned by Attempt when the email or password is wrong.
+	ErrInvalidCredentials = errors.New("auth: invalid credentials")
+	// ErrInactive is returned by Attempt for users that have been disabled.
+	ErrInactive = errors.New("auth: user is not active")
+)
+
+// Auth logs users in and out of their session, and remembers them across sessions with
+// a long lived cookie when asked to.
+type Auth struct {
+	Sessions *session.Manager
+	Provider UserProvider
+	// Remember stores remember me tokens; without it remember me is ignored
+	Remember RememberStore
+	// Tokens stores api tokens for AuthToken
+	Tokens TokenStore
+	Hasher Hasher
+
+	RememberCookie string
+	RememberFor    time.Duration
+	Secure         bool
+	Domain         string
+	// LoginURL is where Auth sends guests, and HomeURL where Guest sends users
+	LoginURL string
+	HomeURL  string
+	// ErrorHandler answers api requests refused by AuthToken and RequireAbility; without
+	// it they get application/problem+json.
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+	// Signer signs the password reset and email verification links that Notifier
+	// delivers. BaseURL is put in front of ResetURL and VerifyURL to make them absolute.
+	Signer          *Signer
+	Notifier        Notifier
+	BaseURL         string
+	ResetURL        string
+	VerifyURL       string
+	VerifyNoticeURL string
+	ResetFor        time.Duration
+	VerifyFor       time.Duration
+	// ResetLimit caps reset requests per email and per ip address in an hour, counted
+	// in Cache when there is one
+	ResetLimit int
+	Cache      cache.Cache
+
+	// TwoFactor stores TOTP secrets, encrypted with Encrypter when it is set. Issuer
+	// names the application in authenticator apps.
+	TwoFactor         TwoFactorStore
+	Encrypter         session.Encrypter
+	Issuer            string
+	TwoFactorURL      string
+	TwoFactorSetupURL string
+	// TwoFactorLimit caps second factor attempts per user and per ip address in 15
+	// minutes, 5 by default; the pending login is dropped once it is reached
+	TwoFactorLimit int
+
+	dummyOnce        sync.Once
+	dummyHash        string
+	limiterOnce      sync.Once
+	limiter          *limiter
+	twoFactorOnce    sync.Once
+	twoFactorLimiter *limiter
+}
+
+// Attempt checks the credentials of a user and logs them in.
+func (a *Auth) Attempt(w http.ResponseWriter, r *http.Request, email, password string, remember bool) (User, error) {
+	user, err := a.Provider.FindByEmail(r.Context(), email)
+	if errors.Is(err, ErrUserNotFound) {
+		// hash anyway, so that response times do not reveal which emails exist
+		_, _ = a.Hasher.Verify(password, a.dummy())
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := a.Hasher.Verify(password, user.AuthPassword())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := a.Authenticate(w, r, user, remember); err != nil {
+		if errors.Is(err, ErrTwoFactorRequired) {
+			return user, err
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// Authenticate logs in a user whose identity has already been proven, by a password or
+// by an OAuth provider, with the checks of Attempt: disabled users get ErrInactive, and
+// users with two factor authentication ErrTwoFactorRequired, leaving the session waiting
+// for VerifyTwoFactor.
+func (a *Auth) Authenticate(w http.ResponseWriter, r *http.Request, user User, remember bool) error {
+	if !active(user) {
+		return ErrInactive
+	}
+
+	twoFactor, err := a.HasTwoFactor(r.Context(), user.AuthID())
+	if err != nil {
+		return err
+	}
+
+	if twoFactor {
+		if err := a.startTwoFactor(r, user, remember); err != nil {
+			return err
+		}
+		return ErrTwoFactorRequired
+	}
+
+	return a.Login(w, r, user, remember)
+}
+
+// Login logs user in, giving the session a new token to prevent session fixation.
+func (a *Auth) Login(w http.ResponseWriter, r *http.Request, user User, remember bool) error {
+	if err := a.Sessions.Login(r, user.AuthID()); err != nil {
+		return err
+	}
+
+	if remember && a.Remember != nil {
+		return a.issueRememberToken(w, r, user.AuthID())
+	}
+
+	return nil
+}
+
+// Logout ends the session and forgets the remember me token of the client.
+func (a *Auth) Logout(w http.ResponseWriter, r *http.Request) error {
+	if cookie, err := r.Cookie(a.rememberCookie()); err == nil && a.Remember != nil {
+		if err := a.Remember.DeleteRememberToken(r.Context(), hashToken(cookie.Value)); err != nil {
+			return err
+		}
+	}
+	a.clearRememberCookie(w)
+
+	return a.Sessions.Logout(r.Context())
+}
+
+// Check reports whether the request belongs to a logged in user.
+func (a *Auth) Check(r *http.Request) bool {
+	return a.Sessions.LoggedIn(r.Context())
+}
+
+// ID returns the id of the logged in user, or 0.
+func (a *Auth) ID(r *http.Request) int {
+	return a.Sessions.UserID(r.Context())
+}
+
+// User loads the logged in user from the provider.
+func (a *Auth) User(r *http.Request) (User, error) {
+	if !a.Check(r) {
+		return nil, ErrUserNotFound
+	}
+
+	return a.Provider.FindByID(r.Context(), a.ID(r))
+}
+
+// RememberMe is middleware that logs in guests that present a valid remember me cookie.
+// The token is replaced on every use, so a stolen cookie stops working once the owner
+// comes back, and the tokens of disabled users are deleted instead. It must run after
+// the session is loaded.
+func (a *Auth) RememberMe(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(a.rememberCookie())
+		if err != nil || a.Remember == nil || a.Check(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		hash := hashToken(cookie.Value)
+
+		userID, err := a.Remember.FindRememberToken(ctx, hash, time.Now().Add(-a.rememberFor()))
+		if err == nil {
+			err = a.Remember.DeleteRememberToken(ctx, hash)
+		}
+
+		var user User
+		if err == nil {
+			user, err = a.Provider.FindByID(ctx, userID)
+		}
+		if err == nil && !active(user) {
+			err = ErrInactive
+		}
+
+		if err == nil {
+			err = a.Sessions.Login(r, userID)
+		}
+		if err == nil {
+			err = a.issueRememberToken(w, r, userID)
+		}
+		if err != nil {
+			a.clearRememberCookie(w)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Auth is middleware that only lets logged in users through, redirecting guests to
+// LoginURL, and sessions waiting for a second factor to TwoFactorURL.
+func (a *Auth) Auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.Check(r) {
+			if a.TwoFactorPending(r) {
+				http.Redirect(w, r, a.twoFactorURL(), http.StatusSeeOther)
+				return
+			}
+
+			http.Redirect(w, r, a.loginURL(), http.StatusSeeOther)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Guest is middleware for pages like the login form, redirecting logged in users to
+// HomeURL.
+func (a *Auth) Guest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.Check(r) {
+			http.Redirect(w, r, a.homeURL(), http.StatusSeeOther)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *Auth) issueRememberToken(w http.ResponseWriter, r *http.Request, userID int) error {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	if err := a.Remember.SaveRememberToken(r.Context(), userID, hashToken(token)); err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     a.rememberCookie(),
+		Value:    token,
+		Path:     "/",
+		Domain:   a.Domain,
+		Expires:  time.Now().Add(a.rememberFor()),
+		MaxAge:   int(a.rememberFor().Seconds()),
+		Secure:   a.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return nil
+}
+
+func (a *Auth) clearRememberCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     a.rememberCookie(),
+		Value:    "",
+		Path:     "/",
+		Domain:   a.Domain,
+		Expires:  time.Unix(1, 0),
+		MaxAge:   -1,
+		Secure:   a.Secure,
+		HttpOnly: true,
+	})
+}
+
+func (a *Auth) dummy() string {
+	a.dummyOnce.Do(func() {
+		a.dummyHash, _ = a.Hasher.Hash("not a real password")
+	})
+
+	return a.dummyHash
+}
+
+func (a *Auth) rememberCookie() string {
+	if a.RememberCookie == "" {
+		return "remember_token"
+	}
+	return a.RememberCookie
+}
+
+func (a *Auth) rememberFor() time.Duration {
+	if a.RememberFor == 0 {
+		return 30 * 24 * time.Hour
+	}
+	return a.RememberFor
+}
+
+func (a *Auth) loginURL() string {
+	if a.LoginURL == "" {
+		return "/users/login"
+	}
+	return a.LoginURL
+}
+
+func (a *Auth) homeURL() string {
+	if a.HomeURL == "" {
+		return "/"
+	}
+	return a.HomeURL
+}
+
+// Active reports whether the user with id may still log in: false for users that were
+// disabled or deleted.
+func (a *Auth) Active(ctx context.Context, id int) (bool, error) {
+	user, err := a.Provider.FindByID(ctx, id)
+	if errors.Is(err, ErrUserNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return active(user), nil
+}
+
+// active reports whether user may log in. Users that cannot be disabled always may.
+func active(user User) bool {
+	u, ok := user.(interface{ AuthActive() bool })
+	return !ok || u.AuthActive()
+}
+
+// hashToken is how remember me tokens are stored, so a leaked table cannot be used to
+// log in. The sha256 hex digest fits the 100 characters of remember_token.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
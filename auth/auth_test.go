@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// serve runs handler with the session loaded, sending cookies, and returns the response.
+func serve(handler http.HandlerFunc, cookies ...*http.Cookie) *http.Response {
+	req := httptest.NewRequest("POST", "/", nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+
+	rr := httptest.NewRecorder()
+	testAuth.Sessions.Session.LoadAndSave(handler).ServeHTTP(rr, req)
+
+	return rr.Result()
+}
+
+func cookieNamed(res *http.Response, name string) *http.Cookie {
+	for _, c := range res.Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestAuth_Attempt(t *testing.T) {
+	var tests = []struct {
+		name     string
+		email    string
+		password string
+		err      error
+	}{
+		{"valid", "active@here.com", "secret", nil},
+		{"wrong_password", "active@here.com", "wrong", ErrInvalidCredentials},
+		{"unknown_email", "nobody@here.com", "secret", ErrInvalidCredentials},
+		{"inactive", "inactive@here.com", "secret", ErrInactive},
+		{"no_password", "oauth@here.com", "", ErrInvalidCredentials},
+	}
+
+	for _, e := range tests {
+		var loggedIn bool
+
+		serve(func(w http.ResponseWriter, r *http.Request) {
+			_, err := testAuth.Attempt(w, r, e.email, e.password, false)
+			if err != e.err {
+				t.Errorf("%s: expected %v, got %v", e.name, e.err, err)
+			}
+			loggedIn = testAuth.Check(r)
+		})
+
+		if loggedIn != (e.err == nil) {
+			t.Errorf("%s: wrong login state after attempt", e.name)
+		}
+	}
+}
+
+func TestAuth_RememberMe(t *testing.T) {
+	res := serve(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := testAuth.Attempt(w, r, "active@here.com", "secret", true); err != nil {
+			t.Error(err)
+		}
+	})
+
+	remember := cookieNamed(res, "remember_token")
+	if remember == nil {
+		t.Fatal("no remember me cookie after login")
+	}
+
+	// a new browser session with only the remember me cookie is logged back in
+	var id int
+	res = serve(func(w http.ResponseWriter, r *http.Request) {
+		testAuth.RememberMe(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id = testAuth.ID(r)
+		})).ServeHTTP(w, r)
+	}, remember)
+
+	if id != 1 {
+		t.Fatal("remember me cookie did not log the user in")
+	}
+
+	rotated := cookieNamed(res, "remember_token")
+	if rotated == nil || rotated.Value == remember.Value {
+		t.Error("remember me token was not replaced after use")
+	}
+
+	// the old token is gone
+	id = 0
+	serve(func(w http.ResponseWriter, r *http.Request) {
+		testAuth.RememberMe(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id = testAuth.ID(r)
+		})).ServeHTTP(w, r)
+	}, remember)
+
+	if id != 0 {
+		t.Error("used remember me token logged the user in again")
+	}
+}
+
+func TestAuth_Middleware(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	res := serve(testAuth.Auth(ok).ServeHTTP)
+	if res.StatusCode != http.StatusSeeOther || res.Header.Get("Location") != "/users/login" {
+		t.Error("guest was not redirected to the login page")
+	}
+
+	res = serve(testAuth.Guest(ok).ServeHTTP)
+	if res.StatusCode != http.StatusOK {
+		t.Error("guest was not let through the guest middleware")
+	}
+
+	res = serve(func(w http.ResponseWriter, r *http.Request) {
+		_ = testAuth.Login(w, r, &SQLUser{ID: 1}, false)
+		testAuth.Guest(ok).ServeHTTP(w, r)
+	})
+	if res.StatusCode != http.StatusSeeOther || res.Header.Get("Location") != "/" {
+		t.Error("logged in user was not redirected away from a guest page")
+	}
+}
+
+func TestAuth_Authenticate(t *testing.T) {
+	// users proven by other means, such as an OAuth provider, get the checks of Attempt
+	serve(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := testAuth.Provider.FindByID(r.Context(), 2)
+		if err := testAuth.Authenticate(w, r, user, false); err != ErrInactive {
+			t.Error("expected ErrInactive, got", err)
+		}
+		if testAuth.Check(r) {
+			t.Error("disabled user logged in")
+		}
+	})
+
+	serve(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := testAuth.Provider.FindByID(r.Context(), 1)
+		if err := testAuth.Authenticate(w, r, user, false); err != nil {
+			t.Error(err)
+		}
+		if !testAuth.Check(r) {
+			t.Error("user not logged in")
+		}
+	})
+}
+
+func TestAuth_RememberMeInactive(t *testing.T) {
+	ctx := context.Background()
+	token := "inactive-remember-token"
+	if err := testAuth.Remember.SaveRememberToken(ctx, 2, hashToken(token)); err != nil {
+		t.Fatal(err)
+	}
+
+	id := 0
+	res := serve(func(w http.ResponseWriter, r *http.Request) {
+		testAuth.RememberMe(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id = testAuth.ID(r)
+		})).ServeHTTP(w, r)
+	}, &http.Cookie{Name: "remember_token", Value: token})
+
+	if id != 0 {
+		t.Error("remember me cookie logged in a disabled user")
+	}
+
+	if c := cookieNamed(res, "remember_token"); c == nil || c.MaxAge >= 0 {
+		t.Error("remember me cookie of a disabled user not cleared")
+	}
+
+	if _, err := testAuth.Remember.FindRememberToken(ctx, hashToken(token), time.Time{}); err == nil {
+		t.Error("remember me token of a disabled user not deleted")
+	}
+}
+
+func TestAuth_Active(t *testing.T) {
+	for _, e := range []struct {
+		id     int
+		active bool
+	}{{1, true}, {2, false}, {99, false}} {
+		active, err := testAuth.Active(context.Background(), e.id)
+		if err != nil || active != e.active {
+			t.Errorf("user %d: expected %v, got %v %v", e.id, e.active, active, err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package auth
+
+import "testing"
+
+func TestHasher(t *testing.T) {
+	hashers := map[string]Hasher{
+		"bcrypt": &BcryptHasher{Cost: 4},
+		"argon2": &Argon2Hasher{Time: 1, Memory: 1024, Threads: 1},
+	}
+
+	for name, h := range hashers {
+		hash, err := h.Hash("secret")
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		ok, err := h.Verify("secret", hash)
+		if err != nil || !ok {
+			t.Errorf("%s: password does not match its own hash", name)
+		}
+
+		ok, _ = h.Verify("wrong", hash)
+		if ok {
+			t.Errorf("%s: wrong password matched", name)
+		}
+	}
+}
+
+func TestVerify_AcrossHashers(t *testing.T) {
+	hash, _ := (&Argon2Hasher{Time: 1, Memory: 1024, Threads: 1}).Hash("secret")
+
+	// a bcrypt hasher still accepts passwords hashed before switching to bcrypt
+	ok, err := (&BcryptHasher{}).Verify("secret", hash)
+	if err != nil || !ok {
+		t.Error("argon2 hash not verified by the bcrypt hasher")
+	}
+
+	ok, err = Verify("", "")
+	if err != nil || ok {
+		t.Error("expected an empty hash not to match, got", ok, err)
+	}
+
+	_, err = Verify("secret", "$argon2id$v=19$garbage")
+	if err != ErrInvalidHash {
+		t.Error("expected ErrInvalidHash, got", err)
+	}
+}
+
+func TestNewHasher(t *testing.T) {
+	if _, ok := NewHasher("argon2").(*Argon2Hasher); !ok {
+		t.Error("argon2 did not give an argon2 hasher")
+	}
+
+	if _, ok := NewHasher("").(*BcryptHasher); !ok {
+		t.Error("bcrypt is not the default hasher")
+	}
+}
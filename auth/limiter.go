@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hilsonxhero/napoleon/cache"
+)
+
+// limiter counts attempts per key in a fixed window. With a cache the counts are shared
+// between servers, and the window starts over with every attempt; without one they are
+// kept in memory.
+type limiter struct {
+	cache  cache.Cache
+	limit  int
+	window time.Duration
+
+	mu      sync.Mutex
+	windows map[string]limitWindow
+}
+
+type limitWindow struct {
+	count int
+	reset time.Time
+}
+
+// allow records an attempt for key and reports whether it is within the limit.
+func (l *limiter) allow(key string) bool {
+	if l.cache != nil {
+		return l.allowCache(key)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.windows == nil {
+		l.windows = make(map[string]limitWindow)
+	}
+
+	now := time.Now()
+	w := l.windows[key]
+	if now.After(w.reset) {
+		w = limitWindow{reset: now.Add(l.window)}
+	}
+
+	w.count++
+	l.windows[key] = w
+
+	return w.count <= l.limit
+}
+
+// reset forgets the attempts recorded for key.
+func (l *limiter) reset(key string) {
+	if l.cache != nil {
+		_ = l.cache.Forget("ratelimit:" + key)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.windows, key)
+}
+
+func (l *limiter) allowCache(key string) bool {
+	key = "ratelimit:" + key
+
+	count := 0
+	if value, err := l.cache.Get(key); err == nil {
+		count, _ = value.(int)
+	}
+
+	count++
+	_ = l.cache.Set(key, count, int(l.window.Seconds()))
+
+	return count <= l.limit
+}
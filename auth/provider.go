@@ -0,0 +1,330 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/hilsonxhero/napoleon/internal/support"
+)
+
+// ErrUserNotFound is returned by a UserProvider when no user matches.
+var ErrUserNotFound = errors.New("auth: user not found")
+
+// User is anything that can log in. Users that also implement AuthActive can be
+// disabled.
+type User interface {
+	AuthID() int
+	AuthPassword() string
+}
+
+// UserProvider looks users up for Auth. The default, SQLProvider, reads the users table
+// created by "napoleon make auth"; applications with their own user storage plug in their
+// own provider.
+type UserProvider interface {
+	FindByID(ctx context.Context, id int) (User, error)
+	FindByEmail(ctx context.Context, email string) (User, error)
+}
+
+// RememberStore keeps the hashes of remember me tokens.
+type RememberStore interface {
+	SaveRememberToken(ctx context.Context, userID int, hash string) error
+	// FindRememberToken returns the user of a token created after since.
+	FindRememberToken(ctx context.Context, hash string, since time.Time) (int, error)
+	DeleteRememberToken(ctx context.Context, hash string) error
+	// DeleteRememberTokens forgets every remember me token of a user
+	DeleteRememberTokens(ctx context.Context, userID int) error
+}
+
+// SQLUser is a row of the users table.
+type SQLUser struct {
+	ID              int
+	FirstName       string
+	LastName        string
+	Email           string
+	Active          int
+	Password        string
+	EmailVerifiedAt sql.NullTime
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+func (u *SQLUser) AuthID() int {
+	return u.ID
+}
+
+func (u *SQLUser) AuthPassword() string {
+	return u.Password
+}
+
+func (u *SQLUser) AuthActive() bool {
+	return u.Active == 1
+}
+
+func (u *SQLUser) AuthEmail() string {
+	return u.Email
+}
+
+func (u *SQLUser) AuthVerified() bool {
+	return u.EmailVerifiedAt.Valid
+}
+
+// SQLProvider finds users in the users table, and keeps remember me tokens in the
+// remember_tokens table.
+type SQLProvider struct {
+	DB     *sql.DB
+	DBType string
+}
+
+const userColumns = "id, first_name, last_name, email, user_active, password, email_verified_at, created_at, updated_at"
+
+func (p *SQLProvider) FindByID(ctx context.Context, id int) (User, error) {
+	return p.findUser(ctx, "select "+userColumns+" from users where id = ?", id)
+}
+
+func (p *SQLProvider) FindByEmail(ctx context.Context, email string) (User, error) {
+	return p.findUser(ctx, "select "+userColumns+" from users where email = ?", email)
+}
+
+func (p *SQLProvider) findUser(ctx context.Context, query string, arg interface{}) (User, error) {
+	var u SQLUser
+
+	row := p.DB.QueryRowContext(ctx, support.Rebind(p.DBType, query), arg)
+	err := row.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Email, &u.Active, &u.Password, &u.EmailVerifiedAt,
+		&u.CreatedAt, &u.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+func (p *SQLProvider) UpdatePassword(ctx context.Context, id int, hash string) error {
+	_, err := p.DB.ExecContext(ctx, support.Rebind(p.DBType, "update users set password = ?, updated_at = ? where id = ?"),
+		hash, time.Now(), id)
+
+	return err
+}
+
+func (p *SQLProvider) MarkEmailVerified(ctx context.Context, id int) error {
+	now := time.Now()
+
+	_, err := p.DB.ExecContext(ctx, support.Rebind(p.DBType, "update users set email_verified_at = ?, updated_at = ? where id = ?"),
+		now, now, id)
+
+	return err
+}
+
+func (p *SQLProvider) SaveRememberToken(ctx context.Context, userID int, hash string) error {
+	now := time.Now()
+
+	_, err := p.DB.ExecContext(ctx, support.Rebind(p.DBType, `insert into remember_tokens (user_id, remember_token, created_at, updated_at)
+		values (?, ?, ?, ?)`), userID, hash, now, now)
+
+	return err
+}
+
+func (p *SQLProvider) FindRememberToken(ctx context.Context, hash string, since time.Time) (int, error) {
+	var userID int
+
+	row := p.DB.QueryRowContext(ctx, support.Rebind(p.DBType, "select user_id from remember_tokens where remember_token = ? and created_at > ?"),
+		hash, since)
+	err := row.Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrUserNotFound
+	}
+
+	return userID, err
+}
+
+func (p *SQLProvider) DeleteRememberToken(ctx context.Context, hash string) error {
+	_, err := p.DB.ExecContext(ctx, support.Rebind(p.DBType, "delete from remember_tokens where remember_token = ?"), hash)
+	return err
+}
+
+func (p *SQLProvider) DeleteRememberTokens(ctx context.Context, userID int) error {
+	_, err := p.DB.ExecContext(ctx, support.Rebind(p.DBType, "delete from remember_tokens where user_id = ?"), userID)
+	return err
+}
+
+func (p *SQLProvider) FindToken(ctx context.Context, hash []byte) (*Token, error) {
+	var t Token
+	var abilities string
+
+	row := p.DB.QueryRowContext(ctx, support.Rebind(p.DBType, "select id, user_id, abilities, expiry from tokens where token_hash = ?"), hash)
+	err := row.Scan(&t.ID, &t.UserID, &abilities, &t.Expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	t.Abilities = splitAbilities(abilities)
+
+	return &t, nil
+}
+
+// CreateToken stores a token that only has a hash. The token column, which the generated
+// Token model fills with the plain text, is left empty.
+func (p *SQLProvider) CreateToken(ctx context.Context, t *Token, hash []byte) error {
+	now := time.Now()
+	query := support.Rebind(p.DBType, `insert into tokens (user_id, first_name, email, token, token_hash, abilities, created_at, updated_at, expiry)
+		select id, first_name, email, '', ?, ?, ?, ?, ? from users where id = ?`)
+	args := []interface{}{hash, strings.Join(t.Abilities, ","), now, now, t.Expires, t.UserID}
+
+	switch strings.ToLower(p.DBType) {
+	case "mysql", "mariadb":
+		res, err := p.DB.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrUserNotFound
+		}
+
+		id, err := res.LastInsertId()
+		t.ID = int(id)
+		return err
+	}
+
+	err := p.DB.QueryRowContext(ctx, query+" returning id", args...).Scan(&t.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	}
+
+	return err
+}
+
+func (p *SQLProvider) DeleteToken(ctx context.Context, id int) error {
+	res, err := p.DB.ExecContext(ctx, support.Rebind(p.DBType, "delete from tokens where id = ?"), id)
+	if err != nil {
+		return err
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTokenNotFound
+	}
+
+	return nil
+}
+
+func (p *SQLProvider) FindTwoFactor(ctx context.Context, userID int) (*TwoFactor, error) {
+	tf := TwoFactor{UserID: userID}
+	var confirmedAt sql.NullTime
+
+	row := p.DB.QueryRowContext(ctx, support.Rebind(p.DBType, "select secret, confirmed_at, last_step from user_two_factor where user_id = ?"), userID)
+	err := row.Scan(&tf.Secret, &confirmedAt, &tf.LastStep)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tf.ConfirmedAt = confirmedAt.Time
+
+	return &tf, nil
+}
+
+func (p *SQLProvider) SaveTwoFactor(ctx context.Context, tf *TwoFactor) error {
+	var confirmedAt sql.NullTime
+	if tf.Confirmed() {
+		confirmedAt = sql.NullTime{Time: tf.ConfirmedAt, Valid: true}
+	}
+
+	now := time.Now()
+
+	var query string
+	switch strings.ToLower(p.DBType) {
+	case "mysql", "mariadb":
+		query = `insert into user_two_factor (user_id, secret, confirmed_at, last_step, created_at, updated_at)
+			values (?, ?, ?, ?, ?, ?)
+			on duplicate key update secret = values(secret), confirmed_at = values(confirmed_at),
+			last_step = values(last_step), updated_at = values(updated_at)`
+	default:
+		query = `insert into user_two_factor (user_id, secret, confirmed_at, last_step, created_at, updated_at)
+			values (?, ?, ?, ?, ?, ?)
+			on conflict (user_id) do update set secret = excluded.secret, confirmed_at = excluded.confirmed_at,
+			last_step = excluded.last_step, updated_at = excluded.updated_at`
+	}
+
+	_, err := p.DB.ExecContext(ctx, support.Rebind(p.DBType, query), tf.UserID, tf.Secret, confirmedAt, tf.LastStep, now, now)
+
+	return err
+}
+
+func (p *SQLProvider) DeleteTwoFactor(ctx context.Context, userID int) error {
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, support.Rebind(p.DBType, "delete from user_recovery_codes where user_id = ?"), userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, support.Rebind(p.DBType, "delete from user_two_factor where user_id = ?"), userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (p *SQLProvider) SaveRecoveryCodes(ctx context.Context, userID int, hashes []string) error {
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, support.Rebind(p.DBType, "delete from user_recovery_codes where user_id = ?"), userID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, hash := range hashes {
+		_, err = tx.ExecContext(ctx, support.Rebind(p.DBType, "insert into user_recovery_codes (user_id, code_hash, created_at) values (?, ?, ?)"),
+			userID, hash, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (p *SQLProvider) UseRecoveryCode(ctx context.Context, userID int, hash string) (bool, error) {
+	res, err := p.DB.ExecContext(ctx, support.Rebind(p.DBType, "update user_recovery_codes set used_at = ? where user_id = ? and code_hash = ? and used_at is null"),
+		time.Now(), userID, hash)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+
+	return n == 1, err
+}
+
+func splitAbilities(str string) []string {
+	var abilities []string
+
+	for _, a := range strings.Split(str, ",") {
+		a = strings.TrimSpace(a)
+		if a != "" {
+			abilities = append(abilities, a)
+		}
+	}
+
+	return abilities
+}
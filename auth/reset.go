@@ -0,0 +1,259 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hilsonxhero/napoleon/internal/support"
+)
+
+// ErrTooManyRequests is returned when password resets are requested, or second factors
+// tried, too often.
+var ErrTooManyRequests = errors.New("auth: too many requests")
+
+// Notifier delivers password reset and email verification links to users.
+type Notifier interface {
+	SendPasswordReset(ctx context.Context, user User, link string) error
+	SendVerification(ctx context.Context, user User, link string) error
+}
+
+// PasswordUpdater is implemented by providers that can change passwords and mark email
+// addresses as verified, which the reset and verification flows need.
+type PasswordUpdater interface {
+	UpdatePassword(ctx context.Context, id int, hash string) error
+	MarkEmailVerified(ctx context.Context, id int) error
+}
+
+// SendPasswordReset mails a signed reset link to the user with email. Unknown emails
+// are not reported, so the form cannot be used to find out who has an account.
+func (a *Auth) SendPasswordReset(r *http.Request, email string) error {
+	ctx := r.Context()
+
+	if !a.resetLimiter().allow("reset:email:"+strings.ToLower(email)) ||
+		!a.resetLimiter().allow("reset:ip:"+support.ClientIP(r)) {
+		return ErrTooManyRequests
+	}
+
+	user, err := a.Provider.FindByEmail(ctx, email)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	link, err := a.signedLink(a.resetURL(), user, a.resetFor())
+	if err != nil {
+		return err
+	}
+
+	return a.Notifier.SendPasswordReset(ctx, user, link)
+}
+
+// ResetPassword sets a new password for the user of a signed reset link, and logs them
+// out everywhere, remember me cookies included. A link stops working once the password
+// has been changed.
+func (a *Auth) ResetPassword(r *http.Request, password string) (User, error) {
+	user, err := a.userForLink(r)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := a.Hasher.Hash(password)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := r.Context()
+	if err := a.updater().UpdatePassword(ctx, user.AuthID(), hash); err != nil {
+		return nil, err
+	}
+
+	if a.Remember != nil {
+		if err := a.Remember.DeleteRememberTokens(ctx, user.AuthID()); err != nil {
+			return nil, err
+		}
+	}
+
+	return user, a.Sessions.RevokeAllForUser(ctx, user.AuthID())
+}
+
+// SendVerification mails a signed link that verifies the email address of user.
+func (a *Auth) SendVerification(r *http.Request, user User) error {
+	link, err := a.signedLink(a.verifyURL(), user, a.verifyFor())
+	if err != nil {
+		return err
+	}
+
+	return a.Notifier.SendVerification(r.Context(), user, link)
+}
+
+// VerifyEmail marks the email address of the user of a signed verification link as
+// verified.
+func (a *Auth) VerifyEmail(r *http.Request) (User, error) {
+	user, err := a.userForLink(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, a.updater().MarkEmailVerified(r.Context(), user.AuthID())
+}
+
+// Verified is middleware that sends logged in users whose email address is not verified
+// to VerifyNoticeURL. Users that do not implement AuthVerified are let through.
+func (a *Auth) Verified(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := a.User(r)
+		if err == nil {
+			if u, ok := user.(interface{ AuthVerified() bool }); ok && !u.AuthVerified() {
+				http.Redirect(w, r, a.verifyNoticeURL(), http.StatusSeeOther)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// signedLink builds a link for user to path. It carries a fingerprint of the password
+// hash and the email address, so changing either invalidates every link handed out
+// before, and a verification link only ever verifies the address it was sent to.
+func (a *Auth) signedLink(path string, user User, ttl time.Duration) (string, error) {
+	q := url.Values{}
+	q.Set("id", strconv.Itoa(user.AuthID()))
+	q.Set("fp", a.fingerprint(user))
+
+	return a.Signer.Sign(strings.TrimSuffix(a.BaseURL, "/")+path+"?"+q.Encode(), ttl)
+}
+
+// userForLink checks the signature of the request and returns the user it was made for.
+func (a *Auth) userForLink(r *http.Request) (User, error) {
+	if err := a.Signer.VerifyRequest(r); err != nil {
+		return nil, err
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	user, err := a.Provider.FindByID(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hmac.Equal([]byte(r.URL.Query().Get("fp")), []byte(a.fingerprint(user))) {
+		return nil, ErrLinkExpired
+	}
+
+	return user, nil
+}
+
+func (a *Auth) fingerprint(user User) string {
+	mac := hmac.New(sha256.New, a.Signer.Key)
+	mac.Write([]byte(user.AuthPassword()))
+	if u, ok := user.(interface{ AuthEmail() string }); ok {
+		// a zero byte cannot be part of either, so the two cannot run into each other
+		mac.Write([]byte{0})
+		mac.Write([]byte(strings.ToLower(u.AuthEmail())))
+	}
+
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+func (a *Auth) updater() PasswordUpdater {
+	updater, ok := a.Provider.(PasswordUpdater)
+	if !ok {
+		return unsupportedUpdater{}
+	}
+	return updater
+}
+
+func (a *Auth) resetLimiter() *limiter {
+	a.limiterOnce.Do(func() {
+		limit := a.ResetLimit
+		if limit == 0 {
+			limit = 5
+		}
+
+		a.limiter = &limiter{
+			cache:  a.Cache,
+			limit:  limit,
+			window: time.Hour,
+		}
+	})
+
+	return a.limiter
+}
+
+func (a *Auth) resetURL() string {
+	if a.ResetURL == "" {
+		return "/users/reset-password"
+	}
+	return a.ResetURL
+}
+
+func (a *Auth) verifyURL() string {
+	if a.VerifyURL == "" {
+		return "/users/verify-email"
+	}
+	return a.VerifyURL
+}
+
+func (a *Auth) verifyNoticeURL() string {
+	if a.VerifyNoticeURL == "" {
+		return "/users/verify-email/notice"
+	}
+	return a.VerifyNoticeURL
+}
+
+func (a *Auth) resetFor() time.Duration {
+	if a.ResetFor == 0 {
+		return time.Hour
+	}
+	return a.ResetFor
+}
+
+func (a *Auth) verifyFor() time.Duration {
+	if a.VerifyFor == 0 {
+		return 24 * time.Hour
+	}
+	return a.VerifyFor
+}
+
+type unsupportedUpdater struct{}
+
+var errNoUpdater = errors.New("auth: user provider cannot update users")
+
+func (unsupportedUpdater) UpdatePassword(ctx context.Context, id int, hash string) error {
+	return errNoUpdater
+}
+
+func (unsupportedUpdater) MarkEmailVerified(ctx context.Context, id int) error {
+	return errNoUpdater
+}
+
+// LogNotifier writes links to a log instead of sending them, which is handy in
+// development before a mailer is set up.
+type LogNotifier struct {
+	Log *log.Logger
+}
+
+func (n *LogNotifier) SendPasswordReset(ctx context.Context, user User, link string) error {
+	n.Log.Printf("Password reset link for user %d: %s", user.AuthID(), link)
+	return nil
+}
+
+func (n *LogNotifier) SendVerification(ctx context.Context, user User, link string) error {
+	n.Log.Printf("Email verification link for user %d: %s", user.AuthID(), link)
+	return nil
+}
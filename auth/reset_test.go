@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// testNotifier keeps the last link it was asked to send.
+type testNotifier struct {
+	link string
+}
+
+func (n *testNotifier) SendPasswordReset(ctx context.Context, user User, link string) error {
+	n.link = link
+	return nil
+}
+
+func (n *testNotifier) SendVerification(ctx context.Context, user User, link string) error {
+	n.link = link
+	return nil
+}
+
+func (p *testProvider) UpdatePassword(ctx context.Context, id int, hash string) error {
+	for _, u := range p.users {
+		if u.ID == id {
+			u.Password = hash
+		}
+	}
+	return nil
+}
+
+func (p *testProvider) MarkEmailVerified(ctx context.Context, id int) error {
+	for _, u := range p.users {
+		if u.ID == id {
+			u.EmailVerifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		}
+	}
+	return nil
+}
+
+// linkRequest turns a link into a request that has the session loaded.
+func linkRequest(t *testing.T, method, link string) *http.Request {
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(method, u.RequestURI(), nil)
+	ctx, _ := testAuth.Sessions.Session.Load(req.Context(), "")
+
+	return req.WithContext(ctx)
+}
+
+func TestAuth_ResetPassword(t *testing.T) {
+	notifier := &testNotifier{}
+	testAuth.Notifier = notifier
+	testAuth.Signer = &Signer{Key: []byte("12345678901234567890123456789012")}
+	testAuth.BaseURL = "https://example.com"
+
+	err := testAuth.SendPasswordReset(linkRequest(t, "POST", "/users/forgot-password"), "active@here.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if notifier.link == "" {
+		t.Fatal("no reset link sent")
+	}
+	link := notifier.link
+
+	_, err = testAuth.ResetPassword(linkRequest(t, "POST", link), "new secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, _ := testAuth.Provider.FindByID(context.Background(), 1)
+	if ok, _ := testAuth.Hasher.Verify("new secret", user.AuthPassword()); !ok {
+		t.Error("password was not changed")
+	}
+
+	// the link cannot be used twice, since the password it was made for is gone
+	_, err = testAuth.ResetPassword(linkRequest(t, "POST", link), "another secret")
+	if err != ErrLinkExpired {
+		t.Error("expected ErrLinkExpired for a used link, got", err)
+	}
+
+	// put the password back for the other tests
+	_ = testAuth.updater().UpdatePassword(context.Background(), 1, testPasswordHash)
+
+	// unknown emails are not reported
+	notifier.link = ""
+	err = testAuth.SendPasswordReset(linkRequest(t, "POST", "/users/forgot-password"), "nobody@here.com")
+	if err != nil || notifier.link != "" {
+		t.Error("reset for an unknown email was reported or sent")
+	}
+}
+
+func TestAuth_ResetLimit(t *testing.T) {
+	a := &Auth{ResetLimit: 2, Provider: testAuth.Provider, Notifier: &testNotifier{}}
+
+	for i := 0; i < 2; i++ {
+		if err := a.SendPasswordReset(linkRequest(t, "POST", "/"), "nobody@here.com"); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if err := a.SendPasswordReset(linkRequest(t, "POST", "/"), "nobody@here.com"); err != ErrTooManyRequests {
+		t.Error("expected ErrTooManyRequests, got", err)
+	}
+}
+
+func TestAuth_VerifyEmail(t *testing.T) {
+	notifier := &testNotifier{}
+	testAuth.Notifier = notifier
+	testAuth.Signer = &Signer{Key: []byte("12345678901234567890123456789012")}
+
+	user, _ := testAuth.Provider.FindByID(context.Background(), 1)
+
+	err := testAuth.SendVerification(linkRequest(t, "POST", "/"), user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = testAuth.VerifyEmail(linkRequest(t, "GET", notifier.link))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !user.(*SQLUser).AuthVerified() {
+		t.Error("email not marked as verified")
+	}
+}
+
+func TestAuth_ResetPasswordForgetsRememberMe(t *testing.T) {
+	notifier := &testNotifier{}
+	testAuth.Notifier = notifier
+	testAuth.Signer = &Signer{Key: []byte("12345678901234567890123456789012")}
+	defer func() { _ = testAuth.updater().UpdatePassword(context.Background(), 1, testPasswordHash) }()
+
+	res := serve(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := testAuth.Attempt(w, r, "active@here.com", "secret", true); err != nil {
+			t.Error(err)
+		}
+	})
+
+	remember := cookieNamed(res, "remember_token")
+	if remember == nil {
+		t.Fatal("no remember me cookie after login")
+	}
+
+	if err := testAuth.SendPasswordReset(linkRequest(t, "POST", "/users/forgot-password"), "active@here.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := testAuth.ResetPassword(linkRequest(t, "POST", notifier.link), "new secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a remember me cookie from before the reset, say a stolen one, no longer logs in
+	id := 0
+	serve(func(w http.ResponseWriter, r *http.Request) {
+		testAuth.RememberMe(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id = testAuth.ID(r)
+		})).ServeHTTP(w, r)
+	}, remember)
+
+	if id != 0 {
+		t.Error("remember me cookie from before the reset logged the user in")
+	}
+}
+
+func TestAuth_VerifyEmailChanged(t *testing.T) {
+	notifier := &testNotifier{}
+	testAuth.Notifier = notifier
+	testAuth.Signer = &Signer{Key: []byte("12345678901234567890123456789012")}
+
+	user, _ := testAuth.Provider.FindByID(context.Background(), 1)
+	u := user.(*SQLUser)
+	u.EmailVerifiedAt = sql.NullTime{}
+
+	if err := testAuth.SendVerification(linkRequest(t, "POST", "/"), user); err != nil {
+		t.Fatal(err)
+	}
+
+	// the user changes their email before following the link sent to the old one
+	u.Email = "changed@here.com"
+	defer func() { u.Email = "active@here.com" }()
+
+	if _, err := testAuth.VerifyEmail(linkRequest(t, "GET", notifier.link)); err != ErrLinkExpired {
+		t.Error("expected ErrLinkExpired for a link sent to the old email, got", err)
+	}
+
+	if u.AuthVerified() {
+		t.Error("new email verified by a link sent to the old one")
+	}
+}
@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
+	"github.com/hilsonxhero/napoleon/session"
+)
+
+// testProvider keeps users and remember tokens in memory.
+type testProvider struct {
+	mu       sync.Mutex
+	users    []*SQLUser
+	remember map[string]rememberToken
+	tokens   map[string]*Token
+	twoFA    map[int]*TwoFactor
+	codes    map[int]map[string]bool
+}
+
+type rememberToken struct {
+	userID  int
+	created time.Time
+}
+
+func (p *testProvider) FindByID(ctx context.Context, id int) (User, error) {
+	for _, u := range p.users {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func (p *testProvider) FindByEmail(ctx context.Context, email string) (User, error) {
+	for _, u := range p.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func (p *testProvider) SaveRememberToken(ctx context.Context, userID int, hash string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.remember == nil {
+		p.remember = make(map[string]rememberToken)
+	}
+	p.remember[hash] = rememberToken{userID: userID, created: time.Now()}
+	return nil
+}
+
+func (p *testProvider) FindRememberToken(ctx context.Context, hash string, since time.Time) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	t, ok := p.remember[hash]
+	if !ok || !t.created.After(since) {
+		return 0, ErrUserNotFound
+	}
+	return t.userID, nil
+}
+
+func (p *testProvider) DeleteRememberToken(ctx context.Context, hash string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.remember, hash)
+	return nil
+}
+
+func (p *testProvider) DeleteRememberTokens(ctx context.Context, userID int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for hash, t := range p.remember {
+		if t.userID == userID {
+			delete(p.remember, hash)
+		}
+	}
+	return nil
+}
+
+func (p *testProvider) FindToken(ctx context.Context, hash []byte) (*Token, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	t, ok := p.tokens[string(hash)]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	return t, nil
+}
+
+func (p *testProvider) CreateToken(ctx context.Context, t *Token, hash []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.tokens == nil {
+		p.tokens = make(map[string]*Token)
+	}
+	t.ID = len(p.tokens) + 1
+	p.tokens[string(hash)] = t
+	return nil
+}
+
+func (p *testProvider) DeleteToken(ctx context.Context, id int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for hash, t := range p.tokens {
+		if t.ID == id {
+			delete(p.tokens, hash)
+			return nil
+		}
+	}
+	return ErrTokenNotFound
+}
+
+func (p *testProvider) FindTwoFactor(ctx context.Context, userID int) (*TwoFactor, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	tf, ok := p.twoFA[userID]
+	if !ok {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	copied := *tf
+	return &copied, nil
+}
+
+func (p *testProvider) SaveTwoFactor(ctx context.Context, tf *TwoFactor) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.twoFA == nil {
+		p.twoFA = make(map[int]*TwoFactor)
+	}
+	copied := *tf
+	p.twoFA[tf.UserID] = &copied
+	return nil
+}
+
+func (p *testProvider) DeleteTwoFactor(ctx context.Context, userID int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.twoFA, userID)
+	delete(p.codes, userID)
+	return nil
+}
+
+func (p *testProvider) SaveRecoveryCodes(ctx context.Context, userID int, hashes []string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.codes == nil {
+		p.codes = make(map[int]map[string]bool)
+	}
+	p.codes[userID] = make(map[string]bool)
+	for _, h := range hashes {
+		p.codes[userID][h] = false
+	}
+	return nil
+}
+
+func (p *testProvider) UseRecoveryCode(ctx context.Context, userID int, hash string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	used, ok := p.codes[userID][hash]
+	if !ok || used {
+		return false, nil
+	}
+	p.codes[userID][hash] = true
+	return true, nil
+}
+
+var testAuth *Auth
+
+var testPasswordHash string
+
+func TestMain(m *testing.M) {
+	hasher := &BcryptHasher{Cost: 4}
+	hash, _ := hasher.Hash("secret")
+	testPasswordHash = hash
+
+	provider := &testProvider{
+		users: []*SQLUser{
+			{ID: 1, Email: "active@here.com", Active: 1, Password: hash},
+			{ID: 2, Email: "inactive@here.com", Active: 0, Password: hash},
+			{ID: 3, Email: "oauth@here.com", Active: 1},
+		},
+	}
+
+	sm := scs.New()
+	sm.Store = memstore.New()
+
+	testAuth = &Auth{
+		Sessions:  &session.Manager{Session: sm, Index: &session.MemoryIndex{}},
+		Provider:  provider,
+		Remember:  provider,
+		Tokens:    provider,
+		TwoFactor: provider,
+		Hasher:    hasher,
+	}
+
+	os.Exit(m.Run())
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigner(t *testing.T) {
+	s := &Signer{Key: []byte("12345678901234567890123456789012")}
+
+	link, err := s.Sign("https://example.com/users/verify-email?id=1", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Verify(link); err != nil {
+		t.Error("signed link not valid:", err)
+	}
+
+	tampered := strings.Replace(link, "id=1", "id=2", 1)
+	if err := s.Verify(tampered); err != ErrInvalidSignature {
+		t.Error("expected ErrInvalidSignature for a changed link, got", err)
+	}
+
+	other := &Signer{Key: []byte("another key")}
+	if err := other.Verify(link); err != ErrInvalidSignature {
+		t.Error("expected ErrInvalidSignature for another key, got", err)
+	}
+
+	expired, _ := s.Sign("/users/verify-email?id=1", -time.Minute)
+	if err := s.Verify(expired); err != ErrLinkExpired {
+		t.Error("expected ErrLinkExpired, got", err)
+	}
+}
+
+func TestSigner_NoKey(t *testing.T) {
+	s := &Signer{}
+
+	if _, err := s.Sign("/users/verify-email?id=1", time.Hour); err != ErrNoSigningKey {
+		t.Error("expected ErrNoSigningKey signing, got", err)
+	}
+
+	if err := s.Verify("/users/verify-email?id=1&expires=9999999999&signature=00"); err != ErrNoSigningKey {
+		t.Error("expected ErrNoSigningKey verifying, got", err)
+	}
+}
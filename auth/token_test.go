@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAuth_AuthToken(t *testing.T) {
+	ctx := context.Background()
+
+	valid, _, err := testAuth.CreateToken(ctx, 1, []string{"posts:read"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired, _, _ := testAuth.CreateToken(ctx, 1, nil, -time.Hour)
+	revoked, token, _ := testAuth.CreateToken(ctx, 1, nil, time.Hour)
+	inactive, _, _ := testAuth.CreateToken(ctx, 2, nil, time.Hour)
+
+	if err := testAuth.RevokeToken(ctx, token.ID); err != nil {
+		t.Error(err)
+	}
+
+	var user User
+	handler := testAuth.AuthToken(RequireAbility("posts:read")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user = UserFromContext(r.Context())
+	})))
+	writer := testAuth.AuthToken(RequireAbility("posts:write")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	var tests = []struct {
+		name    string
+		handler http.Handler
+		header  string
+		status  int
+	}{
+		{"valid", handler, "Bearer " + valid, http.StatusOK},
+		{"no_header", handler, "", http.StatusUnauthorized},
+		{"not_bearer", handler, "Basic " + valid, http.StatusUnauthorized},
+		{"unknown", handler, "Bearer AAAAAAAAAAAAAAAAAAAAAAAAAA", http.StatusUnauthorized},
+		{"expired", handler, "Bearer " + expired, http.StatusUnauthorized},
+		{"revoked", handler, "Bearer " + revoked, http.StatusUnauthorized},
+		{"inactive_user", handler, "Bearer " + inactive, http.StatusUnauthorized},
+		{"missing_ability", writer, "Bearer " + valid, http.StatusForbidden},
+	}
+
+	for _, e := range tests {
+		req := httptest.NewRequest("GET", "/api/posts", nil)
+		if e.header != "" {
+			req.Header.Set("Authorization", e.header)
+		}
+
+		rr := httptest.NewRecorder()
+		e.handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.status {
+			t.Errorf("%s: expected status %d, got %d", e.name, e.status, rr.Code)
+		}
+		if e.status != http.StatusOK && rr.Header().Get("Content-Type") != "application/problem+json" {
+			t.Errorf("%s: expected a problem, got %q", e.name, rr.Header().Get("Content-Type"))
+		}
+	}
+
+	if user == nil || user.AuthID() != 1 {
+		t.Error("user not put in the request context")
+	}
+}
+
+func TestToken_Can(t *testing.T) {
+	token := &Token{Abilities: []string{"posts:read"}}
+	if !token.Can("posts:read") || token.Can("posts:write") {
+		t.Error("wrong abilities for a scoped token")
+	}
+
+	token = &Token{Abilities: []string{"*"}}
+	if !token.Can("anything") {
+		t.Error("* does not grant every ability")
+	}
+}
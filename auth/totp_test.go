@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// the sha1 vectors of RFC 6238, cut to six digits
+var totpVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+}
+
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	for _, e := range totpVectors {
+		code, err := TOTPCode(rfcSecret, time.Unix(e.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if code != e.code {
+			t.Errorf("at %d: expected %s, got %s", e.unix, e.code, code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code, _ := TOTPCode(rfcSecret, now.Add(-30*time.Second))
+
+	if _, ok := ValidateTOTP(rfcSecret, code, now, 1); !ok {
+		t.Error("code from the previous step not accepted with a skew of 1")
+	}
+
+	if _, ok := ValidateTOTP(rfcSecret, code, now, 0); ok {
+		t.Error("code from the previous step accepted without skew")
+	}
+
+	if _, ok := ValidateTOTP(rfcSecret, "000000", now, 1); ok {
+		t.Error("wrong code accepted")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uri := TOTPURI("My App", "me@here.com", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/My%20App:me@here.com?") || !strings.Contains(uri, "secret="+secret) {
+		t.Error("wrong provisioning uri", uri)
+	}
+}
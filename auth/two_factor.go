@@ -0,0 +1,344 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hilsonxhero/napoleon/internal/support"
+)
+
+var (
+	// ErrTwoFactorRequired is returned by Attempt for users with two factor
+	// authentication. The session waits for VerifyTwoFactor before the user is logged in.
+	ErrTwoFactorRequired = errors.New("auth: two factor authentication required")
+	// ErrTwoFactorNotEnabled is returned by a TwoFactorStore for users without 2FA.
+	ErrTwoFactorNotEnabled = errors.New("auth: two factor authentication not enabled")
+	// ErrInvalidCode is returned for wrong, reused or expired codes.
+	ErrInvalidCode = errors.New("auth: invalid code")
+	// ErrTwoFactorEnabled is returned by EnableTwoFactor for users whose 2FA is already
+	// confirmed.
+	ErrTwoFactorEnabled = errors.New("auth: two factor authentication already enabled")
+)
+
+const (
+	twoFactorPendingKey  = "auth.2fa.pending"
+	twoFactorRememberKey = "auth.2fa.remember"
+	recoveryCodeCount    = 8
+)
+
+// TwoFactor is the two factor setup of a user. It only protects logins once confirmed.
+type TwoFactor struct {
+	UserID      int
+	Secret      string
+	ConfirmedAt time.Time
+	// LastStep is the time step of the last accepted code, which cannot be used again
+	LastStep int64
+}
+
+// Confirmed reports whether the user has proven they can generate codes.
+func (tf *TwoFactor) Confirmed() bool {
+	return !tf.ConfirmedAt.IsZero()
+}
+
+// TwoFactorStore keeps two factor secrets and hashed recovery codes.
+type TwoFactorStore interface {
+	FindTwoFactor(ctx context.Context, userID int) (*TwoFactor, error)
+	SaveTwoFactor(ctx context.Context, tf *TwoFactor) error
+	// DeleteTwoFactor removes the secret and the recovery codes of a user
+	DeleteTwoFactor(ctx context.Context, userID int) error
+	// SaveRecoveryCodes replaces the recovery codes of a user
+	SaveRecoveryCodes(ctx context.Context, userID int, hashes []string) error
+	// UseRecoveryCode marks a code as used, reporting whether it was there unused
+	UseRecoveryCode(ctx context.Context, userID int, hash string) (bool, error)
+}
+
+// EnableTwoFactor starts enrollment for a user, returning the secret and the uri to show
+// as a QR code. Logins are not protected until ConfirmTwoFactor. A confirmed setup is
+// never replaced, so that a stray request cannot turn 2FA off; users who want to switch
+// authenticators disable it first, which applications should guard with the password.
+func (a *Auth) EnableTwoFactor(ctx context.Context, userID int, account string) (secret, uri string, err error) {
+	enabled, err := a.HasTwoFactor(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+	if enabled {
+		return "", "", ErrTwoFactorEnabled
+	}
+
+	secret, err = GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	stored, err := a.encryptSecret(secret)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = a.TwoFactor.SaveTwoFactor(ctx, &TwoFactor{UserID: userID, Secret: stored})
+	if err != nil {
+		return "", "", err
+	}
+
+	return secret, TOTPURI(a.issuer(), account, secret), nil
+}
+
+// ConfirmTwoFactor finishes enrollment with a code from the authenticator app, and
+// returns the recovery codes, which are only ever shown this once.
+func (a *Auth) ConfirmTwoFactor(ctx context.Context, userID int, code string) ([]string, error) {
+	tf, err := a.TwoFactor.FindTwoFactor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.checkCode(ctx, tf, code); err != nil {
+		return nil, err
+	}
+
+	tf.ConfirmedAt = time.Now()
+	if err := a.TwoFactor.SaveTwoFactor(ctx, tf); err != nil {
+		return nil, err
+	}
+
+	return a.RegenerateRecoveryCodes(ctx, userID)
+}
+
+// DisableTwoFactor turns two factor authentication off for a user.
+func (a *Auth) DisableTwoFactor(ctx context.Context, userID int) error {
+	return a.TwoFactor.DeleteTwoFactor(ctx, userID)
+}
+
+// HasTwoFactor reports whether logins of a user need a second factor.
+func (a *Auth) HasTwoFactor(ctx context.Context, userID int) (bool, error) {
+	if a.TwoFactor == nil {
+		return false, nil
+	}
+
+	tf, err := a.TwoFactor.FindTwoFactor(ctx, userID)
+	if errors.Is(err, ErrTwoFactorNotEnabled) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return tf.Confirmed(), nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of a user with new ones.
+func (a *Auth) RegenerateRecoveryCodes(ctx context.Context, userID int) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+
+		code := hex.EncodeToString(b)
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+
+	if err := a.TwoFactor.SaveRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// TwoFactorPending reports whether the session waits for a second factor.
+func (a *Auth) TwoFactorPending(r *http.Request) bool {
+	return a.Sessions.Session.Exists(r.Context(), twoFactorPendingKey)
+}
+
+// VerifyTwoFactor completes a login that Attempt left waiting, with either a code from
+// the authenticator app or a recovery code. Once TwoFactorLimit attempts have been made
+// for the user or from the ip address, the pending login is dropped and
+// ErrTooManyRequests returned, so that codes cannot be guessed.
+func (a *Auth) VerifyTwoFactor(w http.ResponseWriter, r *http.Request, code string) error {
+	ctx := r.Context()
+	sm := a.Sessions.Session
+
+	userID := sm.GetInt(ctx, twoFactorPendingKey)
+	if userID == 0 {
+		return ErrInvalidCode
+	}
+
+	userKey := "2fa:user:" + strconv.Itoa(userID)
+	ipKey := "2fa:ip:" + support.ClientIP(r)
+
+	limiter := a.twoFactorLimits()
+	if !limiter.allow(userKey) || !limiter.allow(ipKey) {
+		a.cancelTwoFactor(r)
+		return ErrTooManyRequests
+	}
+
+	tf, err := a.TwoFactor.FindTwoFactor(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	err = a.checkCode(ctx, tf, code)
+	if errors.Is(err, ErrInvalidCode) {
+		ok, rerr := a.TwoFactor.UseRecoveryCode(ctx, userID, hashRecoveryCode(code))
+		if rerr != nil {
+			return rerr
+		}
+		if ok {
+			err = nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	limiter.reset(userKey)
+
+	user, err := a.Provider.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	remember := sm.GetBool(ctx, twoFactorRememberKey)
+	a.cancelTwoFactor(r)
+
+	// the account may have been disabled since the password was checked
+	if !active(user) {
+		return ErrInactive
+	}
+
+	return a.Login(w, r, user, remember)
+}
+
+// RequireTwoFactor is middleware for pages that only users with two factor
+// authentication may see, such as an admin area. Others are sent to TwoFactorSetupURL.
+// It must run after Auth.
+func (a *Auth) RequireTwoFactor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, err := a.HasTwoFactor(r.Context(), a.ID(r))
+		if err != nil || !ok {
+			http.Redirect(w, r, a.twoFactorSetupURL(), http.StatusSeeOther)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// TwoFactorChallenge is middleware for the page that asks for the second factor. Only
+// sessions waiting for one get through.
+func (a *Auth) TwoFactorChallenge(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.TwoFactorPending(r) {
+			http.Redirect(w, r, a.loginURL(), http.StatusSeeOther)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// startTwoFactor puts the session in the pending state, logged in to nobody yet.
+func (a *Auth) startTwoFactor(r *http.Request, user User, remember bool) error {
+	ctx := r.Context()
+
+	if err := a.Sessions.RenewID(ctx); err != nil {
+		return err
+	}
+
+	a.Sessions.Session.Put(ctx, twoFactorPendingKey, user.AuthID())
+	a.Sessions.Session.Put(ctx, twoFactorRememberKey, remember)
+
+	return nil
+}
+
+// cancelTwoFactor takes the session out of the pending state.
+func (a *Auth) cancelTwoFactor(r *http.Request) {
+	a.Sessions.Session.Remove(r.Context(), twoFactorPendingKey)
+	a.Sessions.Session.Remove(r.Context(), twoFactorRememberKey)
+}
+
+func (a *Auth) twoFactorLimits() *limiter {
+	a.twoFactorOnce.Do(func() {
+		limit := a.TwoFactorLimit
+		if limit == 0 {
+			limit = 5
+		}
+
+		a.twoFactorLimiter = &limiter{
+			cache:  a.Cache,
+			limit:  limit,
+			window: 15 * time.Minute,
+		}
+	})
+
+	return a.twoFactorLimiter
+}
+
+// checkCode validates a TOTP code, refusing codes at or before the last one used.
+func (a *Auth) checkCode(ctx context.Context, tf *TwoFactor, code string) error {
+	secret, err := a.decryptSecret(tf.Secret)
+	if err != nil {
+		return err
+	}
+
+	step, ok := ValidateTOTP(secret, code, time.Now(), 1)
+	if !ok || step <= tf.LastStep {
+		return ErrInvalidCode
+	}
+
+	tf.LastStep = step
+	return a.TwoFactor.SaveTwoFactor(ctx, tf)
+}
+
+func (a *Auth) encryptSecret(secret string) (string, error) {
+	if a.Encrypter == nil {
+		return secret, nil
+	}
+	return a.Encrypter.Encrypt(secret)
+}
+
+func (a *Auth) decryptSecret(stored string) (string, error) {
+	if a.Encrypter == nil {
+		return stored, nil
+	}
+	return a.Encrypter.Decrypt(stored)
+}
+
+func (a *Auth) issuer() string {
+	if a.Issuer == "" {
+		return "Napoleon"
+	}
+	return a.Issuer
+}
+
+func (a *Auth) twoFactorURL() string {
+	if a.TwoFactorURL == "" {
+		return "/users/two-factor"
+	}
+	return a.TwoFactorURL
+}
+
+func (a *Auth) twoFactorSetupURL() string {
+	if a.TwoFactorSetupURL == "" {
+		return "/users/two-factor/setup"
+	}
+	return a.TwoFactorSetupURL
+}
+
+// hashRecoveryCode ignores case and dashes, so codes can be typed as they like.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAuth_TwoFactor(t *testing.T) {
+	ctx := context.Background()
+	defer testAuth.DisableTwoFactor(ctx, 1)
+
+	secret, _, err := testAuth.EnableTwoFactor(ctx, 1, "active@here.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// not enforced before it is confirmed
+	if on, _ := testAuth.HasTwoFactor(ctx, 1); on {
+		t.Error("two factor enforced before confirmation")
+	}
+
+	if _, err := testAuth.ConfirmTwoFactor(ctx, 1, "000000"); err != ErrInvalidCode {
+		t.Error("expected ErrInvalidCode for a wrong code, got", err)
+	}
+
+	code, _ := TOTPCode(secret, time.Now())
+	recovery, err := testAuth.ConfirmTwoFactor(ctx, 1, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recovery) != recoveryCodeCount {
+		t.Fatalf("expected %d recovery codes, got %d", recoveryCodeCount, len(recovery))
+	}
+
+	// a confirmed setup is not replaced, which would turn 2FA off until confirmed again
+	if _, _, err := testAuth.EnableTwoFactor(ctx, 1, "active@here.com"); err != ErrTwoFactorEnabled {
+		t.Error("expected ErrTwoFactorEnabled, got", err)
+	}
+	if on, _ := testAuth.HasTwoFactor(ctx, 1); !on {
+		t.Error("enabling again turned two factor off")
+	}
+
+	// the password alone leaves the session pending
+	res := serve(func(w http.ResponseWriter, r *http.Request) {
+		_, err := testAuth.Attempt(w, r, "active@here.com", "secret", false)
+		if err != ErrTwoFactorRequired {
+			t.Error("expected ErrTwoFactorRequired, got", err)
+		}
+		if testAuth.Check(r) || !testAuth.TwoFactorPending(r) {
+			t.Error("session not waiting for the second factor")
+		}
+	})
+	pending := cookieNamed(res, "session")
+
+	// the code used to confirm cannot be used again
+	serve(func(w http.ResponseWriter, r *http.Request) {
+		if err := testAuth.VerifyTwoFactor(w, r, code); err != ErrInvalidCode {
+			t.Error("expected ErrInvalidCode for a reused code, got", err)
+		}
+	}, pending)
+
+	// a recovery code works once
+	serve(func(w http.ResponseWriter, r *http.Request) {
+		if err := testAuth.VerifyTwoFactor(w, r, recovery[0]); err != nil {
+			t.Error(err)
+		}
+		if !testAuth.Check(r) || testAuth.TwoFactorPending(r) {
+			t.Error("user not logged in after the second factor")
+		}
+	}, pending)
+
+	used, _ := testAuth.TwoFactor.UseRecoveryCode(ctx, 1, hashRecoveryCode(recovery[0]))
+	if used {
+		t.Error("recovery code usable twice")
+	}
+}
+
+func TestAuth_TwoFactorMiddleware(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	res := serve(testAuth.TwoFactorChallenge(ok).ServeHTTP)
+	if res.StatusCode != http.StatusSeeOther {
+		t.Error("challenge page reachable without a pending login")
+	}
+
+	res = serve(func(w http.ResponseWriter, r *http.Request) {
+		_ = testAuth.Login(w, r, &SQLUser{ID: 1}, false)
+		testAuth.RequireTwoFactor(ok).ServeHTTP(w, r)
+	})
+	if res.StatusCode != http.StatusSeeOther || res.Header.Get("Location") != "/users/two-factor/setup" {
+		t.Error("user without two factor not sent to setup")
+	}
+}
+
+func TestAuth_TwoFactorLimit(t *testing.T) {
+	ctx := context.Background()
+	newAuth := func() *Auth {
+		return &Auth{
+			Sessions:       testAuth.Sessions,
+			Provider:       testAuth.Provider,
+			TwoFactor:      testAuth.TwoFactor,
+			Hasher:         testAuth.Hasher,
+			TwoFactorLimit: 2,
+		}
+	}
+	a := newAuth()
+	defer a.DisableTwoFactor(ctx, 2)
+
+	secret, _, err := a.EnableTwoFactor(ctx, 2, "inactive@here.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := TOTPCode(secret, time.Now().Add(-30*time.Second))
+	if _, err := a.ConfirmTwoFactor(ctx, 2, code); err != nil {
+		t.Fatal(err)
+	}
+
+	user, _ := a.Provider.FindByID(ctx, 2)
+	res := serve(func(w http.ResponseWriter, r *http.Request) {
+		if err := a.startTwoFactor(r, user, false); err != nil {
+			t.Error(err)
+		}
+	})
+	pending := cookieNamed(res, "session")
+
+	// a disabled user is not logged in by a right code, and the pending login ends
+	code, _ = TOTPCode(secret, time.Now())
+	serve(func(w http.ResponseWriter, r *http.Request) {
+		if err := a.VerifyTwoFactor(w, r, code); err != ErrInactive {
+			t.Error("expected ErrInactive, got", err)
+		}
+		if a.Check(r) || a.TwoFactorPending(r) {
+			t.Error("disabled user logged in, or still pending")
+		}
+	}, pending)
+
+	a = newAuth()
+	res = serve(func(w http.ResponseWriter, r *http.Request) {
+		_ = a.startTwoFactor(r, user, false)
+	})
+	pending = cookieNamed(res, "session")
+
+	for i := 0; i < 2; i++ {
+		serve(func(w http.ResponseWriter, r *http.Request) {
+			if err := a.VerifyTwoFactor(w, r, "000000"); err != ErrInvalidCode {
+				t.Error("expected ErrInvalidCode, got", err)
+			}
+		}, pending)
+	}
+
+	// past the limit the pending login is dropped, so guessing has to start over
+	serve(func(w http.ResponseWriter, r *http.Request) {
+		if err := a.VerifyTwoFactor(w, r, "000000"); err != ErrTooManyRequests {
+			t.Error("expected ErrTooManyRequests, got", err)
+		}
+		if a.TwoFactorPending(r) {
+			t.Error("login still pending after too many attempts")
+		}
+	}, pending)
+}
@@ -0,0 +1,275 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/hilsonxhero/napoleon/internal/support"
+)
+
+var (
+	// ErrUnauthenticated is returned when a guest asks for something that needs a user.
+	ErrUnauthenticated = errors.New("authz: not logged in")
+	// ErrForbidden is returned when the user is not allowed to do what they asked.
+	ErrForbidden = errors.New("authz: forbidden")
+)
+
+// Store loads the roles and permissions of users.
+type Store interface {
+	// Roles returns the names of the roles of the user.
+	Roles(ctx context.Context, userID int) ([]string, error)
+	// Permissions returns the names of the permissions the roles of the user grant.
+	Permissions(ctx context.Context, userID int) ([]string, error)
+}
+
+// Resource is implemented by values that name their resource themselves; other values
+// are named after their type, so a *Post is "post".
+type Resource interface {
+	AuthzResource() string
+}
+
+// Policy decides whether subject may perform action on resource. It is called with the
+// value passed to Can, so it can look at the resource itself, as in "users may edit
+// their own posts".
+type Policy func(ctx context.Context, subject *Subject, action string, resource interface{}) bool
+
+// Subject is a user with their roles and permissions.
+type Subject struct {
+	ID          int
+	Roles       []string
+	Permissions []string
+}
+
+// HasRole reports whether the subject has any of roles.
+func (s *Subject) HasRole(roles ...string) bool {
+	for _, have := range s.Roles {
+		for _, role := range roles {
+			if have == role {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// HasPermission reports whether the subject has permission. Permissions are named
+// "resource:action"; "posts:*" grants every action on posts, and "*" everything.
+func (s *Subject) HasPermission(permission string) bool {
+	resource, _, _ := strings.Cut(permission, ":")
+
+	for _, have := range s.Permissions {
+		if have == permission || have == "*" || have == resource+":*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Authorizer answers whether the current user may do something. Permissions are checked
+// with Can, with policies registered per resource taking precedence over the plain
+// "resource:action" permission check.
+type Authorizer struct {
+	Store Store
+	// UserID returns the id of the user making the request, or 0 for guests.
+	UserID func(ctx context.Context) int
+	// SuperRole is a role that is allowed everything, such as "admin"; empty disables it.
+	SuperRole string
+	// ErrorHandler answers requests refused by RequirePermission and RequireRole; without
+	// it they get application/problem+json.
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+	mu       sync.RWMutex
+	policies map[string]Policy
+}
+
+type contextKey int
+
+const subjectContextKey contextKey = iota
+
+// subjectCache holds the subject of a request once loaded, so that several checks in
+// one request query the store once.
+type subjectCache struct {
+	once    sync.Once
+	subject *Subject
+	err     error
+}
+
+// Policy registers the policy for resource.
+func (a *Authorizer) Policy(resource string, policy Policy) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.policies == nil {
+		a.policies = make(map[string]Policy)
+	}
+
+	a.policies[resource] = policy
+}
+
+// Middleware makes the checks of a request share one load of the user's roles and
+// permissions. Nothing is loaded until a check needs it.
+func (a *Authorizer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), subjectContextKey, &subjectCache{})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Subject returns the user of ctx with their roles and permissions, or nil for guests.
+func (a *Authorizer) Subject(ctx context.Context) (*Subject, error) {
+	cache, ok := ctx.Value(subjectContextKey).(*subjectCache)
+	if !ok {
+		return a.load(ctx)
+	}
+
+	cache.once.Do(func() {
+		cache.subject, cache.err = a.load(ctx)
+	})
+
+	return cache.subject, cache.err
+}
+
+func (a *Authorizer) load(ctx context.Context) (*Subject, error) {
+	id := a.UserID(ctx)
+	if id == 0 {
+		return nil, nil
+	}
+
+	roles, err := a.Store.Roles(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := a.Store.Permissions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Subject{ID: id, Roles: roles, Permissions: permissions}, nil
+}
+
+// Authorize returns nil when the user of ctx may perform action on resource,
+// ErrUnauthenticated for guests and ErrForbidden otherwise. The resource is either a
+// name, such as "posts", or a value with a registered policy.
+func (a *Authorizer) Authorize(ctx context.Context, action string, resource interface{}) error {
+	subject, err := a.Subject(ctx)
+	if err != nil {
+		return err
+	}
+	if subject == nil {
+		return ErrUnauthenticated
+	}
+
+	if a.SuperRole != "" && subject.HasRole(a.SuperRole) {
+		return nil
+	}
+
+	name := resourceName(resource)
+
+	a.mu.RLock()
+	policy, ok := a.policies[name]
+	a.mu.RUnlock()
+
+	if ok {
+		if policy(ctx, subject, action, resource) {
+			return nil
+		}
+		return ErrForbidden
+	}
+
+	if subject.HasPermission(name + ":" + action) {
+		return nil
+	}
+
+	return ErrForbidden
+}
+
+// Can reports whether the user of ctx may perform action on resource. Errors loading
+// the permissions deny.
+func (a *Authorizer) Can(ctx context.Context, action string, resource interface{}) bool {
+	return a.Authorize(ctx, action, resource) == nil
+}
+
+// HasRole reports whether the user of ctx has any of roles.
+func (a *Authorizer) HasRole(ctx context.Context, roles ...string) bool {
+	subject, err := a.Subject(ctx)
+	if err != nil || subject == nil {
+		return false
+	}
+
+	return subject.HasRole(roles...)
+}
+
+// RequirePermission only lets requests through from users with all of permissions,
+// named "resource:action". Guests get 401 and other users 403.
+func (a *Authorizer) RequirePermission(permissions ...string) func(http.Handler) http.Handler {
+	return a.require(func(ctx context.Context) error {
+		for _, permission := range permissions {
+			resource, action, _ := strings.Cut(permission, ":")
+			if err := a.Authorize(ctx, action, resource); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RequireRole only lets requests through from users with any of roles.
+func (a *Authorizer) RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return a.require(func(ctx context.Context) error {
+		subject, err := a.Subject(ctx)
+		if err != nil {
+			return err
+		}
+		if subject == nil {
+			return ErrUnauthenticated
+		}
+		if a.SuperRole != "" && subject.HasRole(a.SuperRole) || subject.HasRole(roles...) {
+			return nil
+		}
+		return ErrForbidden
+	})
+}
+
+func (a *Authorizer) require(check func(ctx context.Context) error) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch err := check(r.Context()); {
+			case err == nil:
+				next.ServeHTTP(w, r)
+			case errors.Is(err, ErrUnauthenticated):
+				support.Fail(a.ErrorHandler, w, r, &support.StatusError{Status: http.StatusUnauthorized, Err: err})
+			case errors.Is(err, ErrForbidden):
+				support.Fail(a.ErrorHandler, w, r, &support.StatusError{Status: http.StatusForbidden, Err: err})
+			default:
+				support.Fail(a.ErrorHandler, w, r, err)
+			}
+		})
+	}
+}
+
+// resourceName names resource: strings are names already, Resources name themselves,
+// and other values are named after their type.
+func resourceName(resource interface{}) string {
+	switch r := resource.(type) {
+	case string:
+		return r
+	case Resource:
+		return r.AuthzResource()
+	case nil:
+		return ""
+	}
+
+	t := reflect.TypeOf(resource)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	return strings.ToLower(t.Name())
+}
@@ -0,0 +1,156 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthorizer_Can(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		user     int
+		action   string
+		resource interface{}
+		want     bool
+	}{
+		{"guest", 0, "read", "posts", false},
+		{"admin can do anything", 1, "delete", "users", true},
+		{"wildcard permission", 2, "publish", "posts", true},
+		{"exact permission", 2, "delete", "comments", true},
+		{"missing permission", 2, "edit", "comments", false},
+		{"reader reads", 3, "read", "posts", true},
+		{"reader cannot edit", 3, "edit", "posts", false},
+		{"policy allows author", 3, "edit", &post{AuthorID: 3}, true},
+		{"policy denies others", 3, "edit", &post{AuthorID: 2}, false},
+		{"policy with permission", 2, "edit", &post{AuthorID: 3}, true},
+	}
+
+	for _, tt := range tests {
+		if got := testAuthorizer.Can(withUser(ctx, tt.user), tt.action, tt.resource); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestAuthorizer_Authorize(t *testing.T) {
+	ctx := context.Background()
+
+	if err := testAuthorizer.Authorize(ctx, "read", "posts"); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("expected ErrUnauthenticated for a guest, got %v", err)
+	}
+
+	if err := testAuthorizer.Authorize(withUser(ctx, 3), "edit", "posts"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("expected ErrForbidden, got %v", err)
+	}
+}
+
+type countingStore struct {
+	Store
+	loads int
+}
+
+func (s *countingStore) Roles(ctx context.Context, userID int) ([]string, error) {
+	s.loads++
+	return s.Store.Roles(ctx, userID)
+}
+
+func TestAuthorizer_Middleware(t *testing.T) {
+	store := &countingStore{Store: testStore}
+	a := &Authorizer{Store: store, UserID: testAuthorizer.UserID}
+
+	handler := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Can(r.Context(), "read", "posts")
+		a.Can(r.Context(), "edit", "posts")
+		a.HasRole(r.Context(), "reader")
+	}))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), r.WithContext(withUser(r.Context(), 3)))
+
+	if store.loads != 1 {
+		t.Errorf("expected the roles to be loaded once per request, got %d", store.loads)
+	}
+}
+
+func TestAuthorizer_RequirePermission(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name        string
+		user        int
+		permissions []string
+		status      int
+	}{
+		{"guest", 0, []string{"posts:read"}, http.StatusUnauthorized},
+		{"allowed", 3, []string{"posts:read"}, http.StatusOK},
+		{"forbidden", 3, []string{"posts:read", "posts:edit"}, http.StatusForbidden},
+		{"admin", 1, []string{"anything:at-all"}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		testAuthorizer.RequirePermission(tt.permissions...)(ok).ServeHTTP(rr, r.WithContext(withUser(r.Context(), tt.user)))
+
+		if rr.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.status, rr.Code)
+		}
+		if tt.status != http.StatusOK && rr.Header().Get("Content-Type") != "application/problem+json" {
+			t.Errorf("%s: expected a problem, got %q", tt.name, rr.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestAuthorizer_ErrorHandler(t *testing.T) {
+	var got error
+	a := &Authorizer{Store: testAuthorizer.Store, UserID: testAuthorizer.UserID,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			got = err
+			w.WriteHeader(http.StatusTeapot)
+		}}
+
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	a.RequireRole("editor")(http.NotFoundHandler()).ServeHTTP(rr, r.WithContext(withUser(r.Context(), 3)))
+
+	if rr.Code != http.StatusTeapot || !errors.Is(got, ErrForbidden) {
+		t.Errorf("expected the error handler to get ErrForbidden, got %d %v", rr.Code, got)
+	}
+}
+
+func TestAuthorizer_RequireRole(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	for user, status := range map[int]int{0: http.StatusUnauthorized, 1: http.StatusOK, 2: http.StatusOK, 3: http.StatusForbidden} {
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		testAuthorizer.RequireRole("editor")(ok).ServeHTTP(rr, r.WithContext(withUser(r.Context(), user)))
+
+		if rr.Code != status {
+			t.Errorf("user %d: expected %d, got %d", user, status, rr.Code)
+		}
+	}
+}
+
+type invoice struct{}
+
+func (invoice) AuthzResource() string { return "billing" }
+
+func TestResourceName(t *testing.T) {
+	tests := map[string]interface{}{
+		"posts":   "posts",
+		"post":    &post{},
+		"billing": invoice{},
+	}
+
+	for want, resource := range tests {
+		if got := resourceName(resource); got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package authz
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+type userKey struct{}
+
+// withUser returns ctx for a request by the user with id.
+func withUser(ctx context.Context, id int) context.Context {
+	return context.WithValue(ctx, userKey{}, id)
+}
+
+var testStore = &MemoryStore{}
+
+// testAuthorizer knows an admin (1), an editor (2) and a reader (3).
+var testAuthorizer = &Authorizer{
+	Store: testStore,
+	UserID: func(ctx context.Context) int {
+		id, _ := ctx.Value(userKey{}).(int)
+		return id
+	},
+	SuperRole: "admin",
+}
+
+type post struct {
+	AuthorID int
+}
+
+func TestMain(m *testing.M) {
+	ctx := context.Background()
+
+	_ = testStore.AssignRole(ctx, 1, "admin")
+	_ = testStore.AssignRole(ctx, 2, "editor")
+	_ = testStore.AssignRole(ctx, 3, "reader")
+	_ = testStore.Grant(ctx, "editor", "posts:*", "comments:delete")
+	_ = testStore.Grant(ctx, "reader", "posts:read")
+
+	testAuthorizer.Policy("post", func(ctx context.Context, s *Subject, action string, resource interface{}) bool {
+		p, ok := resource.(*post)
+		if !ok {
+			return false
+		}
+		return p.AuthorID == s.ID || s.HasPermission("posts:"+action)
+	})
+
+	os.Exit(m.Run())
+}
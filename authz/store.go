@@ -0,0 +1,230 @@
+package authz
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hilsonxhero/napoleon/internal/support"
+)
+
+// MemoryStore keeps roles and permissions in memory, for tests and for applications
+// with a fixed set of roles defined in code.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	userRoles map[int]map[string]bool
+	grants    map[string]map[string]bool
+}
+
+// AssignRole gives the user role.
+func (s *MemoryStore) AssignRole(ctx context.Context, userID int, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.userRoles == nil {
+		s.userRoles = make(map[int]map[string]bool)
+	}
+	if s.userRoles[userID] == nil {
+		s.userRoles[userID] = make(map[string]bool)
+	}
+
+	s.userRoles[userID][role] = true
+
+	return nil
+}
+
+// RemoveRole takes role away from the user.
+func (s *MemoryStore) RemoveRole(ctx context.Context, userID int, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.userRoles[userID], role)
+
+	return nil
+}
+
+// Grant gives role the permissions.
+func (s *MemoryStore) Grant(ctx context.Context, role string, permissions ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.grants == nil {
+		s.grants = make(map[string]map[string]bool)
+	}
+	if s.grants[role] == nil {
+		s.grants[role] = make(map[string]bool)
+	}
+
+	for _, permission := range permissions {
+		s.grants[role][permission] = true
+	}
+
+	return nil
+}
+
+// Revoke takes the permissions away from role.
+func (s *MemoryStore) Revoke(ctx context.Context, role string, permissions ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, permission := range permissions {
+		delete(s.grants[role], permission)
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) Roles(ctx context.Context, userID int) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var roles []string
+	for role := range s.userRoles[userID] {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	return roles, nil
+}
+
+func (s *MemoryStore) Permissions(ctx context.Context, userID int) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var permissions []string
+	for role := range s.userRoles[userID] {
+		for permission := range s.grants[role] {
+			if !seen[permission] {
+				seen[permission] = true
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	sort.Strings(permissions)
+
+	return permissions, nil
+}
+
+// SQLStore keeps roles and permissions in the roles, permissions, role_permissions and
+// user_roles tables created by "napoleon make auth --rbac".
+type SQLStore struct {
+	DB     *sql.DB
+	DBType string
+}
+
+func (s *SQLStore) Roles(ctx context.Context, userID int) ([]string, error) {
+	return s.names(ctx, `select r.name from roles r
+		join user_roles ur on ur.role_id = r.id
+		where ur.user_id = ? order by r.name`, userID)
+}
+
+func (s *SQLStore) Permissions(ctx context.Context, userID int) ([]string, error) {
+	return s.names(ctx, `select distinct p.name from permissions p
+		join role_permissions rp on rp.permission_id = p.id
+		join user_roles ur on ur.role_id = rp.role_id
+		where ur.user_id = ? order by p.name`, userID)
+}
+
+// AssignRole gives the user role, creating the role if needed.
+func (s *SQLStore) AssignRole(ctx context.Context, userID int, role string) error {
+	roleID, err := s.findOrCreate(ctx, "roles", role)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.DB.ExecContext(ctx, s.insertIgnore("insert into user_roles (user_id, role_id) values (?, ?)"), userID, roleID)
+
+	return err
+}
+
+// RemoveRole takes role away from the user.
+func (s *SQLStore) RemoveRole(ctx context.Context, userID int, role string) error {
+	_, err := s.DB.ExecContext(ctx, support.Rebind(s.DBType, `delete from user_roles
+		where user_id = ? and role_id in (select id from roles where name = ?)`), userID, role)
+
+	return err
+}
+
+// Grant gives role the permissions, creating the role and permissions if needed.
+func (s *SQLStore) Grant(ctx context.Context, role string, permissions ...string) error {
+	roleID, err := s.findOrCreate(ctx, "roles", role)
+	if err != nil {
+		return err
+	}
+
+	for _, permission := range permissions {
+		permissionID, err := s.findOrCreate(ctx, "permissions", permission)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.DB.ExecContext(ctx, s.insertIgnore("insert into role_permissions (role_id, permission_id) values (?, ?)"),
+			roleID, permissionID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Revoke takes the permissions away from role.
+func (s *SQLStore) Revoke(ctx context.Context, role string, permissions ...string) error {
+	for _, permission := range permissions {
+		_, err := s.DB.ExecContext(ctx, support.Rebind(s.DBType, `delete from role_permissions
+			where role_id in (select id from roles where name = ?)
+			and permission_id in (select id from permissions where name = ?)`), role, permission)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *SQLStore) names(ctx context.Context, query string, userID int) ([]string, error) {
+	rows, err := s.DB.QueryContext(ctx, support.Rebind(s.DBType, query), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
+
+// findOrCreate returns the id of the row called name in table, which is roles or
+// permissions.
+func (s *SQLStore) findOrCreate(ctx context.Context, table, name string) (int, error) {
+	_, err := s.DB.ExecContext(ctx, s.insertIgnore(fmt.Sprintf("insert into %s (name) values (?)", table)), name)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = s.DB.QueryRowContext(ctx, support.Rebind(s.DBType, fmt.Sprintf("select id from %s where name = ?", table)), name).Scan(&id)
+
+	return id, err
+}
+
+// insertIgnore makes an insert do nothing when it would violate a unique key.
+func (s *SQLStore) insertIgnore(query string) string {
+	switch strings.ToLower(s.DBType) {
+	case "mysql", "mariadb":
+		return strings.Replace(query, "insert into", "insert ignore into", 1)
+	}
+
+	return support.Rebind(s.DBType, query+" on conflict do nothing")
+}
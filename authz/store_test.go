@@ -0,0 +1,47 @@
+package authz
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := &MemoryStore{}
+
+	_ = store.AssignRole(ctx, 5, "editor")
+	_ = store.AssignRole(ctx, 5, "reader")
+	_ = store.Grant(ctx, "editor", "posts:edit", "posts:read")
+	_ = store.Grant(ctx, "reader", "posts:read")
+
+	roles, _ := store.Roles(ctx, 5)
+	if !reflect.DeepEqual(roles, []string{"editor", "reader"}) {
+		t.Errorf("wrong roles: %v", roles)
+	}
+
+	permissions, _ := store.Permissions(ctx, 5)
+	if !reflect.DeepEqual(permissions, []string{"posts:edit", "posts:read"}) {
+		t.Errorf("wrong permissions: %v", permissions)
+	}
+
+	_ = store.Revoke(ctx, "editor", "posts:edit")
+	_ = store.RemoveRole(ctx, 5, "reader")
+
+	permissions, _ = store.Permissions(ctx, 5)
+	if !reflect.DeepEqual(permissions, []string{"posts:read"}) {
+		t.Errorf("wrong permissions after revoking: %v", permissions)
+	}
+}
+
+func TestSQLStore_InsertIgnore(t *testing.T) {
+	query := "insert into roles (name) values (?)"
+
+	if got := (&SQLStore{DBType: "postgres"}).insertIgnore(query); got != "insert into roles (name) values ($1) on conflict do nothing" {
+		t.Errorf("wrong postgres query: %s", got)
+	}
+
+	if got := (&SQLStore{DBType: "mysql"}).insertIgnore(query); got != "insert ignore into roles (name) values (?)" {
+		t.Errorf("wrong mysql query: %s", got)
+	}
+}
@@ -0,0 +1,417 @@
+package napoleon
+
+import (
+	"encoding"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/go-chi/chi/v5"
+)
+
+// errUnsupportedField is returned for fields Bind cannot convert strings into.
+var errUnsupportedField = errors.New("bind: unsupported field type")
+
+var (
+	durationType   = reflect.TypeOf(time.Duration(0))
+	fileHeaderType = reflect.TypeOf((*multipart.FileHeader)(nil))
+	textType       = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// boundField is a field of the struct Bind fills.
+type boundField struct {
+	field reflect.StructField
+	value reflect.Value
+}
+
+// Bind fills dst, a pointer to a struct, from r and validates it. Fields are set, in
+// this order, from their default tag when they are zero, from the body, from the query
+// string by their query tag, from the headers by their header tag, and from the chi url
+// params by their param tag. Json and xml bodies are decoded into dst as they are, with
+// the options of ReadJSON, and bodies without a Content-Type are taken as json;
+// urlencoded and multipart forms set the fields by their form tag, *multipart.FileHeader
+// fields included.
+//
+// Strings are converted into numbers, booleans, durations, slices, pointers and
+// encoding.TextUnmarshalers such as time.Time. Values that do not convert are answered
+// by a 400 *Problem with the fields at fault, and then dst is validated by the valid
+// tags of govalidator, failing with a *Validation.
+func (n *Napoleon) Bind(r *http.Request, dst interface{}, opts ...ReadOption) error {
+	o := readOptions{maxBytes: 1048576} // one megabyte
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return errors.New("bind: dst must be a pointer to a struct")
+	}
+
+	fields := bindFields(v.Elem())
+	invalid := make(map[string]string)
+
+	for _, f := range fields {
+		def, ok := f.field.Tag.Lookup("default")
+		if !ok || !f.value.IsZero() {
+			continue
+		}
+
+		values := []string{def}
+		if f.value.Kind() == reflect.Slice {
+			values = strings.Split(def, ",")
+		}
+
+		if err := setValue(f.value, values); err != nil {
+			return fmt.Errorf("bind: default of %s: %w", f.field.Name, err)
+		}
+	}
+
+	if err := bindBody(r, dst, fields, o, invalid); err != nil {
+		return err
+	}
+
+	query := r.URL.Query()
+	if err := bindValues(fields, "query", func(name string) []string { return query[name] }, invalid); err != nil {
+		return err
+	}
+
+	if err := bindValues(fields, "header", r.Header.Values, invalid); err != nil {
+		return err
+	}
+
+	if err := bindValues(fields, "param", urlParam(r), invalid); err != nil {
+		return err
+	}
+
+	if len(invalid) > 0 {
+		p := NewProblem(http.StatusBadRequest, "The request contains invalid values.")
+		p.Code = "invalid_request"
+		p.Errors = invalid
+		return p
+	}
+
+	return n.validateStruct(r, dst, fields)
+}
+
+func bindBody(r *http.Request, dst interface{}, fields []boundField, o readOptions, invalid map[string]string) error {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return nil
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	body, err := requestBody(nil, r, o)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	switch {
+	case contentType == "", isJSON(contentType):
+		// bodies without a type are taken as json, as ReadJSON does
+		return decodeJSON(body, dst, o)
+
+	case mediaType == MediaXML, mediaType == "text/xml", strings.HasSuffix(mediaType, "+xml"):
+		return decodeXML(body, dst, o)
+
+	case mediaType == "application/x-www-form-urlencoded":
+		r.Body = body
+		if err := r.ParseForm(); err != nil {
+			return formProblem(err, o)
+		}
+		return bindValues(fields, "form", func(name string) []string { return r.PostForm[name] }, invalid)
+
+	case mediaType == "multipart/form-data":
+		r.Body = body
+		if err := r.ParseMultipartForm(o.maxBytes); err != nil {
+			return formProblem(err, o)
+		}
+
+		form := r.MultipartForm
+		bindFiles(fields, form)
+		return bindValues(fields, "form", func(name string) []string { return form.Value[name] }, invalid)
+	}
+
+	p := NewProblem(http.StatusUnsupportedMediaType, fmt.Sprintf("Content-Type %s is not supported.", contentType))
+	p.Code = "unsupported_media_type"
+
+	return p
+}
+
+func decodeXML(body io.Reader, dst interface{}, o readOptions) error {
+	err := xml.NewDecoder(body).Decode(dst)
+	if err == nil {
+		return nil
+	}
+
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return readProblem(err, o)
+	}
+
+	p := NewProblem(http.StatusBadRequest, "Body contains badly-formed XML.")
+	p.Code = "invalid_xml"
+	p.Err = err
+	if errors.Is(err, io.EOF) {
+		p.Detail = "Body must not be empty."
+	}
+
+	return p
+}
+
+func formProblem(err error, o readOptions) error {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return readProblem(err, o)
+	}
+
+	p := NewProblem(http.StatusBadRequest, "Body is not a valid form.")
+	p.Code = "invalid_form"
+	p.Err = err
+
+	return p
+}
+
+// urlParam looks up the chi url params of r.
+func urlParam(r *http.Request) func(name string) []string {
+	rctx := chi.RouteContext(r.Context())
+
+	return func(name string) []string {
+		if rctx == nil {
+			return nil
+		}
+
+		// the innermost router adds its params last
+		for i := len(rctx.URLParams.Keys) - 1; i >= 0; i-- {
+			if rctx.URLParams.Keys[i] == name {
+				return []string{rctx.URLParams.Values[i]}
+			}
+		}
+
+		return nil
+	}
+}
+
+// bindFields lists the exported fields of v, and of the untagged structs within it.
+func bindFields(v reflect.Value) []boundField {
+	var fields []boundField
+
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		if f.Type.Kind() == reflect.Struct && !reflect.PointerTo(f.Type).Implements(textType) && !hasBindTag(f) {
+			fields = append(fields, bindFields(v.Field(i))...)
+			continue
+		}
+
+		fields = append(fields, boundField{field: f, value: v.Field(i)})
+	}
+
+	return fields
+}
+
+func hasBindTag(f reflect.StructField) bool {
+	for _, key := range []string{"json", "xml", "form", "query", "header", "param"} {
+		if _, ok := f.Tag.Lookup(key); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func tagName(f reflect.StructField, key string) string {
+	name := strings.Split(f.Tag.Get(key), ",")[0]
+	if name == "-" {
+		return ""
+	}
+
+	return name
+}
+
+// bindValues sets the fields tagged with key to the values lookup finds for them, and
+// notes the values that do not convert in invalid.
+func bindValues(fields []boundField, key string, lookup func(name string) []string, invalid map[string]string) error {
+	for _, f := range fields {
+		name := tagName(f.field, key)
+		if name == "" {
+			continue
+		}
+
+		values := lookup(name)
+		if len(values) == 0 {
+			continue
+		}
+
+		err := setValue(f.value, values)
+		if errors.Is(err, errUnsupportedField) {
+			return fmt.Errorf("%w: %s is a %s", err, f.field.Name, f.field.Type)
+		}
+		if err != nil {
+			invalid[name] = invalidMessage(f.field.Type)
+		}
+	}
+
+	return nil
+}
+
+// bindFiles sets the *multipart.FileHeader fields, and slices of them, to the uploads
+// of their form tag.
+func bindFiles(fields []boundField, form *multipart.Form) {
+	for _, f := range fields {
+		name := tagName(f.field, "form")
+		files := form.File[name]
+		if name == "" || len(files) == 0 {
+			continue
+		}
+
+		switch f.field.Type {
+		case fileHeaderType:
+			f.value.Set(reflect.ValueOf(files[0]))
+		case reflect.SliceOf(fileHeaderType):
+			f.value.Set(reflect.ValueOf(files))
+		}
+	}
+}
+
+func setValue(v reflect.Value, values []string) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setValue(v.Elem(), values)
+	}
+
+	if v.CanAddr() && v.Addr().Type().Implements(textType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(values[0]))
+	}
+
+	if v.Kind() == reflect.Slice {
+		s := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for i := range values {
+			if err := setValue(s.Index(i), values[i:i+1]); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+
+	s := values[0]
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type() == durationType {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return err
+			}
+			v.SetInt(int64(d))
+			return nil
+		}
+
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+
+	default:
+		return errUnsupportedField
+	}
+
+	return nil
+}
+
+// invalidMessage tells the client what a field of type t takes.
+func invalidMessage(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == durationType:
+		return "Must be a duration, such as 1h30m"
+	case reflect.PointerTo(t).Implements(textType), t.Kind() == reflect.String:
+		return "Is not valid"
+	}
+
+	return fmt.Sprintf("Must be a %s", jsonKind(t.Kind()))
+}
+
+// validateStruct runs the valid tags of dst, and names the fields of failed validations
+// as the client sent them.
+func (n *Napoleon) validateStruct(r *http.Request, dst interface{}, fields []boundField) error {
+	ok, err := govalidator.ValidateStruct(dst)
+	if ok || err == nil {
+		return nil
+	}
+
+	var failed govalidator.Errors
+	if !errors.As(err, &failed) {
+		return err
+	}
+
+	// govalidator names fields by their json tag, or else by their Go name
+	names := make(map[string]string)
+	for _, f := range fields {
+		name := tagName(f.field, "json")
+		if name == "" {
+			name = f.field.Name
+		}
+
+		for _, key := range []string{"json", "form", "query", "param", "header"} {
+			if client := tagName(f.field, key); client != "" {
+				names[name] = client
+				break
+			}
+		}
+	}
+
+	validation := n.Validator(r.Form)
+	for name, message := range govalidator.ErrorsByField(err) {
+		if client, ok := names[name]; ok {
+			name = client
+		}
+		validation.AddError(name, message)
+	}
+
+	return validation
+}
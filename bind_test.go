@@ -0,0 +1,281 @@
+package napoleon
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type bindFilter struct {
+	Status string `query:"status"`
+}
+
+type bindTarget struct {
+	ID      int           `param:"id"`
+	Page    int           `query:"page" default:"1"`
+	Tags    []string      `query:"tag" default:"new,sale"`
+	Sort    string        `json:"sort" query:"sort" default:"name"`
+	Token   string        `header:"X-Token" query:"token"`
+	Name    string        `json:"name" xml:"name" form:"name"`
+	Timeout time.Duration `query:"timeout"`
+	Since   *time.Time    `query:"since"`
+	Filter  bindFilter
+}
+
+func TestSetValue(t *testing.T) {
+	since := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		name          string
+		dst           interface{}
+		values        []string
+		want          interface{}
+		errorExpected bool
+	}{
+		{"string", new(string), []string{"jack"}, "jack", false},
+		{"bool", new(bool), []string{"true"}, true, false},
+		{"bad_bool", new(bool), []string{"yes please"}, false, true},
+		{"int", new(int), []string{"-42"}, -42, false},
+		{"int8_overflow", new(int8), []string{"300"}, int8(0), true},
+		{"uint", new(uint16), []string{"8080"}, uint16(8080), false},
+		{"negative_uint", new(uint), []string{"-1"}, uint(0), true},
+		{"float", new(float64), []string{"1.5"}, 1.5, false},
+		{"duration", new(time.Duration), []string{"1h30m"}, 90 * time.Minute, false},
+		{"bad_duration", new(time.Duration), []string{"90"}, time.Duration(0), true},
+		{"time", new(time.Time), []string{"2024-05-01T12:00:00Z"}, since, false},
+		{"pointer", new(*int), []string{"7"}, func() *int { i := 7; return &i }(), false},
+		{"slice", new([]int), []string{"1", "2", "3"}, []int{1, 2, 3}, false},
+		{"bad_slice", new([]int), []string{"1", "two"}, []int(nil), true},
+		{"unsupported", new(map[string]string), []string{"a"}, map[string]string(nil), true},
+	}
+
+	for _, e := range tests {
+		v := reflect.ValueOf(e.dst).Elem()
+
+		err := setValue(v, e.values)
+		if e.errorExpected {
+			if err == nil {
+				t.Errorf("%s: expected an error", e.name)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %v", e.name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(v.Interface(), e.want) {
+			t.Errorf("%s: expected %v, got %v", e.name, e.want, v.Interface())
+		}
+	}
+
+	if err := setValue(reflect.ValueOf(new(map[string]string)).Elem(), []string{"a"}); !errors.Is(err, errUnsupportedField) {
+		t.Error("expected errUnsupportedField, got", err)
+	}
+}
+
+func TestBind(t *testing.T) {
+	var tests = []struct {
+		name        string
+		target      string
+		contentType string
+		body        string
+		header      http.Header
+		want        bindTarget
+	}{
+		{"defaults", "/items", "", "", nil,
+			bindTarget{Page: 1, Tags: []string{"new", "sale"}, Sort: "name"}},
+		{"query", "/items?page=3&tag=a&tag=b&timeout=2s&status=open", "", "", nil,
+			bindTarget{Page: 3, Tags: []string{"a", "b"}, Sort: "name", Timeout: 2 * time.Second, Filter: bindFilter{Status: "open"}}},
+		{"json", "/items", MediaJSON, `{"name":"Pen","sort":"price"}`, nil,
+			bindTarget{Page: 1, Tags: []string{"new", "sale"}, Sort: "price", Name: "Pen"}},
+		{"json_without_content_type", "/items", "", `{"name":"Pen"}`, nil,
+			bindTarget{Page: 1, Tags: []string{"new", "sale"}, Sort: "name", Name: "Pen"}},
+		{"xml", "/items", "text/xml", `<bindTarget><name>Pen</name></bindTarget>`, nil,
+			bindTarget{Page: 1, Tags: []string{"new", "sale"}, Sort: "name", Name: "Pen"}},
+		{"form", "/items", "application/x-www-form-urlencoded", `name=Pen`, nil,
+			bindTarget{Page: 1, Tags: []string{"new", "sale"}, Sort: "name", Name: "Pen"}},
+		{"query_over_body", "/items?sort=date", MediaJSON, `{"sort":"price"}`, nil,
+			bindTarget{Page: 1, Tags: []string{"new", "sale"}, Sort: "date"}},
+		{"header_over_query", "/items?token=query", "", "", http.Header{"X-Token": {"header"}},
+			bindTarget{Page: 1, Tags: []string{"new", "sale"}, Sort: "name", Token: "header"}},
+	}
+
+	for _, e := range tests {
+		r := httptest.NewRequest("POST", e.target, strings.NewReader(e.body))
+		if e.contentType != "" {
+			r.Header.Set("Content-Type", e.contentType)
+		}
+		for key, values := range e.header {
+			r.Header[key] = values
+		}
+
+		var got bindTarget
+		if err := testApp.Bind(r, &got); err != nil {
+			t.Errorf("%s: %v", e.name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, e.want) {
+			t.Errorf("%s: expected %+v, got %+v", e.name, e.want, got)
+		}
+	}
+}
+
+func TestBind_Since(t *testing.T) {
+	r := httptest.NewRequest("GET", "/items?since=2024-05-01T12:00:00Z", nil)
+
+	var got bindTarget
+	if err := testApp.Bind(r, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Since == nil || !got.Since.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("wrong time %v", got.Since)
+	}
+}
+
+func TestBind_Problems(t *testing.T) {
+	var tests = []struct {
+		name        string
+		target      string
+		contentType string
+		body        string
+		status      int
+		detail      string
+		errors      map[string]string
+	}{
+		{"invalid_values", "/items?page=two&timeout=90&since=may", "", "", http.StatusBadRequest, "The request contains invalid values.",
+			map[string]string{"page": "Must be a number", "timeout": "Must be a duration, such as 1h30m", "since": "Is not valid"}},
+		{"unsupported_type", "/items", "text/plain", "name=Pen", http.StatusUnsupportedMediaType, "Content-Type text/plain is not supported.", nil},
+		{"bad_json", "/items", MediaJSON, `{"name":`, http.StatusBadRequest, "Body contains badly-formed JSON.", nil},
+		{"bad_xml", "/items", MediaXML, `<bindTarget>`, http.StatusBadRequest, "Body contains badly-formed XML.", nil},
+	}
+
+	for _, e := range tests {
+		r := httptest.NewRequest("POST", e.target, strings.NewReader(e.body))
+		if e.contentType != "" {
+			r.Header.Set("Content-Type", e.contentType)
+		}
+
+		var got bindTarget
+		err := testApp.Bind(r, &got)
+
+		var p *Problem
+		if !errors.As(err, &p) {
+			t.Errorf("%s: expected a problem, got %v", e.name, err)
+			continue
+		}
+
+		if p.Status != e.status || p.Detail != e.detail {
+			t.Errorf("%s: expected %d %q, got %d %q", e.name, e.status, e.detail, p.Status, p.Detail)
+		}
+		if !reflect.DeepEqual(p.Errors, e.errors) {
+			t.Errorf("%s: expected errors %v, got %v", e.name, e.errors, p.Errors)
+		}
+	}
+}
+
+func TestBind_URLParams(t *testing.T) {
+	var got bindTarget
+
+	mux := chi.NewRouter()
+	mux.Route("/teams/{id}", func(r chi.Router) {
+		r.Get("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+			if err := testApp.Bind(r, &got); err != nil {
+				t.Error(err)
+			}
+		})
+	})
+
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/teams/3/items/42", nil))
+
+	// the param of the innermost route wins
+	if got.ID != 42 {
+		t.Errorf("expected id 42, got %d", got.ID)
+	}
+
+	// requests outside of chi have no params
+	got = bindTarget{}
+	if err := testApp.Bind(httptest.NewRequest("GET", "/items/42", nil), &got); err != nil || got.ID != 0 {
+		t.Errorf("expected no id, got %d, %v", got.ID, err)
+	}
+}
+
+func TestBind_Files(t *testing.T) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	_ = mw.WriteField("title", "Report")
+	for _, name := range []string{"avatar.png", "a.pdf", "b.pdf"} {
+		field := "docs"
+		if name == "avatar.png" {
+			field = "avatar"
+		}
+		fw, err := mw.CreateFormFile(field, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = fw.Write([]byte("content of " + name))
+	}
+	_ = mw.Close()
+
+	r := httptest.NewRequest("POST", "/upload", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	var got struct {
+		Title  string                  `form:"title"`
+		Avatar *multipart.FileHeader   `form:"avatar"`
+		Docs   []*multipart.FileHeader `form:"docs"`
+	}
+	if err := testApp.Bind(r, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Title != "Report" {
+		t.Errorf("expected title Report, got %q", got.Title)
+	}
+	if got.Avatar == nil || got.Avatar.Filename != "avatar.png" {
+		t.Errorf("wrong avatar %+v", got.Avatar)
+	}
+	if len(got.Docs) != 2 || got.Docs[0].Filename != "a.pdf" || got.Docs[1].Filename != "b.pdf" {
+		t.Errorf("wrong docs %+v", got.Docs)
+	}
+}
+
+func TestBind_Validation(t *testing.T) {
+	form := url.Values{"email_address": {"not an email"}, "name": {""}}
+	r := httptest.NewRequest("POST", "/signup?nick=x", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var got struct {
+		Email string `form:"email_address" valid:"email"`
+		Name  string `json:"full_name" form:"name" valid:"required"`
+		Nick  string `query:"nick" valid:"length(2|20)"`
+	}
+	err := testApp.Bind(r, &got)
+
+	var validation *Validation
+	if !errors.As(err, &validation) {
+		t.Fatal("expected a failed validation, got", err)
+	}
+
+	// fields are named as the client sent them, not as govalidator knows them
+	for _, field := range []string{"email_address", "full_name", "nick"} {
+		if _, ok := validation.Errors[field]; !ok {
+			t.Errorf("no error for %s in %v", field, validation.Errors)
+		}
+	}
+	if len(validation.Errors) != 3 {
+		t.Errorf("expected 3 errors, got %v", validation.Errors)
+	}
+}
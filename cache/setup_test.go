@@ -40,12 +40,12 @@ func TestMain(m *testing.M) {
 
 	// create a badger database
 	if _, err := os.Stat("./testdata/tmp"); os.IsNotExist(err) {
-		err := os.Mkdir("./testdata/tmp", 0755)
+		err := os.MkdirAll("./testdata/tmp", 0755)
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
-	err = os.Mkdir("./testdata/tmp/badger", 0755)
+	err = os.MkdirAll("./testdata/tmp/badger", 0755)
 	if err != nil {
 		log.Fatal(err)
 	}
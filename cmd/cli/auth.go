@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"log"
 	"time"
+
+	"github.com/fatih/color"
 )
 
-func doAuth() error {
+func doAuth(rbac bool) error {
 	dbType := nap.DB.DataType
 	fileName := fmt.Sprintf("%d_create_auth_tables", time.Now().UnixMicro())
 	upFile := nap.RootPath + "/migrations/" + fileName + ".up.sql"
@@ -19,15 +21,24 @@ func doAuth() error {
 		exitGracefully(err)
 	}
 
-	err = copyDataToFile([]byte("drop table if exists users cascade; drop table if exists tokens cascade; drop table if exists remember_tokens;"), downFile)
+	err = copyDataToFile([]byte("drop table if exists user_recovery_codes; drop table if exists user_two_factor; drop table if exists users cascade; drop table if exists tokens cascade; drop table if exists remember_tokens;"), downFile)
 
 	if err != nil {
 		exitGracefully(err)
 	}
 
-	err = doMigrate("up", "")
-	if err != nil {
-		exitGracefully(err)
+	if rbac {
+		fileName = fmt.Sprintf("%d_create_rbac_tables", time.Now().UnixMicro())
+
+		err = copyFilefromTemplate("templates/migrations/auth_rbac."+dbType+".sql", nap.RootPath+"/migrations/"+fileName+".up.sql")
+		if err != nil {
+			exitGracefully(err)
+		}
+
+		err = copyDataToFile([]byte("drop table if exists user_roles; drop table if exists role_permissions; drop table if exists permissions; drop table if exists roles;"), nap.RootPath+"/migrations/"+fileName+".down.sql")
+		if err != nil {
+			exitGracefully(err)
+		}
 	}
 
 	err = copyFilefromTemplate("templates/data/user.go.txt", nap.RootPath+"/data/user.go")
@@ -40,6 +51,86 @@ func doAuth() error {
 		exitGracefully(err)
 	}
 
+	err = copyFilefromTemplate("templates/middleware/auth.go.txt", nap.RootPath+"/middlewares/auth.go")
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	err = copyFilefromTemplate("templates/handlers/auth-handlers.go.txt", nap.RootPath+"/handlers/auth-handlers.go")
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	err = copyFilefromTemplate("templates/views/login.jet", nap.RootPath+"/views/login.jet")
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	err = copyFilefromTemplate("templates/views/forgot.jet", nap.RootPath+"/views/forgot.jet")
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	err = copyFilefromTemplate("templates/views/reset-password.jet", nap.RootPath+"/views/reset-password.jet")
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	err = copyFilefromTemplate("templates/views/two-factor.jet", nap.RootPath+"/views/two-factor.jet")
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	err = copyFilefromTemplate("templates/views/two-factor-setup.jet", nap.RootPath+"/views/two-factor-setup.jet")
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	err = nap.CreateDirIfNotExist(nap.RootPath + "/views/mail")
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	for _, mail := range []string{"password-reset.html.jet", "password-reset.plain.jet", "verify-email.html.jet", "verify-email.plain.jet"} {
+		err = copyFilefromTemplate("templates/mailer/"+mail, nap.RootPath+"/views/mail/"+mail)
+		if err != nil {
+			exitGracefully(err)
+		}
+	}
+
+	// the files go first, so that one already there stops before the database is changed
+	err = doMigrate("up", "")
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	color.Yellow("  - users, tokens and remember_tokens migrations created and executed")
+	if rbac {
+		color.Yellow("  - roles, permissions, role_permissions and user_roles migrations created and executed")
+	}
+	color.Yellow("  - user and token models created")
+	color.Yellow("  - auth middleware, login, password reset and email verification handlers, views and emails created")
+	color.Yellow("")
+	color.Yellow("Don't forget to add the user and token models to data/models.go, and the login routes:")
+	color.Yellow(`  a.App.Routes.With(a.App.Auth.Guest).Get("/users/login", a.App.Handle(a.Handlers.UserLogin))`)
+	color.Yellow(`  a.App.Routes.Post("/users/login", a.App.Handle(a.Handlers.PostUserLogin))`)
+	color.Yellow(`  a.App.Routes.Get("/users/logout", a.App.Handle(a.Handlers.Logout))`)
+	color.Yellow(`  a.App.Routes.Get("/users/forgot-password", a.App.Handle(a.Handlers.Forgot))`)
+	color.Yellow(`  a.App.Routes.Post("/users/forgot-password", a.App.Handle(a.Handlers.PostForgot))`)
+	color.Yellow(`  a.App.Routes.Get("/users/reset-password", a.App.Handle(a.Handlers.ResetPasswordForm))`)
+	color.Yellow(`  a.App.Routes.Post("/users/reset-password", a.App.Handle(a.Handlers.PostResetPassword))`)
+	color.Yellow(`  a.App.Routes.Get("/users/verify-email", a.App.Handle(a.Handlers.VerifyEmail))`)
+	color.Yellow(`  a.App.Routes.With(a.App.Auth.TwoFactorChallenge).Get("/users/two-factor", a.App.Handle(a.Handlers.TwoFactor))`)
+	color.Yellow(`  a.App.Routes.With(a.App.Auth.TwoFactorChallenge).Post("/users/two-factor", a.App.Handle(a.Handlers.PostTwoFactor))`)
+	color.Yellow(`  a.App.Routes.With(a.App.Auth.Auth).Get("/users/two-factor/setup", a.App.Handle(a.Handlers.TwoFactorSetup))`)
+	color.Yellow(`  a.App.Routes.With(a.App.Auth.Auth).Post("/users/two-factor/setup", a.App.Handle(a.Handlers.PostTwoFactorSetup))`)
+
+	if rbac {
+		color.Yellow("")
+		color.Yellow("Protect routes with permissions, and set AUTHZ_SUPER_ROLE to a role that may do anything:")
+		color.Yellow(`  a.App.Routes.With(a.App.Authz.RequirePermission("posts:edit")).Post("/posts/{id}", a.App.Handle(a.Handlers.UpdatePost))`)
+	}
+
 	return nil
 
 }
@@ -65,9 +65,21 @@ func showHelp() {
 	migrate down          - reverses the most recent migration
 	migrate reset         - runs all down migrations in reverse order, and then all up migrations
 	make migration <name> - creates two new up and down migrations in the migrations folder
-	make auth             - creates and runs migrations for authentication tables, and creates models and middleware
-	make handler <name>   - creates a stub handler in the handlers directory
+	make auth             - creates and runs migrations for authentication tables, and creates models, middleware, login handlers and views
+	make auth --rbac      - also creates and runs migrations for the roles and permissions tables
+	make handler <name>   - creates a stub handler, returning its error, and its view
 	make model <name>     - creates a new model in the models directory
+	make queue            - creates and runs migrations for the jobs and failed_jobs tables
+	make oauth            - creates and runs the migration for the user_identities table, and creates oauth handlers
+	make jwt              - creates and runs the migration for the refresh_tokens table
+	queue work [queues]   - processes jobs, optionally from a comma separated list of queues
+	queue failed          - lists jobs that ran out of attempts
+	queue retry <id|all>  - pushes failed jobs back onto their queue
+	queue forget <id>     - deletes a failed job
+	schedule list         - lists scheduled jobs with their next and last run
+	schedule run <name>   - runs a scheduled job now
+	token create <user_id> [abilities] [ttl] - creates an api token, e.g. token create 1 posts:read,posts:write 720h
+	token revoke <id>     - deletes an api token
 	
 	`)
 }
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+func doJWT() error {
+	dbType := nap.DB.DataType
+
+	if dbType == "mariadb" {
+		dbType = "mysql"
+	}
+
+	if dbType == "postgresql" {
+		dbType = "postgres"
+	}
+
+	fileName := fmt.Sprintf("%d_create_refresh_tokens_table", time.Now().UnixMicro())
+
+	upFile := nap.RootPath + "/migrations/" + fileName + "." + dbType + ".up.sql"
+	downFile := nap.RootPath + "/migrations/" + fileName + "." + dbType + ".down.sql"
+
+	err := copyFilefromTemplate("templates/migrations/"+dbType+"_jwt.sql", upFile)
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	err = copyDataToFile([]byte("drop table if exists refresh_tokens;"), downFile)
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	err = doMigrate("up", "")
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	color.Yellow("  - refresh_tokens migration created and executed")
+	color.Yellow("")
+	color.Yellow("Issue tokens with a.App.JWT.Issue and a.App.JWT.Refresh, and protect api routes with:")
+	color.Yellow(`  a.App.Routes.With(a.App.JWT.Authenticate).Get("/api/me", a.Handlers.Me)`)
+
+	return nil
+}
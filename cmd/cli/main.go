@@ -48,6 +48,24 @@ func main() {
 			exitGracefully(err)
 		}
 
+	case "queue":
+		err = doQueue(arg2, arg3)
+		if err != nil {
+			exitGracefully(err)
+		}
+
+	case "schedule":
+		err = doSchedule(arg2, arg3)
+		if err != nil {
+			exitGracefully(err)
+		}
+
+	case "token":
+		err = doToken(arg2)
+		if err != nil {
+			exitGracefully(err)
+		}
+
 	default:
 		showHelp()
 	}
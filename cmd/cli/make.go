@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"io/ioutil"
+	"os"
 	"strings"
 	"time"
 
@@ -42,7 +43,7 @@ func doMake(arg2, arg3 string) error {
 
 	case "auth":
 
-		err := doAuth()
+		err := doAuth(arg3 == "--rbac")
 		if err != nil {
 			exitGracefully(err)
 		}
@@ -63,12 +64,33 @@ func doMake(arg2, arg3 string) error {
 
 		handler := string(data)
 		handler = strings.ReplaceAll(handler, "$HANDLERNAME$", strcase.ToCamel(arg3))
+		handler = strings.ReplaceAll(handler, "$VIEWNAME$", strcase.ToKebab(arg3))
 
 		err = ioutil.WriteFile(fileName, []byte(handler), 0644)
 		if err != nil {
 			exitGracefully(err)
 		}
 
+		// the view the handler renders, for the engine in RENDERER
+		ext := ".jet"
+		if os.Getenv("RENDERER") == "go" {
+			ext = ".page.tmpl"
+		}
+
+		viewName := nap.RootPath + "/views/" + strcase.ToKebab(arg3) + ext
+		if !fileExists(viewName) {
+			data, err := templateFS.ReadFile("templates/views/view" + ext)
+			if err != nil {
+				exitGracefully(err)
+			}
+
+			view := strings.ReplaceAll(string(data), "$TITLE$", strcase.ToDelimited(arg3, ' '))
+			err = copyDataToFile([]byte(view), viewName)
+			if err != nil {
+				exitGracefully(err)
+			}
+		}
+
 	case "model":
 		if arg3 == "" {
 			exitGracefully(errors.New("you must give the model a name"))
@@ -112,6 +134,24 @@ func doMake(arg2, arg3 string) error {
 		if err != nil {
 			exitGracefully(err)
 		}
+
+	case "queue":
+		err := doQueueTables()
+		if err != nil {
+			exitGracefully(err)
+		}
+
+	case "oauth":
+		err := doOAuth()
+		if err != nil {
+			exitGracefully(err)
+		}
+
+	case "jwt":
+		err := doJWT()
+		if err != nil {
+			exitGracefully(err)
+		}
 	}
 
 	return nil
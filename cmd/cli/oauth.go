@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+func doOAuth() error {
+	dbType := nap.DB.DataType
+
+	if dbType == "mariadb" {
+		dbType = "mysql"
+	}
+
+	if dbType == "postgresql" {
+		dbType = "postgres"
+	}
+
+	fileName := fmt.Sprintf("%d_create_user_identities_table", time.Now().UnixMicro())
+
+	upFile := nap.RootPath + "/migrations/" + fileName + "." + dbType + ".up.sql"
+	downFile := nap.RootPath + "/migrations/" + fileName + "." + dbType + ".down.sql"
+
+	err := copyFilefromTemplate("templates/migrations/"+dbType+"_oauth.sql", upFile)
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	err = copyDataToFile([]byte("drop table if exists user_identities;"), downFile)
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	err = doMigrate("up", "")
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	err = copyFilefromTemplate("templates/handlers/oauth-handlers.go.txt", nap.RootPath+"/handlers/oauth-handlers.go")
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	color.Yellow("  - user_identities migration created and executed")
+	color.Yellow("  - oauth handlers created")
+	color.Yellow("")
+	color.Yellow("Configure the providers in .env, for example:")
+	color.Yellow("  OAUTH_PROVIDERS=company")
+	color.Yellow("  OAUTH_COMPANY_CLIENT_ID=...")
+	color.Yellow("  OAUTH_COMPANY_CLIENT_SECRET=...")
+	color.Yellow("  OAUTH_COMPANY_ISSUER=https://login.example.com")
+	color.Yellow("")
+	color.Yellow("and add the routes:")
+	color.Yellow(`  a.App.Routes.With(a.App.Auth.Guest).Get("/auth/{provider}", a.App.Handle(a.Handlers.OAuthRedirect))`)
+	color.Yellow(`  a.App.Routes.Get("/auth/{provider}/callback", a.App.Handle(a.Handlers.OAuthCallback))`)
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+func doQueue(arg2, arg3 string) error {
+	switch arg2 {
+	case "work", "failed", "retry", "forget":
+	default:
+		return errors.New("queue requires a subcommand: (work|failed|retry|forget)")
+	}
+
+	// job handlers live in the application, so the command is run there
+	return runApp("queue", arg2, arg3)
+}
+
+func doQueueTables() error {
+	dbType := nap.DB.DataType
+
+	if dbType == "mariadb" {
+		dbType = "mysql"
+	}
+
+	if dbType == "postgresql" {
+		dbType = "postgres"
+	}
+
+	fileName := fmt.Sprintf("%d_create_queue_tables", time.Now().UnixMicro())
+
+	upFile := nap.RootPath + "/migrations/" + fileName + "." + dbType + ".up.sql"
+	downFile := nap.RootPath + "/migrations/" + fileName + "." + dbType + ".down.sql"
+
+	err := copyFilefromTemplate("templates/migrations/"+dbType+"_queue.sql", upFile)
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	err = copyDataToFile([]byte("drop table if exists failed_jobs; drop table if exists jobs;"), downFile)
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	err = doMigrate("up", "")
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	return nil
+}
+
+// runApp runs the application in the current directory with args, so that framework
+// commands can use what the application registers, such as job handlers.
+func runApp(args ...string) error {
+	cmdArgs := []string{"run", "."}
+	for _, arg := range args {
+		if arg != "" {
+			cmdArgs = append(cmdArgs, arg)
+		}
+	}
+
+	cmd := exec.Command("go", cmdArgs...)
+	cmd.Dir = nap.RootPath
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
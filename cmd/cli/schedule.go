@@ -0,0 +1,14 @@
+package main
+
+import "errors"
+
+func doSchedule(arg2, arg3 string) error {
+	switch arg2 {
+	case "list", "run":
+	default:
+		return errors.New("schedule requires a subcommand: (list|run)")
+	}
+
+	// scheduled jobs are registered by the application, so the command is run there
+	return runApp("schedule", arg2, arg3)
+}
@@ -26,7 +26,7 @@ func doSessionTable() error {
 		exitGracefully(err)
 	}
 
-	err = copyDataToFile([]byte("drop table sessions"), downFile)
+	err = copyDataToFile([]byte("drop table if exists user_sessions;\ndrop table sessions;"), downFile)
 	if err != nil {
 		exitGracefully(err)
 	}
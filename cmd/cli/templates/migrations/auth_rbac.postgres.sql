@@ -0,0 +1,29 @@
+CREATE TABLE roles (
+    id SERIAL PRIMARY KEY,
+    name character varying(255) NOT NULL UNIQUE,
+    description character varying(255) NOT NULL DEFAULT '',
+    created_at timestamp without time zone NOT NULL DEFAULT now(),
+    updated_at timestamp without time zone NOT NULL DEFAULT now()
+);
+
+CREATE TABLE permissions (
+    id SERIAL PRIMARY KEY,
+    name character varying(255) NOT NULL UNIQUE,
+    description character varying(255) NOT NULL DEFAULT '',
+    created_at timestamp without time zone NOT NULL DEFAULT now(),
+    updated_at timestamp without time zone NOT NULL DEFAULT now()
+);
+
+CREATE TABLE role_permissions (
+    role_id integer NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    permission_id integer NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+CREATE TABLE user_roles (
+    user_id integer NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role_id integer NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, role_id)
+);
+
+CREATE INDEX user_roles_role_id_idx ON user_roles (role_id);
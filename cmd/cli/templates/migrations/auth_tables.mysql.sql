@@ -6,7 +6,8 @@ CREATE TABLE `users` (
     `last_name` varchar(255) CHARACTER SET utf8 COLLATE utf8_unicode_ci NOT NULL,
     `user_active` int(11) NOT NULL,
     `email` varchar(255) CHARACTER SET utf8 COLLATE utf8_unicode_ci NOT NULL,
-    `password` char(60) CHARACTER SET utf8 COLLATE utf8_unicode_ci NOT NULL,
+    `password` varchar(255) CHARACTER SET utf8 COLLATE utf8_unicode_ci NOT NULL,
+    `email_verified_at` timestamp NULL DEFAULT NULL,
     `created_at` timestamp NULL DEFAULT NULL,
     `updated_at` timestamp NULL DEFAULT NULL,
     PRIMARY KEY (`id`),
@@ -33,13 +34,39 @@ drop table if exists tokens cascade;
 CREATE TABLE `tokens` (
     `id` int(11) NOT NULL AUTO_INCREMENT,
     `user_id` int(11) unsigned NOT NULL,
-    `name` varchar(255) NOT NULL,
+    `first_name` varchar(255) NOT NULL,
     `email` varchar(255) NOT NULL,
     `token` varchar(255) NOT NULL,
     `token_hash` varbinary(255) DEFAULT NULL,
+    `abilities` varchar(1024) NOT NULL DEFAULT '',
     `created_at` datetime NOT NULL DEFAULT current_timestamp(),
     `updated_at` datetime NOT NULL DEFAULT current_timestamp(),
     `expiry` datetime NOT NULL,
     PRIMARY KEY (`id`),
+    KEY `tokens_token_hash_index` (`token_hash`),
     FOREIGN KEY (user_id) REFERENCES users(id) ON UPDATE cascade ON DELETE cascade
-) ENGINE=InnoDB AUTO_INCREMENT=30 DEFAULT CHARSET=utf8mb4;
\ No newline at end of file
+) ENGINE=InnoDB AUTO_INCREMENT=30 DEFAULT CHARSET=utf8mb4;
+drop table if exists user_recovery_codes cascade;
+drop table if exists user_two_factor cascade;
+
+CREATE TABLE `user_two_factor` (
+    `user_id` int(10) unsigned NOT NULL,
+    `secret` varchar(255) NOT NULL,
+    `confirmed_at` timestamp NULL DEFAULT NULL,
+    `last_step` bigint NOT NULL DEFAULT 0,
+    `created_at` timestamp NOT NULL DEFAULT current_timestamp(),
+    `updated_at` timestamp NOT NULL DEFAULT current_timestamp() ON UPDATE current_timestamp(),
+    PRIMARY KEY (`user_id`),
+    CONSTRAINT `user_two_factor_user_id_foreign` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `user_recovery_codes` (
+    `id` int(10) unsigned NOT NULL AUTO_INCREMENT,
+    `user_id` int(10) unsigned NOT NULL,
+    `code_hash` char(64) NOT NULL,
+    `used_at` timestamp NULL DEFAULT NULL,
+    `created_at` timestamp NOT NULL DEFAULT current_timestamp(),
+    PRIMARY KEY (`id`),
+    KEY `user_recovery_codes_user_id_index` (`user_id`),
+    CONSTRAINT `user_recovery_codes_user_id_foreign` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -14,7 +14,8 @@ CREATE TABLE users (
     last_name character varying(255) NOT NULL,
     user_active integer NOT NULL DEFAULT 0,
     email character varying(255) NOT NULL UNIQUE,
-    password character varying(60) NOT NULL,
+    password character varying(255) NOT NULL,
+    email_verified_at timestamp without time zone,
     created_at timestamp without time zone NOT NULL DEFAULT now(),
     updated_at timestamp without time zone NOT NULL DEFAULT now()
 );
@@ -48,12 +49,41 @@ CREATE TABLE tokens (
     email character varying(255) NOT NULL,
     token character varying(255) NOT NULL,
     token_hash bytea NOT NULL,
+    abilities character varying(1024) NOT NULL DEFAULT '',
     created_at timestamp without time zone NOT NULL DEFAULT now(),
     updated_at timestamp without time zone NOT NULL DEFAULT now(),
     expiry timestamp without time zone NOT NULL
 );
 
+CREATE INDEX tokens_token_hash_idx ON tokens (token_hash);
+
 CREATE TRIGGER set_timestamp
     BEFORE UPDATE ON tokens
     FOR EACH ROW
-    EXECUTE PROCEDURE trigger_set_timestamp();
\ No newline at end of file
+    EXECUTE PROCEDURE trigger_set_timestamp();
+drop table if exists user_recovery_codes;
+drop table if exists user_two_factor;
+
+CREATE TABLE user_two_factor (
+    user_id integer PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    secret character varying(255) NOT NULL,
+    confirmed_at timestamp without time zone,
+    last_step bigint NOT NULL DEFAULT 0,
+    created_at timestamp without time zone NOT NULL DEFAULT now(),
+    updated_at timestamp without time zone NOT NULL DEFAULT now()
+);
+
+CREATE TRIGGER set_timestamp
+    BEFORE UPDATE ON user_two_factor
+    FOR EACH ROW
+    EXECUTE PROCEDURE trigger_set_timestamp();
+
+CREATE TABLE user_recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id integer NOT NULL REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    code_hash character(64) NOT NULL,
+    used_at timestamp without time zone,
+    created_at timestamp without time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX user_recovery_codes_user_id_idx ON user_recovery_codes (user_id);
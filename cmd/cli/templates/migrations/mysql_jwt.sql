@@ -0,0 +1,14 @@
+CREATE TABLE refresh_tokens (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	user_id INT UNSIGNED NOT NULL,
+	family VARCHAR(64) NOT NULL,
+	token_hash CHAR(64) NOT NULL,
+	abilities TEXT NOT NULL,
+	expires_at TIMESTAMP(6) NOT NULL,
+	used_at TIMESTAMP(6) NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	CONSTRAINT refresh_tokens_user_id_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX refresh_tokens_token_hash_idx ON refresh_tokens (token_hash);
+CREATE INDEX refresh_tokens_family_idx ON refresh_tokens (family);
@@ -0,0 +1,13 @@
+CREATE TABLE user_identities (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	user_id INT UNSIGNED NOT NULL,
+	provider VARCHAR(64) NOT NULL,
+	subject VARCHAR(255) NOT NULL,
+	email VARCHAR(255) NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	CONSTRAINT user_identities_user_id_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX user_identities_provider_subject_idx ON user_identities (provider, subject);
+CREATE INDEX user_identities_user_id_idx ON user_identities (user_id);
@@ -0,0 +1,24 @@
+CREATE TABLE jobs (
+	id CHAR(32) PRIMARY KEY,
+	queue VARCHAR(255) NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	payload LONGTEXT NOT NULL,
+	attempts INT UNSIGNED NOT NULL DEFAULT 0,
+	max_attempts INT UNSIGNED NOT NULL DEFAULT 3,
+	available_at BIGINT UNSIGNED NOT NULL,
+	reserved_at BIGINT UNSIGNED NULL,
+	created_at BIGINT UNSIGNED NOT NULL,
+	INDEX jobs_queue_available_at_idx (queue, available_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE failed_jobs (
+	id CHAR(32) PRIMARY KEY,
+	queue VARCHAR(255) NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	payload LONGTEXT NOT NULL,
+	attempts INT UNSIGNED NOT NULL,
+	max_attempts INT UNSIGNED NOT NULL,
+	error TEXT NOT NULL,
+	created_at BIGINT UNSIGNED NOT NULL,
+	failed_at BIGINT UNSIGNED NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
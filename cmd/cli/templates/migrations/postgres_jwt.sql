@@ -0,0 +1,13 @@
+CREATE TABLE refresh_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id integer NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family character varying(64) NOT NULL,
+    token_hash character(64) NOT NULL UNIQUE,
+    abilities text NOT NULL DEFAULT '',
+    expires_at timestamp without time zone NOT NULL,
+    used_at timestamp without time zone,
+    created_at timestamp without time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX refresh_tokens_family_idx ON refresh_tokens (family);
+CREATE INDEX refresh_tokens_user_id_idx ON refresh_tokens (user_id);
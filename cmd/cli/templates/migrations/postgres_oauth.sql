@@ -0,0 +1,12 @@
+CREATE TABLE user_identities (
+    id SERIAL PRIMARY KEY,
+    user_id integer NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider character varying(64) NOT NULL,
+    subject character varying(255) NOT NULL,
+    email character varying(255) NOT NULL DEFAULT '',
+    created_at timestamp without time zone NOT NULL DEFAULT now(),
+    updated_at timestamp without time zone NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX user_identities_provider_subject_idx ON user_identities (provider, subject);
+CREATE INDEX user_identities_user_id_idx ON user_identities (user_id);
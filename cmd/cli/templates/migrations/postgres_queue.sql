@@ -0,0 +1,25 @@
+CREATE TABLE jobs (
+	id CHAR(32) PRIMARY KEY,
+	queue VARCHAR(255) NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	payload TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	max_attempts INTEGER NOT NULL DEFAULT 3,
+	available_at BIGINT NOT NULL,
+	reserved_at BIGINT,
+	created_at BIGINT NOT NULL
+);
+
+CREATE INDEX jobs_queue_available_at_idx ON jobs (queue, available_at);
+
+CREATE TABLE failed_jobs (
+	id CHAR(32) PRIMARY KEY,
+	queue VARCHAR(255) NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	payload TEXT NOT NULL,
+	attempts INTEGER NOT NULL,
+	max_attempts INTEGER NOT NULL,
+	error TEXT NOT NULL,
+	created_at BIGINT NOT NULL,
+	failed_at BIGINT NOT NULL
+);
@@ -4,4 +4,16 @@ CREATE TABLE sessions (
 	expiry TIMESTAMPTZ NOT NULL
 );
 
-CREATE INDEX sessions_expiry_idx ON sessions (expiry);
\ No newline at end of file
+CREATE INDEX sessions_expiry_idx ON sessions (expiry);
+
+CREATE TABLE user_sessions (
+	token TEXT PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	ip VARCHAR(45) NOT NULL DEFAULT '',
+	user_agent VARCHAR(512) NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL,
+	last_seen TIMESTAMPTZ NOT NULL,
+	expiry TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX user_sessions_user_id_idx ON user_sessions (user_id);
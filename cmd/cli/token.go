@@ -0,0 +1,18 @@
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+func doToken(arg2 string) error {
+	switch arg2 {
+	case "create", "revoke":
+	default:
+		return errors.New("token requires a subcommand: (create|revoke)")
+	}
+
+	// tokens are created through the application, so it uses the configured hasher and
+	// database; pass along every argument, since create takes up to four
+	return runApp(append([]string{"token"}, os.Args[2:]...)...)
+}
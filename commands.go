@@ -0,0 +1,224 @@
+package napoleon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hilsonxhero/napoleon/queue"
+)
+
+// runCommand runs a framework command passed on the command line of the application,
+// which is how the napoleon cli reaches code that only the application knows about, like
+// job handlers. It reports whether args held a command, in which case the web server is
+// not started.
+func (n *Napoleon) runCommand(args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+
+	switch args[0] {
+	case "queue":
+		return true, n.queueCommand(args[1:])
+	case "schedule":
+		return true, n.scheduleCommand(args[1:])
+	case "token":
+		return true, n.tokenCommand(args[1:])
+	}
+
+	return false, nil
+}
+
+func (n *Napoleon) queueCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New("queue requires a subcommand: (work|failed|retry|forget)")
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "work":
+		concurrency, err := strconv.Atoi(os.Getenv("QUEUE_CONCURRENCY"))
+		if err != nil {
+			concurrency = 1
+		}
+
+		var queues []string
+		if len(args) > 1 {
+			queues = splitList(args[1])
+		}
+
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		n.InfoLog.Printf("Processing jobs with %d worker(s)", concurrency)
+		err = n.Queue.Work(ctx, queue.WorkerOptions{
+			Queues:      queues,
+			Concurrency: concurrency,
+		})
+		if err != nil {
+			return err
+		}
+		n.InfoLog.Println("Workers stopped")
+
+	case "failed":
+		jobs, err := n.Queue.Failed(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tQUEUE\tJOB\tATTEMPTS\tFAILED AT\tERROR")
+		for _, job := range jobs {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", job.ID, job.Queue, job.Name, job.Attempts,
+				job.FailedAt.Format("2006-01-02 15:04:05"), strings.SplitN(job.LastError, "\n", 2)[0])
+		}
+		return w.Flush()
+
+	case "retry":
+		if len(args) < 2 {
+			return errors.New("you must give the id of the job to retry, or all")
+		}
+
+		ids := []string{args[1]}
+		if args[1] == "all" {
+			jobs, err := n.Queue.Failed(ctx)
+			if err != nil {
+				return err
+			}
+
+			ids = nil
+			for _, job := range jobs {
+				ids = append(ids, job.ID)
+			}
+		}
+
+		for _, id := range ids {
+			if err := n.Queue.Retry(ctx, id); err != nil {
+				return fmt.Errorf("job %s: %w", id, err)
+			}
+			n.InfoLog.Printf("Job %s pushed back onto the queue", id)
+		}
+
+	case "forget":
+		if len(args) < 2 {
+			return errors.New("you must give the id of the job to forget")
+		}
+
+		return n.Queue.Forget(ctx, args[1])
+
+	default:
+		return fmt.Errorf("unknown queue command %q", args[0])
+	}
+
+	return nil
+}
+
+func (n *Napoleon) scheduleCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New("schedule requires a subcommand: (list|run)")
+	}
+
+	switch args[0] {
+	case "list":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSCHEDULE\tNEXT RUN\tLAST RUN\tDURATION\tERROR")
+		for _, job := range n.Scheduler.List() {
+			lastRun := "never"
+			if !job.LastRun.IsZero() {
+				lastRun = job.LastRun.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", job.Name, job.Spec, job.Next.Format("2006-01-02 15:04:05"),
+				lastRun, job.LastDuration.Round(time.Millisecond), job.LastError)
+		}
+		return w.Flush()
+
+	case "run":
+		if len(args) < 2 {
+			return errors.New("you must give the name of the job to run")
+		}
+
+		err := n.Scheduler.Run(context.Background(), args[1])
+		if err != nil {
+			return fmt.Errorf("%s: %w", args[1], err)
+		}
+		n.InfoLog.Printf("Job %s finished", args[1])
+
+	default:
+		return fmt.Errorf("unknown schedule command %q", args[0])
+	}
+
+	return nil
+}
+
+func (n *Napoleon) tokenCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New("token requires a subcommand: (create|revoke)")
+	}
+
+	if n.Auth == nil {
+		return errors.New("api tokens need a database, set DATABASE_TYPE")
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "create":
+		if len(args) < 2 {
+			return errors.New("you must give the id of the user the token is for")
+		}
+
+		userID, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid user id %q", args[1])
+		}
+
+		var abilities []string
+		if len(args) > 2 {
+			abilities = splitList(args[2])
+		}
+
+		ttl := 365 * 24 * time.Hour
+		if len(args) > 3 {
+			ttl, err = time.ParseDuration(args[3])
+			if err != nil {
+				return err
+			}
+		}
+
+		plainText, token, err := n.Auth.CreateToken(ctx, userID, abilities, ttl)
+		if err != nil {
+			return err
+		}
+
+		n.InfoLog.Printf("Token %d created, expires %s", token.ID, token.Expires.Format("2006-01-02 15:04:05"))
+		n.InfoLog.Printf("Copy it now, it will not be shown again: %s", plainText)
+
+	case "revoke":
+		if len(args) < 2 {
+			return errors.New("you must give the id of the token to revoke")
+		}
+
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid token id %q", args[1])
+		}
+
+		if err := n.Auth.RevokeToken(ctx, id); err != nil {
+			return fmt.Errorf("token %d: %w", id, err)
+		}
+		n.InfoLog.Printf("Token %d revoked", id)
+
+	default:
+		return fmt.Errorf("unknown token command %q", args[0])
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package napoleon
+
+import (
+	"encoding/gob"
+	"net/http"
+	"strings"
+
+	"github.com/hilsonxhero/napoleon/render"
+)
+
+func init() {
+	// old input and validation errors are stored in the session as maps
+	gob.Register(map[string]string{})
+}
+
+// Flash stores a message that is shown on the next page rendered for this session.
+func (n *Napoleon) Flash(r *http.Request, message string) {
+	n.Session.Put(r.Context(), render.FlashKey, message)
+}
+
+// Error stores an error message that is shown on the next page rendered for this session.
+func (n *Napoleon) Error(r *http.Request, message string) {
+	n.Session.Put(r.Context(), render.ErrorKey, message)
+}
+
+// Warning stores a warning that is shown on the next page rendered for this session.
+func (n *Napoleon) Warning(r *http.Request, message string) {
+	n.Session.Put(r.Context(), render.WarningKey, message)
+}
+
+// WithInput keeps the submitted form values for the next page, so that a form can be
+// refilled after a redirect. Passwords and the csrf token are never kept.
+func (n *Napoleon) WithInput(r *http.Request) {
+	_ = r.ParseForm()
+
+	old := make(map[string]string)
+	for field, values := range r.PostForm {
+		if len(values) == 0 || field == "csrf_token" || strings.Contains(strings.ToLower(field), "password") {
+			continue
+		}
+		old[field] = values[0]
+	}
+
+	n.Session.Put(r.Context(), render.OldInputKey, old)
+}
+
+// WithErrors keeps the errors of a failed validation, and the submitted input, for the
+// next page.
+func (n *Napoleon) WithErrors(r *http.Request, v *Validation) {
+	n.WithInput(r)
+	n.Session.Put(r.Context(), render.ErrorsKey, v.Errors)
+}
@@ -0,0 +1,85 @@
+package napoleon
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/hilsonxhero/napoleon/authz"
+	"github.com/hilsonxhero/napoleon/jwt"
+)
+
+// Errors handlers can return, wrapped or as they are, to answer with their status.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+)
+
+// HandlerFunc is a handler that returns its error instead of answering it; Handle turns
+// it into an http.HandlerFunc for chi routes.
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// Handle adapts fn to an http.HandlerFunc. The errors fn returns are answered by
+// WriteProblem: ErrNotFound and sql.ErrNoRows with 404, ErrUnauthorized with 401,
+// ErrForbidden with 403, a failed Validation with 422, Problems with their own status,
+// and anything else with 500. Server errors are logged with the request ID.
+func (n *Napoleon) Handle(fn HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tw := &trackingWriter{ResponseWriter: w}
+
+		err := fn(tw, r)
+		if err == nil {
+			return
+		}
+
+		p := ProblemFrom(err)
+		if p.Status >= http.StatusInternalServerError {
+			n.ErrorLog.Printf("[%s] %s %s: %v", middleware.GetReqID(r.Context()), r.Method, r.URL.Path, err)
+		}
+
+		// too late to answer with the error when the handler has already started to
+		if tw.wroteHeader {
+			return
+		}
+
+		n.WriteProblem(w, r, p)
+	}
+}
+
+// errorStatus returns the status of the errors handlers can return.
+func errorStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, sql.ErrNoRows):
+		return http.StatusNotFound, true
+	case errors.Is(err, ErrUnauthorized), errors.Is(err, authz.ErrUnauthenticated),
+		errors.Is(err, jwt.ErrInvalidToken), errors.Is(err, jwt.ErrRevoked):
+		return http.StatusUnauthorized, true
+	case errors.Is(err, ErrForbidden), errors.Is(err, authz.ErrForbidden):
+		return http.StatusForbidden, true
+	}
+
+	return 0, false
+}
+
+// trackingWriter remembers whether the response has been started.
+type trackingWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *trackingWriter) WriteHeader(status int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *trackingWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *trackingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,130 @@
+// Package support holds the helpers shared by the packages of napoleon.
+package support
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Rebind turns the ? placeholders of query into $1, $2... for postgres.
+func Rebind(dbType, query string) string {
+	switch strings.ToLower(dbType) {
+	case "mysql", "mariadb":
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
+
+// BearerToken returns the token of an "Authorization: Bearer" header.
+func BearerToken(r *http.Request) (string, bool) {
+	parts := strings.Fields(r.Header.Get("Authorization"))
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return "", false
+	}
+
+	return parts[1], true
+}
+
+// ClientIP returns the address of the client, without the port. Behind a proxy it is
+// the address set by middleware.RealIP.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ErrorHandler answers a request that failed with err.
+type ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error)
+
+// StatusError is an error that answers the request with Status, telling the client
+// Message. napoleon.ProblemFrom turns it into a problem with Message as the detail.
+type StatusError struct {
+	Status  int
+	Message string
+	Err     error
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" && e.Err != nil {
+		return e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+type contextKey int
+
+const errorHandlerContextKey contextKey = iota
+
+// WithErrorHandler stores handler in ctx, for middleware that runs later in the chain
+// and has no handler of its own.
+func WithErrorHandler(ctx context.Context, handler ErrorHandler) context.Context {
+	return context.WithValue(ctx, errorHandlerContextKey, handler)
+}
+
+// ErrorHandlerFrom returns the handler stored by WithErrorHandler, or nil.
+func ErrorHandlerFrom(ctx context.Context) ErrorHandler {
+	handler, _ := ctx.Value(errorHandlerContextKey).(ErrorHandler)
+	return handler
+}
+
+// Fail answers r with err through handler, or as a problem when handler is nil.
+func Fail(handler ErrorHandler, w http.ResponseWriter, r *http.Request, err error) {
+	if handler == nil {
+		handler = WriteProblem
+	}
+
+	handler(w, r, err)
+}
+
+// Unauthorized refuses a request to the api with status, which is 401 or 403.
+func Unauthorized(handler ErrorHandler, w http.ResponseWriter, r *http.Request, status int, message string) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+	}
+
+	Fail(handler, w, r, &StatusError{Status: status, Message: message})
+}
+
+// WriteProblem answers r with err as application/problem+json. A StatusError gives the
+// status and detail; any other error is a 500 that does not tell the client more.
+func WriteProblem(w http.ResponseWriter, r *http.Request, err error) {
+	status, detail := http.StatusInternalServerError, ""
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		status, detail = statusErr.Status, statusErr.Message
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(struct {
+		Type   string `json:"type"`
+		Title  string `json:"title"`
+		Status int    `json:"status"`
+		Detail string `json:"detail,omitempty"`
+	}{"about:blank", http.StatusText(status), status, detail})
+}
@@ -0,0 +1,108 @@
+package support
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRebind(t *testing.T) {
+	var tests = []struct {
+		dbType string
+		want   string
+	}{
+		{"postgres", "select * from users where id = $1 and email = $2"},
+		{"pgx", "select * from users where id = $1 and email = $2"},
+		{"mysql", "select * from users where id = ? and email = ?"},
+		{"MariaDB", "select * from users where id = ? and email = ?"},
+	}
+
+	for _, e := range tests {
+		if got := Rebind(e.dbType, "select * from users where id = ? and email = ?"); got != e.want {
+			t.Errorf("%s: expected %q, got %q", e.dbType, e.want, got)
+		}
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	var tests = []struct {
+		header string
+		token  string
+		ok     bool
+	}{
+		{"Bearer abc", "abc", true},
+		{"bearer  abc", "abc", true},
+		{"Basic abc", "", false},
+		{"Bearer", "", false},
+		{"", "", false},
+	}
+
+	for _, e := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", e.header)
+
+		if token, ok := BearerToken(r); token != e.token || ok != e.ok {
+			t.Errorf("%q: expected %q %v, got %q %v", e.header, e.token, e.ok, token, ok)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+
+	r.RemoteAddr = "10.0.0.1:5555"
+	if ip := ClientIP(r); ip != "10.0.0.1" {
+		t.Errorf("expected 10.0.0.1, got %q", ip)
+	}
+
+	// middleware.RealIP sets the address without a port
+	r.RemoteAddr = "2001:db8::1"
+	if ip := ClientIP(r); ip != "2001:db8::1" {
+		t.Errorf("expected 2001:db8::1, got %q", ip)
+	}
+}
+
+func TestWriteProblem(t *testing.T) {
+	var tests = []struct {
+		name   string
+		err    error
+		status int
+		detail string
+	}{
+		{"status_error", &StatusError{Status: http.StatusForbidden, Message: "token cannot posts:write"}, http.StatusForbidden, "token cannot posts:write"},
+		{"other", errors.New("database is down"), http.StatusInternalServerError, ""},
+	}
+
+	for _, e := range tests {
+		rr := httptest.NewRecorder()
+		WriteProblem(rr, httptest.NewRequest("GET", "/", nil), e.err)
+
+		var p struct {
+			Status int    `json:"status"`
+			Title  string `json:"title"`
+			Detail string `json:"detail"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &p); err != nil {
+			t.Errorf("%s: %v", e.name, err)
+			continue
+		}
+
+		if rr.Code != e.status || p.Status != e.status || p.Title != http.StatusText(e.status) || p.Detail != e.detail {
+			t.Errorf("%s: wrong problem %d %+v", e.name, rr.Code, p)
+		}
+		if rr.Header().Get("Content-Type") != "application/problem+json" {
+			t.Errorf("%s: wrong content type %q", e.name, rr.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestUnauthorized(t *testing.T) {
+	rr := httptest.NewRecorder()
+	Unauthorized(nil, rr, httptest.NewRequest("GET", "/", nil), http.StatusUnauthorized, "no bearer token received")
+
+	if rr.Code != http.StatusUnauthorized || rr.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("expected a 401 challenge, got %d %v", rr.Code, rr.Header())
+	}
+}
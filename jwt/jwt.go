@@ -0,0 +1,318 @@
+package jwt
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	jwtlib "github.com/golang-jwt/jwt/v5"
+	"github.com/hilsonxhero/napoleon/cache"
+	"github.com/hilsonxhero/napoleon/internal/support"
+)
+
+var (
+	// ErrInvalidToken is returned for tokens that are malformed, badly signed, expired,
+	// or not meant for us.
+	ErrInvalidToken = errors.New("jwt: invalid token")
+	// ErrRevoked is returned for access tokens on the revocation list.
+	ErrRevoked = errors.New("jwt: token revoked")
+	// ErrNoKeys is returned when there is no key to sign with.
+	ErrNoKeys = errors.New("jwt: no signing key")
+)
+
+// Claims are the claims of access tokens. The subject is the user id.
+type Claims struct {
+	jwtlib.RegisteredClaims
+	Abilities []string `json:"abilities,omitempty"`
+}
+
+// UserID returns the id of the user the token was issued to.
+func (c *Claims) UserID() int {
+	id, _ := strconv.Atoi(c.Subject)
+	return id
+}
+
+// Can reports whether the token has ability. Like api tokens, tokens without abilities,
+// or with "*", can do everything.
+func (c *Claims) Can(ability string) bool {
+	if len(c.Abilities) == 0 {
+		return true
+	}
+
+	for _, a := range c.Abilities {
+		if a == "*" || a == ability {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Pair is an access token with the refresh token to get the next one, in the shape of
+// an OAuth2 token response.
+type Pair struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// JWT issues and verifies short lived access tokens, and the refresh tokens that renew
+// them. Tokens are signed with the key called SigningKey, or the first of Keys, and
+// tokens signed with any of Keys verify, so keys can be rotated by adding the new key,
+// switching SigningKey to it, and dropping the old one once its tokens have expired.
+type JWT struct {
+	Keys          []*Key
+	SigningKey    string
+	Issuer        string
+	Audience      string
+	AccessTTL     time.Duration
+	RefreshTTL    time.Duration
+	RefreshTokens RefreshStore
+	// Cache shares the revocation list between servers; without it the list is kept
+	// in memory.
+	Cache  cache.Cache
+	Leeway time.Duration
+	// Active reports whether a user may still refresh their tokens, so that disabled
+	// or deleted users are logged out once their access token expires. Without it every
+	// user may.
+	Active func(ctx context.Context, userID int) (bool, error)
+	// ErrorHandler answers requests refused by Authenticate and RequireAbility; without
+	// it they get application/problem+json.
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+type contextKey int
+
+const claimsContextKey contextKey = iota
+
+// Issue starts a new refresh token family for the user, and returns its first pair.
+func (j *JWT) Issue(ctx context.Context, userID int, abilities ...string) (*Pair, error) {
+	family, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+
+	return j.issue(ctx, &RefreshToken{
+		UserID:    userID,
+		Family:    family,
+		Abilities: abilities,
+	})
+}
+
+func (j *JWT) issue(ctx context.Context, parent *RefreshToken) (*Pair, error) {
+	now := time.Now()
+
+	jti, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &Claims{
+		RegisteredClaims: jwtlib.RegisteredClaims{
+			ID:        jti,
+			Issuer:    j.Issuer,
+			Subject:   strconv.Itoa(parent.UserID),
+			IssuedAt:  jwtlib.NewNumericDate(now),
+			NotBefore: jwtlib.NewNumericDate(now),
+			ExpiresAt: jwtlib.NewNumericDate(now.Add(j.accessTTL())),
+		},
+		Abilities: parent.Abilities,
+	}
+	if j.Audience != "" {
+		claims.Audience = jwtlib.ClaimStrings{j.Audience}
+	}
+
+	access, err := j.Sign(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	plainText, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+
+	err = j.RefreshTokens.SaveRefreshToken(ctx, &RefreshToken{
+		Hash:      hashToken(plainText),
+		UserID:    parent.UserID,
+		Family:    parent.Family,
+		Abilities: parent.Abilities,
+		Expires:   now.Add(j.refreshTTL()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Pair{
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(j.accessTTL().Seconds()),
+		RefreshToken: plainText,
+	}, nil
+}
+
+// Sign signs claims with the signing key, naming it in the kid header.
+func (j *JWT) Sign(claims jwtlib.Claims) (string, error) {
+	key := j.signingKey()
+	if key == nil {
+		return "", ErrNoKeys
+	}
+
+	t := jwtlib.NewWithClaims(key.Method, claims)
+	t.Header["kid"] = key.ID
+
+	return t.SignedString(key.signingKey())
+}
+
+// Parse verifies an access token and returns its claims.
+func (j *JWT) Parse(ctx context.Context, token string) (*Claims, error) {
+	claims := &Claims{}
+
+	opts := []jwtlib.ParserOption{
+		jwtlib.WithExpirationRequired(),
+		jwtlib.WithLeeway(j.Leeway),
+	}
+	if j.Issuer != "" {
+		opts = append(opts, jwtlib.WithIssuer(j.Issuer))
+	}
+	if j.Audience != "" {
+		opts = append(opts, jwtlib.WithAudience(j.Audience))
+	}
+
+	_, err := jwtlib.ParseWithClaims(token, claims, func(t *jwtlib.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+
+		key := j.key(kid)
+		if key == nil {
+			return nil, fmt.Errorf("unknown key %q", kid)
+		}
+
+		// the algorithm must be the one of the key, or an attacker could pick one
+		if t.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected algorithm %s", t.Method.Alg())
+		}
+
+		return key.verifyingKey(), nil
+	}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	revoked, err := j.isRevoked(claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrRevoked
+	}
+
+	return claims, nil
+}
+
+// Authenticate is middleware that verifies the "Authorization: Bearer <token>" header
+// and puts the claims in the request context, where ClaimsFromContext finds them.
+func (j *JWT) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := support.BearerToken(r)
+		if !ok {
+			support.Unauthorized(j.ErrorHandler, w, r, http.StatusUnauthorized, "no bearer token received")
+			return
+		}
+
+		claims, err := j.Parse(r.Context(), token)
+		if err != nil {
+			support.Unauthorized(j.ErrorHandler, w, r, http.StatusUnauthorized, "invalid or expired token")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), claimsContextKey, claims)
+		ctx = support.WithErrorHandler(ctx, j.ErrorHandler)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireAbility returns middleware that rejects tokens missing any of abilities. It
+// must run after Authenticate.
+func RequireAbility(abilities ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := ClaimsFromContext(r.Context())
+			if claims == nil {
+				support.Unauthorized(support.ErrorHandlerFrom(r.Context()), w, r, http.StatusUnauthorized, "no bearer token received")
+				return
+			}
+
+			for _, ability := range abilities {
+				if !claims.Can(ability) {
+					support.Unauthorized(support.ErrorHandlerFrom(r.Context()), w, r, http.StatusForbidden, "token cannot "+ability)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClaimsFromContext returns the claims of the token authenticated by Authenticate, or nil.
+func ClaimsFromContext(ctx context.Context) *Claims {
+	claims, _ := ctx.Value(claimsContextKey).(*Claims)
+	return claims
+}
+
+func (j *JWT) signingKey() *Key {
+	if j.SigningKey != "" {
+		return j.key(j.SigningKey)
+	}
+
+	if len(j.Keys) > 0 {
+		return j.Keys[0]
+	}
+
+	return nil
+}
+
+func (j *JWT) key(id string) *Key {
+	for _, key := range j.Keys {
+		if key.ID == id {
+			return key
+		}
+	}
+
+	return nil
+}
+
+func (j *JWT) accessTTL() time.Duration {
+	if j.AccessTTL > 0 {
+		return j.AccessTTL
+	}
+
+	return 15 * time.Minute
+}
+
+func (j *JWT) refreshTTL() time.Duration {
+	if j.RefreshTTL > 0 {
+		return j.RefreshTTL
+	}
+
+	return 30 * 24 * time.Hour
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
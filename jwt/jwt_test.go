@@ -0,0 +1,205 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwtlib "github.com/golang-jwt/jwt/v5"
+)
+
+func TestJWT_IssueAndParse(t *testing.T) {
+	ctx := context.Background()
+
+	for _, key := range []*Key{testHMACKey, testRSAKey, testEd25519Key} {
+		j := newTestJWT(key)
+
+		pair, err := j.Issue(ctx, 7, "posts:read")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		claims, err := j.Parse(ctx, pair.AccessToken)
+		if err != nil {
+			t.Errorf("%s: %v", key.Method.Alg(), err)
+			continue
+		}
+
+		if claims.UserID() != 7 || !claims.Can("posts:read") || claims.Can("posts:write") {
+			t.Errorf("%s: wrong claims: %+v", key.Method.Alg(), claims)
+		}
+
+		if pair.TokenType != "Bearer" || pair.ExpiresIn != 900 || pair.RefreshToken == "" {
+			t.Errorf("%s: wrong pair: %+v", key.Method.Alg(), pair)
+		}
+	}
+}
+
+func TestJWT_KeyRotation(t *testing.T) {
+	ctx := context.Background()
+
+	old := newTestJWT(testRSAKey)
+	pair, _ := old.Issue(ctx, 7)
+
+	// the new key signs, the old one still verifies
+	rotated := newTestJWT(testEd25519Key, testRSAKey)
+	if _, err := rotated.Parse(ctx, pair.AccessToken); err != nil {
+		t.Errorf("expected token of the old key to verify, got %v", err)
+	}
+
+	fresh, _ := rotated.Issue(ctx, 7)
+	token, _, _ := jwtlib.NewParser().ParseUnverified(fresh.AccessToken, &Claims{})
+	if token.Header["kid"] != "ed" {
+		t.Errorf("expected new tokens to be signed with the new key, got kid %v", token.Header["kid"])
+	}
+
+	// once the old key is dropped its tokens stop working
+	dropped := newTestJWT(testEd25519Key)
+	if _, err := dropped.Parse(ctx, pair.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken for a dropped key, got %v", err)
+	}
+}
+
+func TestJWT_Parse_Invalid(t *testing.T) {
+	ctx := context.Background()
+	j := newTestJWT(testHMACKey, testRSAKey)
+
+	sign := func(key *Key, method jwtlib.SigningMethod, signingKey interface{}, mutate func(c *Claims)) string {
+		c := &Claims{RegisteredClaims: jwtlib.RegisteredClaims{
+			Issuer:    j.Issuer,
+			Audience:  jwtlib.ClaimStrings{j.Audience},
+			Subject:   "7",
+			ExpiresAt: jwtlib.NewNumericDate(time.Now().Add(time.Minute)),
+		}}
+		if mutate != nil {
+			mutate(c)
+		}
+		tok := jwtlib.NewWithClaims(method, c)
+		tok.Header["kid"] = key.ID
+		s, err := tok.SignedString(signingKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	tests := map[string]string{
+		"expired": sign(testHMACKey, testHMACKey.Method, testHMACKey.Secret, func(c *Claims) {
+			c.ExpiresAt = jwtlib.NewNumericDate(time.Now().Add(-time.Minute))
+		}),
+		"no expiry": sign(testHMACKey, testHMACKey.Method, testHMACKey.Secret, func(c *Claims) {
+			c.ExpiresAt = nil
+		}),
+		"wrong issuer": sign(testHMACKey, testHMACKey.Method, testHMACKey.Secret, func(c *Claims) {
+			c.Issuer = "https://evil.test"
+		}),
+		"wrong audience": sign(testHMACKey, testHMACKey.Method, testHMACKey.Secret, func(c *Claims) {
+			c.Audience = jwtlib.ClaimStrings{"other"}
+		}),
+		"unknown key":  sign(&Key{ID: "other"}, testHMACKey.Method, testHMACKey.Secret, nil),
+		"wrong secret": sign(testHMACKey, testHMACKey.Method, []byte("another secret"), nil),
+		// signing with HS256 and the public RSA key as secret is the classic confusion attack
+		"algorithm confusion": sign(testRSAKey, jwtlib.SigningMethodHS256, []byte("public key bytes"), nil),
+		"garbage":             "not.a.token",
+	}
+
+	for name, token := range tests {
+		if _, err := j.Parse(ctx, token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: expected ErrInvalidToken, got %v", name, err)
+		}
+	}
+}
+
+func TestJWT_Revoke(t *testing.T) {
+	ctx := context.Background()
+
+	for name, j := range map[string]*JWT{"memory": newTestJWT(), "cache": newTestJWT()} {
+		if name == "cache" {
+			j.Cache = testCache
+		}
+
+		pair, _ := j.Issue(ctx, 7)
+		claims, err := j.Parse(ctx, pair.AccessToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := j.Revoke(ctx, claims); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := j.Parse(ctx, pair.AccessToken); !errors.Is(err, ErrRevoked) {
+			t.Errorf("%s: expected ErrRevoked, got %v", name, err)
+		}
+
+		other, _ := j.Issue(ctx, 7)
+		if _, err := j.Parse(ctx, other.AccessToken); err != nil {
+			t.Errorf("%s: expected other tokens to still work, got %v", name, err)
+		}
+	}
+}
+
+func TestJWT_Authenticate(t *testing.T) {
+	j := newTestJWT()
+	pair, _ := j.Issue(context.Background(), 7, "posts:read")
+
+	var got *Claims
+	handler := j.Authenticate(RequireAbility("posts:read")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = ClaimsFromContext(r.Context())
+	})))
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"invalid token", "Bearer nope", http.StatusUnauthorized},
+		{"valid token", "Bearer " + pair.AccessToken, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, r)
+
+		if rr.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.status, rr.Code)
+		}
+	}
+
+	if got == nil || got.UserID() != 7 {
+		t.Errorf("expected claims in the context, got %+v", got)
+	}
+
+	// a token without the ability is forbidden
+	limited, _ := j.Issue(context.Background(), 7, "comments:read")
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer "+limited.AccessToken)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a token without the ability, got %d", rr.Code)
+	}
+	if rr.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("expected a problem, got %q", rr.Header().Get("Content-Type"))
+	}
+
+	// RequireAbility answers through the error handler of Authenticate
+	j.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		w.WriteHeader(http.StatusTeapot)
+	}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+
+	if rr.Code != http.StatusTeapot {
+		t.Errorf("expected the error handler to answer, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,106 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	jwtlib "github.com/golang-jwt/jwt/v5"
+)
+
+// ErrUnsupportedKey is returned for private keys other than RSA and Ed25519.
+var ErrUnsupportedKey = errors.New("jwt: unsupported key type")
+
+// Key is a signing key. HMAC keys have a Secret; RSA and Ed25519 keys a Private key,
+// whose public half verifies the tokens. The ID goes into the kid header of the tokens
+// it signs, so tokens keep verifying while a new key takes over.
+type Key struct {
+	ID      string
+	Method  jwtlib.SigningMethod
+	Secret  []byte
+	Private crypto.Signer
+}
+
+// NewHMACKey returns an HS256 key.
+func NewHMACKey(id string, secret []byte) *Key {
+	return &Key{ID: id, Method: jwtlib.SigningMethodHS256, Secret: secret}
+}
+
+// ParsePrivateKey reads a PEM encoded RSA (PKCS #1 or #8) or Ed25519 (PKCS #8) private
+// key, which signs with RS256 or EdDSA respectively.
+func ParsePrivateKey(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("jwt: key %s is not PEM encoded", id)
+	}
+
+	var private interface{}
+	var err error
+	if block.Type == "RSA PRIVATE KEY" {
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		return &Key{ID: id, Method: jwtlib.SigningMethodRS256, Private: private}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: id, Method: jwtlib.SigningMethodEdDSA, Private: private}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, private)
+}
+
+// LoadKeys reads the private keys in the *.pem files of dir, each named after its key
+// id, such as 2024-01.pem. The keys are sorted by id.
+func LoadKeys(dir string) ([]*Key, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var keys []*Key
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := ParsePrivateKey(strings.TrimSuffix(filepath.Base(file), ".pem"), data)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func (k *Key) signingKey() interface{} {
+	if k.Secret != nil {
+		return k.Secret
+	}
+
+	return k.Private
+}
+
+func (k *Key) verifyingKey() interface{} {
+	if k.Secret != nil {
+		return k.Secret
+	}
+
+	return k.Private.Public()
+}
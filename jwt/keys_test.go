@@ -0,0 +1,61 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadKeys(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name, blockType string, der []byte) {
+		data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	edDER, err := x509.MarshalPKCS8PrivateKey(testEd25519Key.Private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write("2024-01.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(testRSAKey.Private.(*rsa.PrivateKey)))
+	write("2024-02.pem", "PRIVATE KEY", edDER)
+
+	keys, err := LoadKeys(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 2 || keys[0].ID != "2024-01" || keys[0].Method.Alg() != "RS256" ||
+		keys[1].ID != "2024-02" || keys[1].Method.Alg() != "EdDSA" {
+		t.Errorf("wrong keys loaded: %+v", keys)
+	}
+}
+
+func TestParsePrivateKey_Unsupported(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, _ := x509.MarshalPKCS8PrivateKey(ecKey)
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	if _, err := ParsePrivateKey("ec", data); !errors.Is(err, ErrUnsupportedKey) {
+		t.Errorf("expected ErrUnsupportedKey, got %v", err)
+	}
+
+	if _, err := ParsePrivateKey("bad", []byte("not pem")); err == nil {
+		t.Error("expected an error for data that is not PEM")
+	}
+}
@@ -0,0 +1,243 @@
+package jwt
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hilsonxhero/napoleon/internal/support"
+)
+
+var (
+	// ErrInvalidRefreshToken is returned for refresh tokens that are unknown or expired.
+	ErrInvalidRefreshToken = errors.New("jwt: invalid refresh token")
+	// ErrRefreshTokenReused is returned when a refresh token is used a second time. Only
+	// a stolen token is used twice, so the whole family it belongs to is revoked.
+	ErrRefreshTokenReused = errors.New("jwt: refresh token reused")
+	// ErrInactive is returned by Refresh when Active reports that the user may no longer
+	// log in.
+	ErrInactive = errors.New("jwt: user is not active")
+)
+
+// RefreshToken is a stored refresh token. Every refresh replaces the token with a new
+// one of the same family, so a family is one login of one client.
+type RefreshToken struct {
+	Hash      string
+	UserID    int
+	Family    string
+	Abilities []string
+	Expires   time.Time
+	UsedAt    time.Time
+}
+
+// RefreshStore stores refresh tokens by the hash of their plain text.
+type RefreshStore interface {
+	SaveRefreshToken(ctx context.Context, t *RefreshToken) error
+	// UseRefreshToken marks the token with hash as used and returns it, with reused set
+	// when it had been used before. Unknown tokens return ErrInvalidRefreshToken.
+	UseRefreshToken(ctx context.Context, hash string) (t *RefreshToken, reused bool, err error)
+	RevokeFamily(ctx context.Context, family string) error
+	RevokeUser(ctx context.Context, userID int) error
+}
+
+// Refresh exchanges a refresh token for a new pair. Each refresh token works once:
+// using one again revokes its family and returns ErrRefreshTokenReused, which logs out
+// both the thief and the client it was stolen from. Users that Active turns down since
+// they logged in have the family revoked and get ErrInactive.
+func (j *JWT) Refresh(ctx context.Context, refreshToken string) (*Pair, error) {
+	t, reused, err := j.RefreshTokens.UseRefreshToken(ctx, hashToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+
+	if reused {
+		if err := j.RefreshTokens.RevokeFamily(ctx, t.Family); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	if t.Expires.Before(time.Now()) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if j.Active != nil {
+		active, err := j.Active(ctx, t.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		if !active {
+			if err := j.RefreshTokens.RevokeFamily(ctx, t.Family); err != nil {
+				return nil, err
+			}
+			return nil, ErrInactive
+		}
+	}
+
+	return j.issue(ctx, t)
+}
+
+// Logout revokes the access token of claims and the family of refreshToken.
+func (j *JWT) Logout(ctx context.Context, claims *Claims, refreshToken string) error {
+	if claims != nil {
+		if err := j.Revoke(ctx, claims); err != nil {
+			return err
+		}
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+
+	t, _, err := j.RefreshTokens.UseRefreshToken(ctx, hashToken(refreshToken))
+	if errors.Is(err, ErrInvalidRefreshToken) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return j.RefreshTokens.RevokeFamily(ctx, t.Family)
+}
+
+// LogoutEverywhere revokes all refresh tokens of the user. Access tokens already issued
+// stay valid until they expire, unless revoked with Revoke.
+func (j *JWT) LogoutEverywhere(ctx context.Context, userID int) error {
+	return j.RefreshTokens.RevokeUser(ctx, userID)
+}
+
+func hashToken(plainText string) string {
+	sum := sha256.Sum256([]byte(plainText))
+	return hex.EncodeToString(sum[:])
+}
+
+// MemoryRefreshStore keeps refresh tokens in memory, for tests and single servers that
+// can live with logging everyone out on restart.
+type MemoryRefreshStore struct {
+	mu     sync.Mutex
+	tokens map[string]*RefreshToken
+}
+
+func (s *MemoryRefreshStore) SaveRefreshToken(ctx context.Context, t *RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tokens == nil {
+		s.tokens = make(map[string]*RefreshToken)
+	}
+
+	copied := *t
+	s.tokens[t.Hash] = &copied
+
+	return nil
+}
+
+func (s *MemoryRefreshStore) UseRefreshToken(ctx context.Context, hash string) (*RefreshToken, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[hash]
+	if !ok {
+		return nil, false, ErrInvalidRefreshToken
+	}
+
+	copied := *t
+	if !t.UsedAt.IsZero() {
+		return &copied, true, nil
+	}
+
+	t.UsedAt = time.Now()
+
+	return &copied, false, nil
+}
+
+func (s *MemoryRefreshStore) RevokeFamily(ctx context.Context, family string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, t := range s.tokens {
+		if t.Family == family {
+			delete(s.tokens, hash)
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryRefreshStore) RevokeUser(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, t := range s.tokens {
+		if t.UserID == userID {
+			delete(s.tokens, hash)
+		}
+	}
+
+	return nil
+}
+
+// SQLRefreshStore keeps refresh tokens in the refresh_tokens table created by
+// "napoleon make jwt".
+type SQLRefreshStore struct {
+	DB     *sql.DB
+	DBType string
+}
+
+func (s *SQLRefreshStore) SaveRefreshToken(ctx context.Context, t *RefreshToken) error {
+	now := time.Now()
+	_, err := s.DB.ExecContext(ctx, support.Rebind(s.DBType, `insert into refresh_tokens (user_id, family, token_hash, abilities, expires_at, created_at)
+		values (?, ?, ?, ?, ?, ?)`), t.UserID, t.Family, t.Hash, strings.Join(t.Abilities, ","), t.Expires, now)
+
+	return err
+}
+
+func (s *SQLRefreshStore) UseRefreshToken(ctx context.Context, hash string) (*RefreshToken, bool, error) {
+	// the update is what claims the token, so two requests racing with the same token
+	// cannot both get a new pair
+	res, err := s.DB.ExecContext(ctx, support.Rebind(s.DBType, "update refresh_tokens set used_at = ? where token_hash = ? and used_at is null"),
+		time.Now(), hash)
+	if err != nil {
+		return nil, false, err
+	}
+	claimed, err := res.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+
+	var t RefreshToken
+	var abilities string
+	var usedAt sql.NullTime
+
+	row := s.DB.QueryRowContext(ctx, support.Rebind(s.DBType, "select token_hash, user_id, family, abilities, expires_at, used_at from refresh_tokens where token_hash = ?"), hash)
+	err = row.Scan(&t.Hash, &t.UserID, &t.Family, &abilities, &t.Expires, &usedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	t.UsedAt = usedAt.Time
+	if abilities != "" {
+		t.Abilities = strings.Split(abilities, ",")
+	}
+
+	return &t, claimed == 0, nil
+}
+
+func (s *SQLRefreshStore) RevokeFamily(ctx context.Context, family string) error {
+	_, err := s.DB.ExecContext(ctx, support.Rebind(s.DBType, "delete from refresh_tokens where family = ?"), family)
+	return err
+}
+
+func (s *SQLRefreshStore) RevokeUser(ctx context.Context, userID int) error {
+	_, err := s.DB.ExecContext(ctx, support.Rebind(s.DBType, "delete from refresh_tokens where user_id = ?"), userID)
+	return err
+}
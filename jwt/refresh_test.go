@@ -0,0 +1,125 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJWT_Refresh(t *testing.T) {
+	ctx := context.Background()
+	j := newTestJWT()
+
+	first, _ := j.Issue(ctx, 7, "posts:read")
+
+	second, err := j.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if second.RefreshToken == first.RefreshToken {
+		t.Error("expected the refresh token to be rotated")
+	}
+
+	claims, err := j.Parse(ctx, second.AccessToken)
+	if err != nil || claims.UserID() != 7 || !claims.Can("posts:read") || claims.Can("posts:write") {
+		t.Errorf("expected the new access token to keep user and abilities, got %+v %v", claims, err)
+	}
+
+	if _, err := j.Refresh(ctx, "unknown"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("expected ErrInvalidRefreshToken, got %v", err)
+	}
+}
+
+func TestJWT_Refresh_ReuseRevokesFamily(t *testing.T) {
+	ctx := context.Background()
+	j := newTestJWT()
+
+	first, _ := j.Issue(ctx, 7)
+	other, _ := j.Issue(ctx, 7)
+	second, _ := j.Refresh(ctx, first.RefreshToken)
+
+	// the first token was stolen and used again
+	if _, err := j.Refresh(ctx, first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("expected ErrRefreshTokenReused, got %v", err)
+	}
+
+	if _, err := j.Refresh(ctx, second.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("expected the rest of the family to be revoked, got %v", err)
+	}
+
+	if _, err := j.Refresh(ctx, other.RefreshToken); err != nil {
+		t.Errorf("expected other families to keep working, got %v", err)
+	}
+}
+
+func TestJWT_Refresh_Expired(t *testing.T) {
+	ctx := context.Background()
+	j := newTestJWT()
+	j.RefreshTTL = time.Nanosecond
+
+	pair, _ := j.Issue(ctx, 7)
+	time.Sleep(time.Millisecond)
+
+	if _, err := j.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("expected ErrInvalidRefreshToken, got %v", err)
+	}
+}
+
+func TestJWT_Refresh_Inactive(t *testing.T) {
+	ctx := context.Background()
+	j := newTestJWT()
+
+	active := true
+	j.Active = func(ctx context.Context, userID int) (bool, error) {
+		return active, nil
+	}
+
+	pair, _ := j.Issue(ctx, 7)
+	pair, err := j.Refresh(ctx, pair.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the user is disabled while logged in
+	active = false
+	if _, err := j.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrInactive) {
+		t.Fatalf("expected ErrInactive, got %v", err)
+	}
+
+	active = true
+	if _, err := j.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("expected the refresh token to be revoked, got %v", err)
+	}
+}
+
+func TestJWT_Logout(t *testing.T) {
+	ctx := context.Background()
+	j := newTestJWT()
+
+	pair, _ := j.Issue(ctx, 7)
+	claims, _ := j.Parse(ctx, pair.AccessToken)
+
+	if err := j.Logout(ctx, claims, pair.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := j.Parse(ctx, pair.AccessToken); !errors.Is(err, ErrRevoked) {
+		t.Errorf("expected the access token to be revoked, got %v", err)
+	}
+
+	if _, err := j.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("expected the refresh token to be revoked, got %v", err)
+	}
+
+	a, _ := j.Issue(ctx, 8)
+	b, _ := j.Issue(ctx, 8)
+	_ = j.LogoutEverywhere(ctx, 8)
+
+	for _, p := range []*Pair{a, b} {
+		if _, err := j.Refresh(ctx, p.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Errorf("expected all refresh tokens of the user to be revoked, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package jwt
+
+import (
+	"context"
+	"time"
+)
+
+// Revoke puts the access token of claims on the revocation list until it expires, so
+// that it stops working before then, as on logout.
+func (j *JWT) Revoke(ctx context.Context, claims *Claims) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+
+	ttl := time.Until(claims.ExpiresAt.Time) + j.Leeway
+	if ttl <= 0 {
+		return nil
+	}
+
+	if j.Cache != nil {
+		// round up, so the entry does not expire before the token
+		return j.Cache.Set(revokedKey(claims.ID), true, int(ttl/time.Second)+1)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.revoked == nil {
+		j.revoked = make(map[string]time.Time)
+	}
+
+	now := time.Now()
+	for id, until := range j.revoked {
+		if now.After(until) {
+			delete(j.revoked, id)
+		}
+	}
+
+	j.revoked[claims.ID] = now.Add(ttl)
+
+	return nil
+}
+
+func (j *JWT) isRevoked(id string) (bool, error) {
+	if id == "" {
+		return false, nil
+	}
+
+	if j.Cache != nil {
+		return j.Cache.Has(revokedKey(id))
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	until, ok := j.revoked[id]
+
+	return ok && time.Now().Before(until), nil
+}
+
+func revokedKey(id string) string {
+	return "jwt:revoked:" + id
+}
@@ -0,0 +1,74 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	jwtlib "github.com/golang-jwt/jwt/v5"
+	"github.com/gomodule/redigo/redis"
+	"github.com/hilsonxhero/napoleon/cache"
+)
+
+var testCache *cache.RedisCache
+
+var (
+	testHMACKey    = NewHMACKey("hmac", []byte("a very secret key of 32 bytes!!!"))
+	testRSAKey     *Key
+	testEd25519Key *Key
+)
+
+func TestMain(m *testing.M) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+
+	testCache = &cache.RedisCache{
+		Conn: &redis.Pool{
+			MaxIdle:     50,
+			MaxActive:   1000,
+			IdleTimeout: 240 * time.Second,
+			Dial: func() (redis.Conn, error) {
+				return redis.Dial("tcp", s.Addr())
+			},
+		},
+		Prefix: "test-napoleon",
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	testRSAKey = &Key{ID: "rsa", Method: jwtlib.SigningMethodRS256, Private: rsaKey}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	testEd25519Key = &Key{ID: "ed", Method: jwtlib.SigningMethodEdDSA, Private: edKey}
+
+	code := m.Run()
+
+	// os.Exit skips deferred calls, so clean up first
+	s.Close()
+
+	os.Exit(code)
+}
+
+func newTestJWT(keys ...*Key) *JWT {
+	if len(keys) == 0 {
+		keys = []*Key{testHMACKey}
+	}
+
+	return &JWT{
+		Keys:          keys,
+		Issuer:        "https://napoleon.test",
+		Audience:      "api",
+		RefreshTokens: &MemoryRefreshStore{},
+	}
+}
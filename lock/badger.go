@@ -0,0 +1,126 @@
+package lock
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// BadgerLocker implements Locker on top of a badger database, for single node setups
+// that already use the badger cache. Conflicting transactions count as not acquired.
+type BadgerLocker struct {
+	Conn   *badger.DB
+	Prefix string
+}
+
+func (b *BadgerLocker) Acquire(ctx context.Context, name string, ttl time.Duration) (*Lock, error) {
+	owner, err := newOwner()
+	if err != nil {
+		return nil, err
+	}
+
+	var token int64
+
+	err = b.Conn.Update(func(txn *badger.Txn) error {
+		_, err := txn.Get(b.key(name))
+		if err == nil {
+			return ErrNotAcquired
+		}
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		item, err := txn.Get(b.fenceKey(name))
+		switch {
+		case err == nil:
+			err = item.Value(func(val []byte) error {
+				token = int64(binary.BigEndian.Uint64(val))
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		case !errors.Is(err, badger.ErrKeyNotFound):
+			return err
+		}
+
+		token++
+		fence := make([]byte, 8)
+		binary.BigEndian.PutUint64(fence, uint64(token))
+
+		if err := txn.Set(b.fenceKey(name), fence); err != nil {
+			return err
+		}
+
+		return txn.SetEntry(badger.NewEntry(b.key(name), []byte(owner)).WithTTL(ttl))
+	})
+
+	if errors.Is(err, badger.ErrConflict) {
+		return nil, ErrNotAcquired
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Lock{
+		Name:    name,
+		Token:   token,
+		Expires: time.Now().Add(ttl),
+		owner:   owner,
+	}, nil
+}
+
+func (b *BadgerLocker) Release(ctx context.Context, l *Lock) error {
+	return b.Conn.Update(func(txn *badger.Txn) error {
+		if err := b.checkOwner(txn, l); err != nil {
+			return err
+		}
+
+		return txn.Delete(b.key(l.Name))
+	})
+}
+
+func (b *BadgerLocker) Extend(ctx context.Context, l *Lock, ttl time.Duration) error {
+	err := b.Conn.Update(func(txn *badger.Txn) error {
+		if err := b.checkOwner(txn, l); err != nil {
+			return err
+		}
+
+		return txn.SetEntry(badger.NewEntry(b.key(l.Name), []byte(l.owner)).WithTTL(ttl))
+	})
+	if err != nil {
+		return err
+	}
+
+	l.Expires = time.Now().Add(ttl)
+
+	return nil
+}
+
+func (b *BadgerLocker) checkOwner(txn *badger.Txn, l *Lock) error {
+	item, err := txn.Get(b.key(l.Name))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return ErrNotHeld
+	}
+	if err != nil {
+		return err
+	}
+
+	return item.Value(func(val []byte) error {
+		if string(val) != l.owner {
+			return ErrNotHeld
+		}
+		return nil
+	})
+}
+
+func (b *BadgerLocker) key(name string) []byte {
+	return []byte(b.Prefix + "lock:" + name)
+}
+
+func (b *BadgerLocker) fenceKey(name string) []byte {
+	return []byte(b.Prefix + "lock:" + name + ":fence")
+}
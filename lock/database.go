@@ -0,0 +1,147 @@
+package lock
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DatabaseLocker implements Locker with postgres advisory locks, or GET_LOCK on mysql and
+// mariadb. These locks belong to a database connection, so each held lock keeps one
+// connection out of the pool until it is released or its ttl runs out. If the process
+// dies, the server drops the connection and the lock with it.
+type DatabaseLocker struct {
+	DB     *sql.DB
+	DBType string
+
+	mu   sync.Mutex
+	held map[string]*heldLock
+}
+
+type heldLock struct {
+	lock  Lock
+	conn  *sql.Conn
+	timer *time.Timer
+}
+
+func (d *DatabaseLocker) Acquire(ctx context.Context, name string, ttl time.Duration) (*Lock, error) {
+	owner, err := newOwner()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := d.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var ok bool
+	var token int64
+
+	if d.isMySQL() {
+		var got sql.NullInt64
+		err = conn.QueryRowContext(ctx, "select get_lock(?, 0)", name).Scan(&got)
+		ok = got.Valid && got.Int64 == 1
+		if err == nil && ok {
+			// uuid_short increases with every call, even across server restarts
+			err = conn.QueryRowContext(ctx, "select uuid_short()").Scan(&token)
+		}
+	} else {
+		err = conn.QueryRowContext(ctx, "select pg_try_advisory_lock(hashtext($1))", name).Scan(&ok)
+		if err == nil && ok {
+			err = conn.QueryRowContext(ctx, "select txid_current()").Scan(&token)
+		}
+	}
+
+	if err != nil || !ok {
+		_ = conn.Close()
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrNotAcquired
+	}
+
+	h := &heldLock{
+		lock: Lock{
+			Name:    name,
+			Token:   token,
+			Expires: time.Now().Add(ttl),
+			owner:   owner,
+		},
+		conn: conn,
+	}
+	h.timer = time.AfterFunc(ttl, func() {
+		d.unlock(context.Background(), owner)
+	})
+
+	d.mu.Lock()
+	if d.held == nil {
+		d.held = make(map[string]*heldLock)
+	}
+	d.held[owner] = h
+	d.mu.Unlock()
+
+	l := h.lock
+	return &l, nil
+}
+
+func (d *DatabaseLocker) Release(ctx context.Context, l *Lock) error {
+	return d.unlock(ctx, l.owner)
+}
+
+func (d *DatabaseLocker) Extend(ctx context.Context, l *Lock, ttl time.Duration) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	h, ok := d.held[l.owner]
+	if !ok || !h.timer.Stop() {
+		return ErrNotHeld
+	}
+
+	h.timer.Reset(ttl)
+	l.Expires = time.Now().Add(ttl)
+	h.lock.Expires = l.Expires
+
+	return nil
+}
+
+func (d *DatabaseLocker) unlock(ctx context.Context, owner string) error {
+	d.mu.Lock()
+	h, ok := d.held[owner]
+	delete(d.held, owner)
+	d.mu.Unlock()
+
+	if !ok {
+		return ErrNotHeld
+	}
+
+	h.timer.Stop()
+	defer h.conn.Close()
+
+	var err error
+	if d.isMySQL() {
+		_, err = h.conn.ExecContext(ctx, "select release_lock(?)", h.lock.Name)
+	} else {
+		_, err = h.conn.ExecContext(ctx, "select pg_advisory_unlock(hashtext($1))", h.lock.Name)
+	}
+
+	if err != nil {
+		// never hand a connection that may still hold the lock back to the pool
+		_ = h.conn.Raw(func(interface{}) error {
+			return driver.ErrBadConn
+		})
+	}
+
+	return err
+}
+
+func (d *DatabaseLocker) isMySQL() bool {
+	switch strings.ToLower(d.DBType) {
+	case "mysql", "mariadb":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// ErrNotAcquired is returned by Acquire when the lock is held by someone else.
+var ErrNotAcquired = errors.New("lock: not acquired")
+
+// ErrNotHeld is returned by Release and Extend when the lock has expired or has been
+// taken over by another owner.
+var ErrNotHeld = errors.New("lock: not held")
+
+// Locker hands out named locks with a time to live, so that a crashed owner cannot hold
+// a lock forever.
+type Locker interface {
+	Acquire(ctx context.Context, name string, ttl time.Duration) (*Lock, error)
+	Release(ctx context.Context, l *Lock) error
+	Extend(ctx context.Context, l *Lock, ttl time.Duration) error
+}
+
+// Lock is a held lock. Token is a fencing token: it grows every time the name is acquired,
+// so a resource can reject writes from an owner whose lock has since expired.
+type Lock struct {
+	Name    string
+	Token   int64
+	Expires time.Time
+	owner   string
+}
+
+// Wait calls Acquire every retry interval until the lock is obtained or ctx is done.
+func Wait(ctx context.Context, locker Locker, name string, ttl, retry time.Duration) (*Lock, error) {
+	for {
+		l, err := locker.Acquire(ctx, name, ttl)
+		if !errors.Is(err, ErrNotAcquired) {
+			return l, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(retry):
+		}
+	}
+}
+
+func newOwner() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,134 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var lockers = []struct {
+	name   string
+	locker Locker
+}{
+	{"redis", &testRedisLocker},
+	{"badger", &testBadgerLocker},
+	{"memory", &testMemoryLocker},
+}
+
+func TestLocker_Acquire(t *testing.T) {
+	ctx := context.Background()
+
+	for _, e := range lockers {
+		l, err := e.locker.Acquire(ctx, "acquire", time.Minute)
+		if err != nil {
+			t.Errorf("%s: %s", e.name, err)
+			continue
+		}
+
+		_, err = e.locker.Acquire(ctx, "acquire", time.Minute)
+		if !errors.Is(err, ErrNotAcquired) {
+			t.Errorf("%s: expected ErrNotAcquired for a held lock, got %v", e.name, err)
+		}
+
+		err = e.locker.Release(ctx, l)
+		if err != nil {
+			t.Errorf("%s: %s", e.name, err)
+		}
+
+		l2, err := e.locker.Acquire(ctx, "acquire", time.Minute)
+		if err != nil {
+			t.Errorf("%s: could not acquire released lock: %s", e.name, err)
+			continue
+		}
+
+		if l2.Token <= l.Token {
+			t.Errorf("%s: fencing token did not increase: %d then %d", e.name, l.Token, l2.Token)
+		}
+
+		_ = e.locker.Release(ctx, l2)
+	}
+}
+
+func TestLocker_Release(t *testing.T) {
+	ctx := context.Background()
+
+	for _, e := range lockers {
+		l, err := e.locker.Acquire(ctx, "release", time.Minute)
+		if err != nil {
+			t.Errorf("%s: %s", e.name, err)
+			continue
+		}
+
+		stranger := &Lock{Name: "release", owner: "someone-else"}
+		err = e.locker.Release(ctx, stranger)
+		if !errors.Is(err, ErrNotHeld) {
+			t.Errorf("%s: released a lock owned by someone else, got %v", e.name, err)
+		}
+
+		err = e.locker.Release(ctx, l)
+		if err != nil {
+			t.Errorf("%s: %s", e.name, err)
+		}
+
+		err = e.locker.Release(ctx, l)
+		if !errors.Is(err, ErrNotHeld) {
+			t.Errorf("%s: expected ErrNotHeld releasing twice, got %v", e.name, err)
+		}
+	}
+}
+
+func TestLocker_Extend(t *testing.T) {
+	ctx := context.Background()
+
+	for _, e := range lockers {
+		l, err := e.locker.Acquire(ctx, "extend", time.Minute)
+		if err != nil {
+			t.Errorf("%s: %s", e.name, err)
+			continue
+		}
+
+		expires := l.Expires
+		err = e.locker.Extend(ctx, l, time.Hour)
+		if err != nil {
+			t.Errorf("%s: %s", e.name, err)
+		}
+
+		if !l.Expires.After(expires) {
+			t.Errorf("%s: expiry was not moved forward", e.name)
+		}
+
+		_ = e.locker.Release(ctx, l)
+
+		err = e.locker.Extend(ctx, l, time.Hour)
+		if !errors.Is(err, ErrNotHeld) {
+			t.Errorf("%s: expected ErrNotHeld extending a released lock, got %v", e.name, err)
+		}
+	}
+}
+
+func TestWait(t *testing.T) {
+	ctx := context.Background()
+
+	l, err := testMemoryLocker.Acquire(ctx, "wait", 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l2, err := Wait(ctx, &testMemoryLocker, "wait", time.Minute, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l2.Token <= l.Token {
+		t.Error("expected a new lock after the first one expired")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Millisecond)
+	defer cancel()
+
+	_, err = Wait(ctx, &testMemoryLocker, "wait", time.Minute, 10*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryLocker keeps locks in process memory. It is only useful for a single instance
+// and for tests. The zero value is ready to use.
+type MemoryLocker struct {
+	mu     sync.Mutex
+	locks  map[string]Lock
+	fences map[string]int64
+}
+
+func (m *MemoryLocker) Acquire(ctx context.Context, name string, ttl time.Duration) (*Lock, error) {
+	owner, err := newOwner()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.locks == nil {
+		m.locks = make(map[string]Lock)
+		m.fences = make(map[string]int64)
+	}
+
+	if held, ok := m.locks[name]; ok && time.Now().Before(held.Expires) {
+		return nil, ErrNotAcquired
+	}
+
+	m.fences[name]++
+	l := Lock{
+		Name:    name,
+		Token:   m.fences[name],
+		Expires: time.Now().Add(ttl),
+		owner:   owner,
+	}
+	m.locks[name] = l
+
+	return &l, nil
+}
+
+func (m *MemoryLocker) Release(ctx context.Context, l *Lock) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.holds(l) {
+		return ErrNotHeld
+	}
+
+	delete(m.locks, l.Name)
+
+	return nil
+}
+
+func (m *MemoryLocker) Extend(ctx context.Context, l *Lock, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.holds(l) {
+		return ErrNotHeld
+	}
+
+	l.Expires = time.Now().Add(ttl)
+	m.locks[l.Name] = *l
+
+	return nil
+}
+
+func (m *MemoryLocker) holds(l *Lock) bool {
+	held, ok := m.locks[l.Name]
+	return ok && held.owner == l.owner && time.Now().Before(held.Expires)
+}
@@ -0,0 +1,108 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// the lock key and its fence counter share a hash tag so the scripts work on a cluster
+var acquireScript = redis.NewScript(2, `
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("INCR", KEYS[2])
+end
+return 0`)
+
+var releaseScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+var extendScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// RedisLocker implements Locker with SET NX PX. Only the owner that set a key can
+// release or extend it.
+type RedisLocker struct {
+	Conn   *redis.Pool
+	Prefix string
+}
+
+func (r *RedisLocker) Acquire(ctx context.Context, name string, ttl time.Duration) (*Lock, error) {
+	owner, err := newOwner()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := r.Conn.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	token, err := redis.Int64(acquireScript.Do(conn, r.key(name), r.key(name)+":fence", owner, ttl.Milliseconds()))
+	if err != nil {
+		return nil, err
+	}
+
+	if token == 0 {
+		return nil, ErrNotAcquired
+	}
+
+	return &Lock{
+		Name:    name,
+		Token:   token,
+		Expires: time.Now().Add(ttl),
+		owner:   owner,
+	}, nil
+}
+
+func (r *RedisLocker) Release(ctx context.Context, l *Lock) error {
+	conn, err := r.Conn.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	n, err := redis.Int(releaseScript.Do(conn, r.key(l.Name), l.owner))
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNotHeld
+	}
+
+	return nil
+}
+
+func (r *RedisLocker) Extend(ctx context.Context, l *Lock, ttl time.Duration) error {
+	conn, err := r.Conn.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	n, err := redis.Int(extendScript.Do(conn, r.key(l.Name), l.owner, ttl.Milliseconds()))
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNotHeld
+	}
+
+	l.Expires = time.Now().Add(ttl)
+
+	return nil
+}
+
+func (r *RedisLocker) key(name string) string {
+	return fmt.Sprintf("%s:lock:{%s}", r.Prefix, name)
+}
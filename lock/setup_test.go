@@ -0,0 +1,52 @@
+package lock
+
+import (
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/gomodule/redigo/redis"
+)
+
+var testRedisLocker RedisLocker
+
+var testBadgerLocker BadgerLocker
+
+var testMemoryLocker MemoryLocker
+
+func TestMain(m *testing.M) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	pool := redis.Pool{
+		MaxIdle:     50,
+		MaxActive:   1000,
+		IdleTimeout: 240 * time.Second,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", s.Addr())
+		},
+	}
+
+	testRedisLocker.Conn = &pool
+	testRedisLocker.Prefix = "test-napoleon"
+
+	defer testRedisLocker.Conn.Close()
+
+	_ = os.RemoveAll("./testdata/tmp/badger")
+
+	err = os.MkdirAll("./testdata/tmp/badger", 0755)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, _ := badger.Open(badger.DefaultOptions("./testdata/tmp/badger"))
+	testBadgerLocker.Conn = db
+
+	os.Exit(m.Run())
+}
@@ -0,0 +1,84 @@
+package napoleon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/hilsonxhero/napoleon/auth"
+	"github.com/hilsonxhero/napoleon/mailer"
+)
+
+// createMailer sets up the transport named in MAILER_TRANSPORT: smtp, file, or log by
+// default. Mail templates live in views/mail, and are rendered by n.Render once
+// createRenderer has set it.
+func (n *Napoleon) createMailer() *mailer.Mail {
+	var transport mailer.Transport
+
+	switch os.Getenv("MAILER_TRANSPORT") {
+	case "smtp":
+		port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		if err != nil {
+			port = 587
+		}
+
+		transport = &mailer.SMTPTransport{
+			Host:       os.Getenv("SMTP_HOST"),
+			Port:       port,
+			Username:   os.Getenv("SMTP_USERNAME"),
+			Password:   os.Getenv("SMTP_PASSWORD"),
+			Encryption: os.Getenv("SMTP_ENCRYPTION"),
+		}
+	case "file":
+		path := os.Getenv("MAILER_PATH")
+		if path == "" {
+			path = fmt.Sprintf("%s/tmp/mail", n.RootPath)
+		}
+
+		transport = &mailer.FileTransport{Path: path}
+	default:
+		transport = &mailer.LogTransport{Log: n.InfoLog}
+	}
+
+	return &mailer.Mail{
+		Transport:   transport,
+		FromAddress: os.Getenv("MAILER_FROM_ADDRESS"),
+		FromName:    os.Getenv("MAILER_FROM_NAME"),
+		Tries:       3,
+		ErrorLog:    n.ErrorLog,
+		Jobs:        make(chan *mailer.Message, 100),
+	}
+}
+
+// mailNotifier sends the password reset and verification links of auth by mail, using
+// the templates created by "napoleon make auth".
+type mailNotifier struct {
+	mail *mailer.Mail
+}
+
+func (m *mailNotifier) SendPasswordReset(ctx context.Context, user auth.User, link string) error {
+	return m.send(user, "Reset your password", "password-reset", link)
+}
+
+func (m *mailNotifier) SendVerification(ctx context.Context, user auth.User, link string) error {
+	return m.send(user, "Verify your email address", "verify-email", link)
+}
+
+func (m *mailNotifier) send(user auth.User, subject, template, link string) error {
+	u, ok := user.(interface{ AuthEmail() string })
+	if !ok {
+		return errors.New("mail: user has no email address")
+	}
+
+	return m.mail.Queue(&mailer.Message{
+		To:       []string{u.AuthEmail()},
+		Subject:  subject,
+		Template: template,
+		Data: map[string]interface{}{
+			"Link": link,
+			"User": user,
+		},
+	})
+}
@@ -0,0 +1,177 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hilsonxhero/napoleon/render"
+)
+
+// Message is an email. HTML and Text are filled from Template when they are empty.
+type Message struct {
+	From        string
+	FromName    string
+	To          []string
+	Cc          []string
+	Bcc         []string
+	ReplyTo     string
+	Subject     string
+	Template    string
+	Data        interface{}
+	HTML        string
+	Text        string
+	Attachments []Attachment
+}
+
+// Attachment is a file sent with a message. Data is read from Path when it is nil.
+type Attachment struct {
+	Name        string
+	ContentType string
+	Path        string
+	Data        []byte
+}
+
+// Transport delivers rendered messages.
+type Transport interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Result reports the outcome of a message sent with Queue.
+type Result struct {
+	Message *Message
+	Error   error
+}
+
+// Mail renders and sends messages. Templates are the views mail/<name>.html and
+// mail/<name>.plain of Render, rendered by its engine, so mail shares the template cache,
+// functions and views of pages; the plain text part is optional.
+type Mail struct {
+	Transport   Transport
+	FromAddress string
+	FromName    string
+	Render      *render.Render
+	Tries       int
+	ErrorLog    *log.Logger
+
+	// Jobs holds messages for the worker started by ListenForMail, and Results gets
+	// the outcome of each when it is not nil
+	Jobs    chan *Message
+	Results chan Result
+}
+
+// Send renders msg and hands it to the transport, trying again on failure.
+func (m *Mail) Send(ctx context.Context, msg *Message) error {
+	if err := m.prepare(msg); err != nil {
+		return err
+	}
+
+	tries := m.Tries
+	if tries < 1 {
+		tries = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= tries; attempt++ {
+		err = m.Transport.Send(ctx, msg)
+		if err == nil {
+			return nil
+		}
+
+		if attempt < tries {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(attempt) * time.Second):
+			}
+		}
+	}
+
+	return fmt.Errorf("mailer: sending %q failed after %d attempt(s): %w", msg.Subject, tries, err)
+}
+
+// ErrQueueFull is returned by Queue when the worker has fallen behind by more messages
+// than Jobs holds.
+var ErrQueueFull = errors.New("mailer: queue is full")
+
+// Queue hands msg to the worker started by ListenForMail, so the caller does not wait
+// for the mail server. It never blocks; a full queue fails with ErrQueueFull.
+func (m *Mail) Queue(msg *Message) error {
+	select {
+	case m.Jobs <- msg:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// ListenForMail sends queued messages until ctx is done.
+func (m *Mail) ListenForMail(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-m.Jobs:
+			err := m.Send(ctx, msg)
+			if err != nil && m.ErrorLog != nil {
+				m.ErrorLog.Println(err)
+			}
+
+			if m.Results != nil {
+				m.Results <- Result{Message: msg, Error: err}
+			}
+		}
+	}
+}
+
+// prepare fills in the sender and renders the template of msg.
+func (m *Mail) prepare(msg *Message) error {
+	if len(msg.To) == 0 {
+		return errors.New("mailer: message has no recipients")
+	}
+
+	if msg.From == "" {
+		msg.From = m.FromAddress
+		if msg.FromName == "" {
+			msg.FromName = m.FromName
+		}
+	}
+
+	if msg.Template == "" || msg.HTML != "" || msg.Text != "" {
+		return nil
+	}
+
+	html, err := m.render(msg.Template+".html", msg.Data)
+	if err != nil {
+		return err
+	}
+	msg.HTML = html
+
+	// the plain text part is optional
+	text, err := m.render(msg.Template+".plain", msg.Data)
+	if err == nil {
+		msg.Text = text
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// render executes the view mail/name, as plain text when name is a .plain part.
+func (m *Mail) render(name string, data interface{}) (string, error) {
+	view := "mail/" + name
+	if m.Render == nil || !m.Render.Exists(view) {
+		return "", fmt.Errorf("mailer: template %s: %w", name, os.ErrNotExist)
+	}
+
+	if strings.HasSuffix(name, ".plain") {
+		return m.Render.Text(view, data)
+	}
+
+	return m.Render.String(view, data)
+}
@@ -0,0 +1,213 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMail_SendTemplates(t *testing.T) {
+	var tests = []struct {
+		name     string
+		renderer string
+		template string
+		data     interface{}
+		html     string
+		text     string
+	}{
+		{"jet", "jet", "welcome", map[string]string{"Name": "Jack"}, "<p>Hello, Jack</p>", "Hello, Jack"},
+		{"jet_link", "jet", "link", map[string]string{"Link": "http://a/b?x=1&y=2"},
+			`<a href="http://a/b?x=1&amp;y=2">Reset your password</a>`, "Reset your password: http://a/b?x=1&y=2"},
+		{"go", "go", "receipt", map[string]string{"Total": "$10"}, "<p>Total: $10</p>", "Total: $10 & tax"},
+		{"html_only", "go", "html-only", map[string]string{"Name": "<b>"}, "<p>&lt;b&gt;</p>", ""},
+	}
+
+	for _, e := range tests {
+		testTransport.Reset()
+		testMail.Render.Renderer = e.renderer
+
+		err := testMail.Send(context.Background(), &Message{
+			To:       []string{"you@there.com"},
+			Subject:  "Test",
+			Template: e.template,
+			Data:     e.data,
+		})
+		if err != nil {
+			t.Errorf("%s: %s", e.name, err)
+			continue
+		}
+
+		msg := testTransport.Last()
+		if strings.TrimSpace(msg.HTML) != e.html {
+			t.Errorf("%s: expected html %q, got %q", e.name, e.html, msg.HTML)
+		}
+		if strings.TrimSpace(msg.Text) != e.text {
+			t.Errorf("%s: expected text %q, got %q", e.name, e.text, msg.Text)
+		}
+		if msg.From != "me@here.com" || msg.FromName != "Napoleon" {
+			t.Errorf("%s: default sender not set", e.name)
+		}
+	}
+}
+
+func TestMail_SendErrors(t *testing.T) {
+	err := testMail.Send(context.Background(), &Message{Subject: "Nobody"})
+	if err == nil {
+		t.Error("no error for a message without recipients")
+	}
+
+	err = testMail.Send(context.Background(), &Message{To: []string{"you@there.com"}, Template: "no-file"})
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Error("expected os.ErrNotExist for a missing template, got", err)
+	}
+}
+
+// flakyTransport fails until it has been called fails times.
+type flakyTransport struct {
+	fails int
+	calls int
+}
+
+func (t *flakyTransport) Send(ctx context.Context, msg *Message) error {
+	t.calls++
+	if t.calls <= t.fails {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func TestMail_Queue(t *testing.T) {
+	transport := &flakyTransport{fails: 1}
+
+	m := Mail{
+		Transport:   transport,
+		FromAddress: "me@here.com",
+		Tries:       2,
+		Jobs:        make(chan *Message, 1),
+		Results:     make(chan Result, 1),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.ListenForMail(ctx)
+
+	if err := m.Queue(&Message{To: []string{"you@there.com"}, Text: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case res := <-m.Results:
+		if res.Error != nil {
+			t.Error("queued message failed after retry:", res.Error)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no result from the mail worker")
+	}
+
+	if transport.calls != 2 {
+		t.Errorf("expected 2 attempts, got %d", transport.calls)
+	}
+}
+
+func TestMail_QueueFull(t *testing.T) {
+	m := Mail{Jobs: make(chan *Message, 1)}
+
+	if err := m.Queue(&Message{To: []string{"you@there.com"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing is listening, so the second message does not fit
+	if err := m.Queue(&Message{To: []string{"you@there.com"}}); !errors.Is(err, ErrQueueFull) {
+		t.Error("expected ErrQueueFull, got", err)
+	}
+}
+
+func TestMessage_Bytes(t *testing.T) {
+	msg := &Message{
+		From:    "me@here.com",
+		To:      []string{"you@there.com"},
+		Bcc:     []string{"Secret <secret@there.com>"},
+		Subject: "Your invoice",
+		HTML:    "<p>Hi</p>",
+		Text:    "Hi",
+		Attachments: []Attachment{
+			{Name: "invoice.txt", Data: []byte("total: 10")},
+		},
+	}
+
+	data, err := msg.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw := string(data)
+	for _, want := range []string{"multipart/mixed", "multipart/alternative", "text/plain", "text/html",
+		"filename=invoice.txt", "Subject: Your invoice"} {
+		if !strings.Contains(raw, want) {
+			t.Errorf("message does not contain %q", want)
+		}
+	}
+
+	if strings.Contains(raw, "secret@there.com") {
+		t.Error("bcc recipient in the message headers")
+	}
+
+	if got := msg.Recipients(); len(got) != 2 || got[1] != "secret@there.com" {
+		t.Error("wrong recipients", got)
+	}
+}
+
+func TestMessage_BytesWithoutAttachments(t *testing.T) {
+	var tests = []struct {
+		name string
+		msg  *Message
+		want []string
+	}{
+		{"text", &Message{Text: "Reset your password"}, []string{"text/plain", "Reset your password"}},
+		{"html", &Message{HTML: "<p>Reset your password</p>"}, []string{"text/html", "<p>Reset your password</p>"}},
+		{"both", &Message{HTML: "<p>Hi html</p>", Text: "Hi text"}, []string{"multipart/alternative", "<p>Hi html</p>", "Hi text"}},
+	}
+
+	for _, e := range tests {
+		e.msg.From = "me@here.com"
+		e.msg.To = []string{"you@there.com"}
+
+		data, err := e.msg.Bytes()
+		if err != nil {
+			t.Errorf("%s: %v", e.name, err)
+			continue
+		}
+
+		_, body, ok := strings.Cut(string(data), "\r\n\r\n")
+		if !ok {
+			t.Errorf("%s: no body after the headers", e.name)
+			continue
+		}
+		for _, want := range e.want {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("%s: message does not contain %q", e.name, want)
+			}
+		}
+		if !strings.Contains(body, e.want[1]) {
+			t.Errorf("%s: body %q does not contain %q", e.name, body, e.want[1])
+		}
+	}
+}
+
+func TestFileTransport(t *testing.T) {
+	dir := t.TempDir()
+	transport := &FileTransport{Path: dir}
+
+	err := transport.Send(context.Background(), &Message{From: "me@here.com", To: []string{"you@there.com"}, Text: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 || !strings.HasSuffix(files[0].Name(), ".eml") {
+		t.Error("message not written to an .eml file")
+	}
+}
@@ -0,0 +1,199 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Bytes encodes msg as a MIME message: a multipart/alternative body with the text and
+// html parts, wrapped in multipart/mixed when there are attachments. Bcc recipients are
+// left out of the headers.
+func (msg *Message) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+
+	from := (&mail.Address{Name: msg.FromName, Address: msg.From}).String()
+	domain := "localhost"
+	if at := strings.LastIndexByte(msg.From, '@'); at >= 0 {
+		domain = msg.From[at+1:]
+	}
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(msg.To, ", "))
+	if len(msg.Cc) > 0 {
+		fmt.Fprintf(&buf, "Cc: %s\r\n", strings.Join(msg.Cc, ", "))
+	}
+	if msg.ReplyTo != "" {
+		fmt.Fprintf(&buf, "Reply-To: %s\r\n", msg.ReplyTo)
+	}
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", randomID(), domain)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if len(msg.Attachments) == 0 {
+		if err := msg.writeBody(&buf); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	mixed := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mixed.Boundary())
+
+	part, err := mixed.CreatePart(textproto.MIMEHeader{})
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	if err := msg.writeBody(&body); err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(body.Bytes()); err != nil {
+		return nil, err
+	}
+
+	for _, a := range msg.Attachments {
+		if err := writeAttachment(mixed, a); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeBody writes the content type header and the text and html parts.
+func (msg *Message) writeBody(buf *bytes.Buffer) error {
+	switch {
+	case msg.HTML != "" && msg.Text != "":
+		alt := multipart.NewWriter(buf)
+		fmt.Fprintf(buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", alt.Boundary())
+
+		if err := writeTextPart(alt, "text/plain", msg.Text); err != nil {
+			return err
+		}
+		if err := writeTextPart(alt, "text/html", msg.HTML); err != nil {
+			return err
+		}
+
+		return alt.Close()
+
+	case msg.HTML != "":
+		return writeSinglePart(buf, "text/html", msg.HTML)
+
+	default:
+		return writeSinglePart(buf, "text/plain", msg.Text)
+	}
+}
+
+func writeSinglePart(buf *bytes.Buffer, contentType, body string) error {
+	fmt.Fprintf(buf, "Content-Type: %s; charset=utf-8\r\n", contentType)
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	w := quotedprintable.NewWriter(buf)
+	if _, err := w.Write([]byte(body)); err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
+func writeTextPart(mw *multipart.Writer, contentType, body string) error {
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType + "; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+
+	w := quotedprintable.NewWriter(part)
+	if _, err := w.Write([]byte(body)); err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
+func writeAttachment(mw *multipart.Writer, a Attachment) error {
+	data := a.Data
+	if data == nil {
+		var err error
+		data, err = os.ReadFile(a.Path)
+		if err != nil {
+			return err
+		}
+	}
+
+	name := a.Name
+	if name == "" {
+		name = filepath.Base(a.Path)
+	}
+
+	contentType := a.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(name))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+	}
+
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType(contentType, map[string]string{"name": name})},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": name})},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return err
+	}
+
+	// base64 lines must not be longer than 76 characters
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		if _, err := part.Write([]byte(encoded[:76] + "\r\n")); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err = part.Write([]byte(encoded + "\r\n"))
+
+	return err
+}
+
+// Recipients returns every address the message goes to, including Bcc.
+func (msg *Message) Recipients() []string {
+	var all []string
+
+	for _, list := range [][]string{msg.To, msg.Cc, msg.Bcc} {
+		for _, addr := range list {
+			if parsed, err := mail.ParseAddress(addr); err == nil {
+				addr = parsed.Address
+			}
+			all = append(all, addr)
+		}
+	}
+
+	return all
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,34 @@
+package mailer
+
+import (
+	"os"
+	"testing"
+
+	"github.com/CloudyKit/jet/v6"
+	"github.com/hilsonxhero/napoleon/render"
+)
+
+var testTransport = &TestTransport{}
+
+var testMail = Mail{
+	Transport:   testTransport,
+	FromAddress: "me@here.com",
+	FromName:    "Napoleon",
+	Render: &render.Render{
+		RootPath: "./testdata",
+		JetViews: *jet.NewSet(
+			jet.NewOSFileSystemLoader("./testdata/views"),
+			jet.InDevelopmentMode(),
+		),
+		JetTextViews: jet.NewSet(
+			jet.NewOSFileSystemLoader("./testdata/views"),
+			jet.InDevelopmentMode(),
+			jet.WithSafeWriter(nil),
+		),
+	},
+	Tries: 1,
+}
+
+func TestMain(m *testing.M) {
+	os.Exit(m.Run())
+}
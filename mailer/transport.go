@@ -0,0 +1,184 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SMTPTransport sends mail through an SMTP server. Encryption is "starttls" (the
+// default), "tls" or "ssl" for implicit TLS, usually on port 465, or "none".
+type SMTPTransport struct {
+	Host       string
+	Port       int
+	Username   string
+	Password   string
+	Encryption string
+	Timeout    time.Duration
+}
+
+func (t *SMTPTransport) Send(ctx context.Context, msg *Message) error {
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	timeout := t.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+
+	addr := net.JoinHostPort(t.Host, fmt.Sprint(t.Port))
+	tlsConfig := &tls.Config{ServerName: t.Host, MinVersion: tls.VersionTLS12}
+	dialer := &net.Dialer{Timeout: timeout}
+
+	var conn net.Conn
+	switch strings.ToLower(t.Encryption) {
+	case "tls", "ssl":
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	default:
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	} else {
+		_ = conn.SetDeadline(time.Now().Add(2 * timeout))
+	}
+
+	client, err := smtp.NewClient(conn, t.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if strings.ToLower(t.Encryption) == "" || strings.ToLower(t.Encryption) == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("mailer: %s does not support STARTTLS", t.Host)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+
+	if t.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", t.Username, t.Password, t.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(msg.From); err != nil {
+		return err
+	}
+
+	for _, rcpt := range msg.Recipients() {
+		if err := client.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// FileTransport writes each message to an .eml file in Path, to be opened with a mail
+// client during development.
+type FileTransport struct {
+	Path string
+}
+
+func (t *FileTransport) Send(ctx context.Context, msg *Message) error {
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(t.Path, 0755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405"), randomID()[:8])
+
+	return os.WriteFile(filepath.Join(t.Path, name), data, 0644)
+}
+
+// LogTransport writes messages to a log instead of sending them.
+type LogTransport struct {
+	Log *log.Logger
+}
+
+func (t *LogTransport) Send(ctx context.Context, msg *Message) error {
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	t.Log.Printf("Mail to %s:\n%s", strings.Join(msg.Recipients(), ", "), data)
+
+	return nil
+}
+
+// TestTransport records messages instead of sending them, for assertions in tests.
+type TestTransport struct {
+	mu       sync.Mutex
+	messages []*Message
+}
+
+func (t *TestTransport) Send(ctx context.Context, msg *Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.messages = append(t.messages, msg)
+	return nil
+}
+
+// Messages returns the messages sent so far.
+func (t *TestTransport) Messages() []*Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]*Message{}, t.messages...)
+}
+
+// Last returns the last message sent, or nil.
+func (t *TestTransport) Last() *Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.messages) == 0 {
+		return nil
+	}
+	return t.messages[len(t.messages)-1]
+}
+
+// Reset forgets the messages sent so far.
+func (t *TestTransport) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.messages = nil
+}
@@ -4,13 +4,45 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/hilsonxhero/napoleon/session"
 	"github.com/justinas/nosurf"
 )
 
 func (n *Napoleon) SessionLoad(next http.Handler) http.Handler {
+	// cookie sessions rewrite the whole cookie, so they need their own middleware
+	if store, ok := n.Session.Store.(*session.CookieStore); ok {
+		return store.LoadAndSave(&n.Session)(next)
+	}
+
 	return n.Session.LoadAndSave(next)
 }
 
+// TrackSession keeps the device details of logged in sessions up to date, so that users
+// can see and revoke their sessions.
+func (n *Napoleon) TrackSession(next http.Handler) http.Handler {
+	return n.Sessions.Track(next)
+}
+
+// RememberMe logs guests back in from their remember me cookie. It does nothing when
+// there is no database to look the cookie up in.
+func (n *Napoleon) RememberMe(next http.Handler) http.Handler {
+	if n.Auth == nil {
+		return next
+	}
+
+	return n.Auth.RememberMe(next)
+}
+
+// Authorization lets the permission checks of a request share one load of the user's
+// roles and permissions.
+func (n *Napoleon) Authorization(next http.Handler) http.Handler {
+	if n.Authz == nil {
+		return next
+	}
+
+	return n.Authz.Middleware(next)
+}
+
 func (n *Napoleon) NoSurf(next http.Handler) http.Handler {
 	csrfHandler := nosurf.New(next)
 	secure, _ := strconv.ParseBool(n.config.cookie.srcure)
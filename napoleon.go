@@ -1,7 +1,12 @@
 package napoleon
 
 import (
+	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"html/template"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
@@ -13,11 +18,18 @@ import (
 	"github.com/dgraph-io/badger/v3"
 	"github.com/go-chi/chi/v5"
 	"github.com/gomodule/redigo/redis"
+	"github.com/hilsonxhero/napoleon/auth"
+	"github.com/hilsonxhero/napoleon/authz"
 	"github.com/hilsonxhero/napoleon/cache"
+	"github.com/hilsonxhero/napoleon/jwt"
+	"github.com/hilsonxhero/napoleon/lock"
+	"github.com/hilsonxhero/napoleon/mailer"
+	"github.com/hilsonxhero/napoleon/oauth"
+	"github.com/hilsonxhero/napoleon/queue"
 	"github.com/hilsonxhero/napoleon/render"
+	"github.com/hilsonxhero/napoleon/scheduler"
 	"github.com/hilsonxhero/napoleon/session"
 	"github.com/joho/godotenv"
-	"github.com/robfig/cron/v3"
 )
 
 const version = "1.0.0"
@@ -37,12 +49,22 @@ type Napoleon struct {
 	Routes        *chi.Mux
 	Render        *render.Render
 	Session       scs.SessionManager
+	Sessions      *session.Manager
+	Auth          *auth.Auth
+	Mail          *mailer.Mail
+	OAuth         *oauth.Client
+	Authz         *authz.Authorizer
+	JWT           *jwt.JWT
 	DB            Database
 	JetViews      jet.Set
+	jetTextViews  *jet.Set
+	Views         fs.FS
 	config        config
 	EncryptionKey string
 	Cache         cache.Cache
-	Scheduler     *cron.Cron
+	Lock          lock.Locker
+	Queue         *queue.Queue
+	Scheduler     *scheduler.Scheduler
 }
 
 type config struct {
@@ -92,25 +114,8 @@ func (n *Napoleon) New(rootPath string) error {
 		}
 	}
 
-	scheduler := cron.New()
-	n.Scheduler = scheduler
-
-	if os.Getenv("CACHE") == "redis" || os.Getenv("SESSION_TYPE") == "redis" {
-		myRedisCache = n.createClientRedisCache()
-		n.Cache = myRedisCache
-	}
-
-	if os.Getenv("CACHE") == "badger" {
-		myBadgerCache = n.createClientBadgerCache()
-		n.Cache = myBadgerCache
-
-		_, err = n.Scheduler.AddFunc("@daily", func() {
-			_ = myBadgerCache.Conn.RunValueLogGC(0.7)
-		})
-
-		if err != nil {
-			return err
-		}
+	n.Scheduler = &scheduler.Scheduler{
+		ErrorLog: errorLog,
 	}
 
 	n.ErrorLog = errorLog
@@ -123,59 +128,135 @@ func (n *Napoleon) New(rootPath string) error {
 		port:     os.Getenv("PORT"),
 		renderer: os.Getenv("RENDERER"),
 		cookie: cookieConfig{
-			name:     os.Getenv("COOKIE_NAME"),
-			lifetime: os.Getenv("COOKIE_LIFETIME"),
-			parsist:  os.Getenv("COOKIE_PARSIST"),
-			srcure:   os.Getenv("SESSION_SECURE"),
-			domain:   os.Getenv("SESSION_DOMAIN"),
+			name:        os.Getenv("COOKIE_NAME"),
+			lifetime:    os.Getenv("COOKIE_LIFETIME"),
+			parsist:     os.Getenv("COOKIE_PARSIST"),
+			srcure:      os.Getenv("SESSION_SECURE"),
+			domain:      os.Getenv("SESSION_DOMAIN"),
+			path:        os.Getenv("COOKIE_PATH"),
+			sameSite:    os.Getenv("COOKIE_SAMESITE"),
+			httpOnly:    os.Getenv("COOKIE_HTTP_ONLY"),
+			partitioned: os.Getenv("COOKIE_PARTITIONED"),
 		},
-		sessionType: os.Getenv("SESSION_TYPE"),
+		sessionType: n.sessionType(),
 		database: DatabaseConfig{
 			database: os.Getenv("DATABASE_TYPE"),
 			dsn:      n.BuildDSN(),
 		},
 
-		redis: RedisConfig{
-			host:     os.Getenv("REDIS_HOST"),
-			password: os.Getenv("REDIS_PASSWORD"),
-			prefix:   os.Getenv("REDIS_PREFIX"),
-		},
+		redis: n.redisConfigFromEnv(),
+	}
+
+	if n.usesDriver("redis") {
+		redisPool = n.createRedisPool()
+	}
+
+	if n.usesDriver("badger") {
+		badgerConn = n.createBadgerConn()
+
+		err = n.Scheduler.Add("badger-gc", "@daily", func(ctx context.Context) error {
+			err := badgerConn.RunValueLogGC(0.7)
+			if errors.Is(err, badger.ErrNoRewrite) {
+				return nil
+			}
+			return err
+		})
+
+		if err != nil {
+			return err
+		}
 	}
 
+	if os.Getenv("CACHE") == "redis" || os.Getenv("SESSION_TYPE") == "redis" {
+		myRedisCache = n.createClientRedisCache()
+		n.Cache = myRedisCache
+	}
+
+	if os.Getenv("CACHE") == "badger" {
+		myBadgerCache = n.createClientBadgerCache()
+		n.Cache = myBadgerCache
+	}
+
+	n.Lock = n.createLocker()
+	n.Scheduler.Locker = n.Lock
+	n.Scheduler.Cache = n.Cache
+	n.Queue = n.createQueue()
+
+	n.EncryptionKey = os.Getenv("KEY")
+
 	sess := session.Session{
-		CookieLifetime: n.config.cookie.lifetime,
-		CookiePersist:  n.config.cookie.parsist,
-		CookieName:     n.config.cookie.name,
-		SessionType:    n.config.sessionType,
-		CookieDomain:   n.config.cookie.domain,
-		DBPool:         n.DB.Pool,
+		CookieLifetime:    n.config.cookie.lifetime,
+		CookiePersist:     n.config.cookie.parsist,
+		CookieName:        n.config.cookie.name,
+		SessionType:       n.config.sessionType,
+		CookieDomain:      n.config.cookie.domain,
+		CookieSecure:      n.config.cookie.srcure,
+		CookiePath:        n.config.cookie.path,
+		CookieSameSite:    n.config.cookie.sameSite,
+		CookieHTTPOnly:    n.config.cookie.httpOnly,
+		CookiePartitioned: n.config.cookie.partitioned,
+		DBPool:            n.DB.Pool,
+		Encrypter:         &Encryption{Key: []byte(n.EncryptionKey)},
+		EncryptionKey:     n.EncryptionKey,
 	}
 
 	switch n.config.sessionType {
 	case "redis":
-		sess.RedisPool = myRedisCache.Conn
+		sess.RedisPool = redisPool
+		sess.RedisPrefix = n.config.redis.prefix
+	case "badger":
+		sess.BadgerConn = badgerConn
 	case "mysql", "postgres", "mariadb", "postgresql":
 		sess.DBPool = n.DB.Pool
 	}
-	n.Session = *sess.InitSession()
+	sm, err := sess.InitSession()
+	if err != nil {
+		return err
+	}
+	n.Session = *sm
+	n.Sessions = &session.Manager{
+		Session: &n.Session,
+		Index:   sess.NewIndex(),
+	}
 
-	n.EncryptionKey = os.Getenv("KEY")
+	n.Mail = n.createMailer()
+
+	if n.DB.Pool != nil {
+		n.Auth, err = n.createAuth()
+		if err != nil {
+			return err
+		}
+		n.Authz = n.createAuthz()
+	}
+
+	n.JWT, err = n.createJWT()
+	if err != nil {
+		return err
+	}
+
+	if os.Getenv("OAUTH_PROVIDERS") != "" {
+		n.OAuth = n.createOAuth()
+	}
+
+	var loader jet.Loader = jet.NewOSFileSystemLoader(fmt.Sprintf("%s/views", rootPath))
+	var options []jet.Option
 	if n.Debug {
-		var views = jet.NewSet(
-			jet.NewOSFileSystemLoader(fmt.Sprintf("%s/views", rootPath)),
-			jet.InDevelopmentMode(),
-		)
-		n.JetViews = *views
-	} else {
-		var views = jet.NewSet(
-			jet.NewOSFileSystemLoader(fmt.Sprintf("%s/views", rootPath)),
-			// jet.InDevelopmentMode(),
-		)
-		n.JetViews = *views
+		options = append(options, jet.InDevelopmentMode())
+	} else if n.Views != nil {
+		// views set by the application before New, such as an embed.FS; Debug mode
+		// reads the directory instead, so that changes show up without a rebuild
+		loader = render.NewFSLoader(n.Views)
 	}
 
+	n.JetViews = *jet.NewSet(loader, options...)
+	// plain text, such as the text part of mail, must not be html escaped
+	n.jetTextViews = jet.NewSet(loader, append(options, jet.WithSafeWriter(nil))...)
+
 	n.createRenderer()
 
+	n.Mail.Render = n.Render
+	go n.Mail.ListenForMail(context.Background())
+
 	return nil
 }
 func (n *Napoleon) Init(p initPaths) error {
@@ -212,8 +293,19 @@ func (n *Napoleon) ListenAndServe() {
 		defer badgerConn.Close()
 	}
 
+	ran, err := n.runCommand(os.Args[1:])
+	if ran {
+		if err != nil {
+			n.ErrorLog.Fatal(err)
+		}
+		return
+	}
+
+	n.Scheduler.Start()
+	defer n.Scheduler.Stop(context.Background())
+
 	n.InfoLog.Printf("Listening on port %s", os.Getenv("PORT"))
-	err := srv.ListenAndServe()
+	err = srv.ListenAndServe()
 	n.ErrorLog.Fatal(err)
 }
 
@@ -237,19 +329,48 @@ func (n *Napoleon) startLogegrs() (*log.Logger, *log.Logger) {
 
 func (n *Napoleon) createRenderer() {
 	myRenderer := render.Render{
-		Renderer: n.config.renderer,
-		RootPath: n.RootPath,
-		Port:     n.config.port,
-		JetViews: n.JetViews,
-		Session:  n.Session,
+		Renderer:     n.config.renderer,
+		RootPath:     n.RootPath,
+		Port:         n.config.port,
+		JetViews:     n.JetViews,
+		JetTextViews: n.jetTextViews,
+		Session:      n.Session,
+		Debug:        n.Debug,
+		FuncMap:      template.FuncMap{},
+		// shown when a page fails to render, if the application has it
+		ErrorView: "errors/500",
+		BaseURL:   n.appURL(),
+		Version:   n.Version,
+	}
+
+	if !n.Debug {
+		myRenderer.Views = n.Views
+	}
+
+	if n.Auth != nil {
+		myRenderer.IsAuthenticated = n.Auth.Check
+	}
+
+	if n.Authz != nil {
+		myRenderer.Can = func(r *http.Request, action string, resource interface{}) bool {
+			return n.Authz.Can(r.Context(), action, resource)
+		}
 	}
 
 	n.Render = &myRenderer
+	n.Render.AddDefaultFuncs()
+
+	// parse go templates up front in production, so broken templates fail at start up
+	if n.config.renderer == "go" && !n.Debug {
+		if err := n.Render.CreateTemplateCache(); err != nil {
+			n.ErrorLog.Println(err)
+		}
+	}
 }
 
 func (n *Napoleon) createClientRedisCache() *cache.RedisCache {
 	cacheClient := cache.RedisCache{
-		Conn:   n.createRedisPool(),
+		Conn:   redisPool,
 		Prefix: n.config.redis.prefix,
 	}
 
@@ -258,26 +379,245 @@ func (n *Napoleon) createClientRedisCache() *cache.RedisCache {
 
 func (n *Napoleon) createClientBadgerCache() *cache.BadgerCache {
 	cacheClient := cache.BadgerCache{
-		Conn: n.createBadgerConn(),
+		Conn: badgerConn,
 	}
 
 	return &cacheClient
 }
 
-func (n *Napoleon) createRedisPool() *redis.Pool {
-	return &redis.Pool{
-		MaxIdle:     50,
-		MaxActive:   10000,
-		IdleTimeout: 240 * time.Second,
-		Dial: func() (redis.Conn, error) {
-			return redis.Dial("tcp", n.config.redis.host, redis.DialPassword(n.config.redis.password))
-		},
+// createLocker picks the distributed lock driver from LOCK (redis, badger, database or
+// memory), falling back to the cache driver when LOCK is not set.
+func (n *Napoleon) createLocker() lock.Locker {
+	driver := os.Getenv("LOCK")
+	if driver == "" {
+		driver = os.Getenv("CACHE")
+	}
 
-		TestOnBorrow: func(c redis.Conn, t time.Time) error {
-			_, err := c.Do("PING")
-			return err
+	switch driver {
+	case "redis":
+		return &lock.RedisLocker{
+			Conn:   redisPool,
+			Prefix: n.config.redis.prefix,
+		}
+	case "badger":
+		return &lock.BadgerLocker{
+			Conn: badgerConn,
+		}
+	case "database":
+		if n.DB.Pool != nil {
+			return &lock.DatabaseLocker{
+				DB:     n.DB.Pool,
+				DBType: n.DB.DataType,
+			}
+		}
+	}
+
+	return &lock.MemoryLocker{}
+}
+
+// createAuth sets up authentication against the users table, hashing passwords with the
+// algorithm named in HASHER. Password reset and verification links are signed with KEY,
+// so it fails without one.
+func (n *Napoleon) createAuth() (*auth.Auth, error) {
+	if n.EncryptionKey == "" {
+		return nil, auth.ErrNoSigningKey
+	}
+
+	secure, _ := strconv.ParseBool(n.config.cookie.srcure)
+	provider := &auth.SQLProvider{
+		DB:     n.DB.Pool,
+		DBType: n.DB.DataType,
+	}
+
+	return &auth.Auth{
+		Sessions: n.Sessions,
+		Provider: provider,
+		Remember: provider,
+		Tokens:   provider,
+		Hasher:   auth.NewHasher(os.Getenv("HASHER")),
+		Secure:   secure,
+		Domain:   n.config.cookie.domain,
+		Signer:   &auth.Signer{Key: []byte(n.EncryptionKey)},
+		Notifier: &mailNotifier{mail: n.Mail},
+		BaseURL:  n.appURL(),
+		Cache:    n.Cache,
+
+		TwoFactor: provider,
+		Encrypter: &Encryption{Key: []byte(n.EncryptionKey)},
+		Issuer:    n.appName(),
+
+		ErrorHandler: n.WriteProblem,
+	}, nil
+}
+
+// createAuthz sets up authorization with the roles and permissions tables created by
+// "make auth --rbac". Users with the role in AUTHZ_SUPER_ROLE are allowed everything.
+func (n *Napoleon) createAuthz() *authz.Authorizer {
+	return &authz.Authorizer{
+		Store: &authz.SQLStore{
+			DB:     n.DB.Pool,
+			DBType: n.DB.DataType,
 		},
+		UserID: func(ctx context.Context) int {
+			// api requests are authenticated by token rather than session
+			if user := auth.UserFromContext(ctx); user != nil {
+				return user.AuthID()
+			}
+			if claims := jwt.ClaimsFromContext(ctx); claims != nil {
+				return claims.UserID()
+			}
+			return n.Sessions.UserID(ctx)
+		},
+		SuperRole:    os.Getenv("AUTHZ_SUPER_ROLE"),
+		ErrorHandler: n.WriteProblem,
+	}
+}
+
+// createJWT sets up access and refresh tokens for api clients. Tokens are signed with
+// the private keys in JWT_KEYS_PATH, using the one named in JWT_KID, or else with
+// JWT_SECRET, which defaults to a key derived from KEY; without any of them it fails,
+// rather than sign with a key anyone can derive. Refresh tokens are kept in the
+// refresh_tokens table created by "make jwt" when there is a database.
+func (n *Napoleon) createJWT() (*jwt.JWT, error) {
+	j := &jwt.JWT{
+		SigningKey:    os.Getenv("JWT_KID"),
+		Issuer:        n.appURL(),
+		Audience:      os.Getenv("JWT_AUDIENCE"),
+		RefreshTokens: &jwt.MemoryRefreshStore{},
+		Cache:         n.Cache,
+		ErrorHandler:  n.WriteProblem,
+	}
+
+	j.AccessTTL, _ = time.ParseDuration(os.Getenv("JWT_ACCESS_TTL"))
+	j.RefreshTTL, _ = time.ParseDuration(os.Getenv("JWT_REFRESH_TTL"))
+
+	if path := os.Getenv("JWT_KEYS_PATH"); path != "" {
+		keys, err := jwt.LoadKeys(path)
+		if err != nil {
+			return nil, err
+		}
+		j.Keys = keys
+	} else {
+		secret := []byte(os.Getenv("JWT_SECRET"))
+		if len(secret) == 0 {
+			if n.EncryptionKey == "" {
+				return nil, fmt.Errorf("%w: set JWT_SECRET, JWT_KEYS_PATH or KEY", jwt.ErrNoKeys)
+			}
+			sum := sha256.Sum256([]byte("napoleon jwt:" + n.EncryptionKey))
+			secret = sum[:]
+		}
+		j.Keys = []*jwt.Key{jwt.NewHMACKey(os.Getenv("JWT_KID"), secret)}
+	}
+
+	if n.DB.Pool != nil {
+		j.RefreshTokens = &jwt.SQLRefreshStore{
+			DB:     n.DB.Pool,
+			DBType: n.DB.DataType,
+		}
+	}
+
+	if n.Auth != nil {
+		j.Active = n.Auth.Active
+	}
+
+	return j, nil
+}
+
+// createOAuth sets up sign in with the providers named in OAUTH_PROVIDERS, mapping their
+// users onto the users table through user_identities.
+func (n *Napoleon) createOAuth() *oauth.Client {
+	client := &oauth.Client{
+		Providers: oauth.ProvidersFromEnv(n.appURL()),
+		Session:   &n.Session,
 	}
+
+	if n.DB.Pool != nil {
+		client.Identities = &oauth.SQLIdentityStore{
+			DB:     n.DB.Pool,
+			DBType: n.DB.DataType,
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := client.Discover(ctx); err != nil {
+		n.ErrorLog.Println(err)
+	}
+
+	return client
+}
+
+// appName returns APP_NAME, the name users see for the application in their
+// authenticator app.
+func (n *Napoleon) appName() string {
+	if name := os.Getenv("APP_NAME"); name != "" {
+		return name
+	}
+
+	return n.AppName
+}
+
+// appURL returns APP_URL, the address links in emails point to.
+func (n *Napoleon) appURL() string {
+	if url := os.Getenv("APP_URL"); url != "" {
+		return url
+	}
+
+	return "http://localhost:" + n.config.port
+}
+
+// createQueue sets up the job queue with the driver named in QUEUE (redis, database or
+// memory). Handlers are registered by the application on n.Queue.
+func (n *Napoleon) createQueue() *queue.Queue {
+	tries, err := strconv.Atoi(os.Getenv("QUEUE_TRIES"))
+	if err != nil {
+		tries = 3
+	}
+
+	q := &queue.Queue{
+		Driver:   &queue.MemoryDriver{},
+		Tries:    tries,
+		ErrorLog: n.ErrorLog,
+	}
+
+	switch os.Getenv("QUEUE") {
+	case "redis":
+		q.Driver = &queue.RedisDriver{
+			Conn:   redisPool,
+			Prefix: n.config.redis.prefix,
+		}
+	case "database":
+		if n.DB.Pool != nil {
+			q.Driver = &queue.DatabaseDriver{
+				DB:     n.DB.Pool,
+				DBType: n.DB.DataType,
+			}
+		}
+	}
+
+	return q
+}
+
+// sessionType returns SESSION_TYPE, where "cache" means the same store as the cache.
+func (n *Napoleon) sessionType() string {
+	sessionType := os.Getenv("SESSION_TYPE")
+	if sessionType == "cache" {
+		return os.Getenv("CACHE")
+	}
+
+	return sessionType
+}
+
+// usesDriver reports whether the cache, session, lock or queue is configured to use driver.
+func (n *Napoleon) usesDriver(driver string) bool {
+	for _, key := range []string{"CACHE", "SESSION_TYPE", "LOCK", "QUEUE"} {
+		if os.Getenv(key) == driver {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (n *Napoleon) createBadgerConn() *badger.DB {
@@ -0,0 +1,35 @@
+package napoleon
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hilsonxhero/napoleon/auth"
+	"github.com/hilsonxhero/napoleon/jwt"
+)
+
+func TestNapoleon_CreateJWT(t *testing.T) {
+	t.Setenv("JWT_KEYS_PATH", "")
+	t.Setenv("JWT_SECRET", "")
+
+	// a key derived from nothing would be known to everyone
+	if _, err := (&Napoleon{}).createJWT(); !errors.Is(err, jwt.ErrNoKeys) {
+		t.Error("expected ErrNoKeys without a secret, got", err)
+	}
+
+	j, err := (&Napoleon{EncryptionKey: "0123456789abcdef0123456789abcdef"}).createJWT()
+	if err != nil || len(j.Keys) != 1 {
+		t.Errorf("expected a key derived from KEY, got %v", err)
+	}
+
+	t.Setenv("JWT_SECRET", "secret")
+	if _, err := (&Napoleon{}).createJWT(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNapoleon_CreateAuthWithoutKey(t *testing.T) {
+	if _, err := (&Napoleon{}).createAuth(); !errors.Is(err, auth.ErrNoSigningKey) {
+		t.Error("expected ErrNoSigningKey without KEY, got", err)
+	}
+}
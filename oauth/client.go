@@ -0,0 +1,319 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+var (
+	// ErrUnknownProvider is returned for a provider name that is not configured.
+	ErrUnknownProvider = errors.New("oauth: unknown provider")
+	// ErrInvalidState is returned when the callback does not belong to a login started
+	// in this session, which is what a forged or replayed callback looks like.
+	ErrInvalidState = errors.New("oauth: invalid state")
+	// ErrInvalidIDToken is returned when the ID token fails validation.
+	ErrInvalidIDToken = errors.New("oauth: invalid id token")
+	// ErrNoSubject is returned when the provider does not say who the user is.
+	ErrNoSubject = errors.New("oauth: provider returned no subject")
+)
+
+// session keys for the login in progress; one per provider, so that two tabs logging in
+// with different providers do not overwrite each other
+const (
+	stateKey    = "oauth.%s.state"
+	nonceKey    = "oauth.%s.nonce"
+	verifierKey = "oauth.%s.verifier"
+)
+
+// Identity is the user as described by a provider.
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Claims        map[string]interface{}
+	Token         *oauth2.Token
+}
+
+// Client signs users in with the configured providers, using the authorization code flow
+// with PKCE. The state, nonce and code verifier of a login in progress are kept in
+// Session, which must be loaded for both the redirect and the callback.
+type Client struct {
+	Providers  map[string]*Provider
+	Session    *scs.SessionManager
+	Identities IdentityStore
+	HTTPClient *http.Client
+	// Leeway is the clock skew allowed when checking ID token times.
+	Leeway time.Duration
+}
+
+// Provider returns the provider called name.
+func (c *Client) Provider(name string) (*Provider, error) {
+	p, ok := c.Providers[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+	}
+
+	return p, nil
+}
+
+// Discover reads the discovery documents of all OpenID Connect providers.
+func (c *Client) Discover(ctx context.Context) error {
+	for _, p := range c.Providers {
+		if err := p.Discover(ctx, c.httpClient()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Redirect starts a login with the provider called name by sending the user to its
+// authorization page.
+func (c *Client) Redirect(w http.ResponseWriter, r *http.Request, name string) error {
+	p, err := c.Provider(name)
+	if err != nil {
+		return err
+	}
+
+	state, err := randomString()
+	if err != nil {
+		return err
+	}
+
+	nonce, err := randomString()
+	if err != nil {
+		return err
+	}
+
+	verifier := oauth2.GenerateVerifier()
+
+	ctx := r.Context()
+	c.Session.Put(ctx, fmt.Sprintf(stateKey, p.Name), state)
+	c.Session.Put(ctx, fmt.Sprintf(nonceKey, p.Name), nonce)
+	c.Session.Put(ctx, fmt.Sprintf(verifierKey, p.Name), verifier)
+
+	opts := []oauth2.AuthCodeOption{oauth2.S256ChallengeOption(verifier)}
+	if p.oidc() {
+		opts = append(opts, oauth2.SetAuthURLParam("nonce", nonce))
+	}
+
+	http.Redirect(w, r, p.config().AuthCodeURL(state, opts...), http.StatusFound)
+
+	return nil
+}
+
+// Callback completes a login with the provider called name: it checks the state,
+// exchanges the code for tokens, validates the ID token and returns the identity.
+func (c *Client) Callback(r *http.Request, name string) (*Identity, error) {
+	p, err := c.Provider(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := r.Context()
+
+	// the values are single use, whatever the outcome
+	state := c.Session.PopString(ctx, fmt.Sprintf(stateKey, p.Name))
+	nonce := c.Session.PopString(ctx, fmt.Sprintf(nonceKey, p.Name))
+	verifier := c.Session.PopString(ctx, fmt.Sprintf(verifierKey, p.Name))
+
+	query := r.URL.Query()
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(query.Get("state"))) != 1 {
+		return nil, ErrInvalidState
+	}
+
+	if e := query.Get("error"); e != "" {
+		return nil, fmt.Errorf("oauth: %s: %s %s", p.Name, e, query.Get("error_description"))
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, c.httpClient())
+
+	token, err := p.config().Exchange(ctx, query.Get("code"), oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{
+		Provider: p.Name,
+		Claims:   make(map[string]interface{}),
+		Token:    token,
+	}
+
+	if p.oidc() {
+		raw, _ := token.Extra("id_token").(string)
+		claims, err := c.verifyIDToken(ctx, p, raw, nonce)
+		if err != nil {
+			return nil, err
+		}
+		identity.Claims = claims
+	}
+
+	if p.UserInfoURL != "" {
+		info, err := c.userInfo(ctx, p, token)
+		if err != nil {
+			return nil, err
+		}
+
+		// the ID token is authoritative for the subject
+		if sub, ok := identity.Claims["sub"]; ok && fmt.Sprint(info["sub"]) != fmt.Sprint(sub) {
+			return nil, fmt.Errorf("oauth: %s userinfo subject does not match the id token", p.Name)
+		}
+
+		for k, v := range info {
+			if _, ok := identity.Claims[k]; !ok {
+				identity.Claims[k] = v
+			}
+		}
+	}
+
+	identity.fromClaims()
+	if identity.Subject == "" {
+		return nil, ErrNoSubject
+	}
+
+	return identity, nil
+}
+
+// Login completes a login like Callback, and returns the id of the user the identity
+// belongs to, linking or creating the user through Identities as needed.
+func (c *Client) Login(r *http.Request, name string) (int, *Identity, error) {
+	identity, err := c.Callback(r, name)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	userID, err := c.Identities.Resolve(r.Context(), identity)
+	if err != nil {
+		return 0, identity, err
+	}
+
+	return userID, identity, nil
+}
+
+// verifyIDToken checks the signature, issuer, audience, times and nonce of an ID token.
+func (c *Client) verifyIDToken(ctx context.Context, p *Provider, raw, nonce string) (map[string]interface{}, error) {
+	if raw == "" {
+		return nil, fmt.Errorf("%w: missing", ErrInvalidIDToken)
+	}
+
+	if p.keys == nil {
+		p.keys = &keySet{url: p.JWKSURL, client: c.httpClient()}
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.keys.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(c.Leeway),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	if got, _ := claims["nonce"].(string); nonce == "" || subtle.ConstantTimeCompare([]byte(got), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+
+	// with several audiences the token must be meant for us as authorized party
+	if aud, ok := claims["aud"].([]interface{}); ok && len(aud) > 1 {
+		if azp, _ := claims["azp"].(string); azp != p.ClientID {
+			return nil, fmt.Errorf("%w: wrong authorized party", ErrInvalidIDToken)
+		}
+	}
+
+	return claims, nil
+}
+
+func (c *Client) userInfo(ctx context.Context, p *Provider, token *oauth2.Token) (map[string]interface{}, error) {
+	res, err := p.config().Client(ctx, token).Get(p.UserInfoURL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oauth: %s userinfo returned %s", p.Name, res.Status)
+	}
+
+	info := make(map[string]interface{})
+	if err := json.NewDecoder(res.Body).Decode(&info); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+
+	return &http.Client{Timeout: 10 * time.Second}
+}
+
+// fromClaims fills the identity from the standard claims, falling back to the fields
+// common plain OAuth2 providers use.
+func (i *Identity) fromClaims() {
+subject:
+	for _, key := range []string{"sub", "id"} {
+		if v, ok := i.Claims[key]; ok && v != nil {
+			switch v := v.(type) {
+			case float64:
+				// json numbers, such as github user ids
+				i.Subject = fmt.Sprintf("%.0f", v)
+			default:
+				i.Subject = fmt.Sprint(v)
+			}
+			break subject
+		}
+	}
+
+	i.Email, _ = i.Claims["email"].(string)
+	i.Email = strings.ToLower(strings.TrimSpace(i.Email))
+
+	switch v := i.Claims["email_verified"].(type) {
+	case bool:
+		i.EmailVerified = v
+	case string:
+		// some providers send it as a string
+		i.EmailVerified = v == "true"
+	}
+
+	i.Name, _ = i.Claims["name"].(string)
+	if i.Name == "" {
+		i.Name, _ = i.Claims["login"].(string)
+	}
+}
+
+func (p *Provider) oidc() bool {
+	return p.Issuer != ""
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,239 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func get(t *testing.T, c *http.Client, url string) (int, string) {
+	t.Helper()
+
+	res, err := c.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(res.Body)
+
+	return res.StatusCode, strings.TrimSpace(string(body))
+}
+
+func TestClient_Login(t *testing.T) {
+	app := newTestApp(t)
+
+	status, body := get(t, app.browser, app.URL+"/login")
+	if status != http.StatusOK || body != "1" {
+		t.Fatalf("expected user 1 to be signed in, got %d %q", status, body)
+	}
+
+	// signing in again finds the identity instead of creating a user
+	status, body = get(t, app.browser, app.URL+"/login")
+	if status != http.StatusOK || body != "1" {
+		t.Errorf("expected the same user, got %d %q", status, body)
+	}
+
+	if len(app.identities.emails) != 1 {
+		t.Errorf("expected one user, got %d", len(app.identities.emails))
+	}
+}
+
+func TestClient_Redirect(t *testing.T) {
+	app := newTestApp(t)
+	app.browser.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	res, err := app.browser.Get(app.URL + "/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	location, _ := url.Parse(res.Header.Get("Location"))
+	q := location.Query()
+
+	if !strings.HasPrefix(location.String(), testStub.URL+"/authorize") {
+		t.Errorf("wrong redirect: %s", location)
+	}
+
+	for _, param := range []string{"state", "nonce", "code_challenge"} {
+		if q.Get(param) == "" {
+			t.Errorf("expected %s in the authorization url", param)
+		}
+	}
+
+	if q.Get("code_challenge_method") != "S256" {
+		t.Errorf("expected S256 code challenge, got %q", q.Get("code_challenge_method"))
+	}
+}
+
+func TestClient_Callback_InvalidState(t *testing.T) {
+	app := newTestApp(t)
+	app.browser.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	res, err := app.browser.Get(app.URL + "/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	// a callback for a login that was not started in this session
+	status, _ := get(t, app.browser, app.URL+"/callback?code=abc&state=forged")
+	if status != http.StatusUnauthorized {
+		t.Errorf("expected forged state to be rejected, got %d", status)
+	}
+
+	// a callback without any login in progress
+	status, _ = get(t, &http.Client{}, app.URL+"/callback?code=abc&state=")
+	if status != http.StatusUnauthorized {
+		t.Errorf("expected missing state to be rejected, got %d", status)
+	}
+}
+
+func TestClient_Callback_StateIsSingleUse(t *testing.T) {
+	app := newTestApp(t)
+
+	var callback string
+	app.browser.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if req.URL.Path == "/callback" {
+			callback = req.URL.String()
+		}
+		return nil
+	}
+
+	if status, _ := get(t, app.browser, app.URL+"/login"); status != http.StatusOK {
+		t.Fatalf("login failed with %d", status)
+	}
+
+	if status, _ := get(t, app.browser, callback); status != http.StatusUnauthorized {
+		t.Errorf("expected replayed callback to be rejected, got %d", status)
+	}
+}
+
+func TestClient_Login_UnverifiedEmail(t *testing.T) {
+	app := newTestApp(t)
+	app.identities.emails[testStub.User.Email] = 7
+
+	testStub.User.EmailVerified = false
+	defer func() { testStub.User.EmailVerified = true }()
+
+	status, body := get(t, app.browser, app.URL+"/login")
+	if status != http.StatusUnauthorized || !strings.Contains(body, ErrUnverifiedEmail.Error()) {
+		t.Errorf("expected unverified email not to be linked, got %d %q", status, body)
+	}
+}
+
+func TestClient_Login_LinksVerifiedEmail(t *testing.T) {
+	app := newTestApp(t)
+	app.identities.emails[testStub.User.Email] = 7
+
+	status, body := get(t, app.browser, app.URL+"/login")
+	if status != http.StatusOK || body != "7" {
+		t.Errorf("expected identity to be linked to user 7, got %d %q", status, body)
+	}
+}
+
+func TestClient_KeyRotation(t *testing.T) {
+	app := newTestApp(t)
+
+	if status, _ := get(t, app.browser, app.URL+"/login"); status != http.StatusOK {
+		t.Fatalf("login failed with %d", status)
+	}
+
+	testStub.RotateKey()
+
+	// pretend the keys were fetched long enough ago to fetch them again
+	app.client.Providers["stub"].keys.fetched = time.Time{}
+
+	if status, body := get(t, app.browser, app.URL+"/login"); status != http.StatusOK {
+		t.Errorf("expected login to work with the rotated key, got %d %q", status, body)
+	}
+}
+
+func TestClient_VerifyIDToken(t *testing.T) {
+	c := &Client{}
+	p := testStub.Provider("")
+	if err := p.Discover(context.Background(), http.DefaultClient); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.verifyIDToken(context.Background(), p, testStub.IDToken("n", nil), "n"); err != nil {
+		t.Errorf("expected valid token, got %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		extra map[string]interface{}
+		nonce string
+	}{
+		{"wrong nonce", nil, "other"},
+		{"wrong audience", map[string]interface{}{"aud": "someone-else"}, "n"},
+		{"wrong issuer", map[string]interface{}{"iss": "https://evil.example.com"}, "n"},
+		{"expired", map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()}, "n"},
+		{"no expiry", map[string]interface{}{"exp": nil}, "n"},
+		{"other authorized party", map[string]interface{}{"aud": []string{testStub.ClientID, "other"}, "azp": "other"}, "n"},
+	}
+
+	for _, tt := range tests {
+		_, err := c.verifyIDToken(context.Background(), p, testStub.IDToken("n", tt.extra), tt.nonce)
+		if !errors.Is(err, ErrInvalidIDToken) {
+			t.Errorf("%s: expected ErrInvalidIDToken, got %v", tt.name, err)
+		}
+	}
+
+	token := testStub.IDToken("n", nil)
+	token = token[:strings.LastIndexByte(token, '.')] + ".invalid"
+	if _, err := c.verifyIDToken(context.Background(), p, token, "n"); !errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("invalid signature: expected ErrInvalidIDToken, got %v", err)
+	}
+}
+
+func TestClient_ProviderError(t *testing.T) {
+	app := newTestApp(t)
+
+	r := httptest.NewRequest("GET", "/callback?error=access_denied", nil)
+	if _, err := app.client.Provider("missing"); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("expected ErrUnknownProvider, got %v", err)
+	}
+
+	ctx, _ := app.client.Session.Load(r.Context(), "")
+	app.client.Session.Put(ctx, "oauth.stub.state", "s")
+	r = httptest.NewRequest("GET", "/callback?error=access_denied&state=s", nil).WithContext(ctx)
+
+	_, err := app.client.Callback(r, "stub")
+	if err == nil || !strings.Contains(err.Error(), "access_denied") {
+		t.Errorf("expected provider error, got %v", err)
+	}
+}
+
+func TestIdentity_FromClaims(t *testing.T) {
+	i := &Identity{Claims: map[string]interface{}{
+		"id":             float64(583231),
+		"email":          " Jack@Example.com",
+		"email_verified": "true",
+		"login":          "jack",
+	}}
+	i.fromClaims()
+
+	if i.Subject != "583231" || i.Email != "jack@example.com" || !i.EmailVerified || i.Name != "jack" {
+		t.Errorf("wrong identity: %+v", i)
+	}
+
+	// sub wins over the id some providers also send
+	i = &Identity{Claims: map[string]interface{}{"sub": "google-1", "id": "legacy-2"}}
+	i.fromClaims()
+
+	if i.Subject != "google-1" {
+		t.Errorf("expected subject google-1, got %q", i.Subject)
+	}
+}
@@ -0,0 +1,122 @@
+package oauth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/hilsonxhero/napoleon/internal/support"
+)
+
+// ErrUnverifiedEmail is returned when a new identity has the email of an existing user
+// but the provider has not verified it. Linking it would let anyone who can register
+// that email with the provider take over the account.
+var ErrUnverifiedEmail = errors.New("oauth: email of existing user is not verified by the provider")
+
+// IdentityStore maps provider identities onto users.
+type IdentityStore interface {
+	// Resolve returns the id of the user identity belongs to. An identity seen before
+	// returns its user; a new one is linked to the user with the same, verified, email,
+	// or gets a new user.
+	Resolve(ctx context.Context, identity *Identity) (int, error)
+}
+
+// SQLIdentityStore keeps identities in the user_identities table created by
+// "napoleon make oauth", and creates users in the users table.
+type SQLIdentityStore struct {
+	DB     *sql.DB
+	DBType string
+}
+
+func (s *SQLIdentityStore) Resolve(ctx context.Context, identity *Identity) (int, error) {
+	now := time.Now()
+
+	var userID int
+	row := s.DB.QueryRowContext(ctx, support.Rebind(s.DBType, "select user_id from user_identities where provider = ? and subject = ?"),
+		identity.Provider, identity.Subject)
+	err := row.Scan(&userID)
+	if err == nil {
+		_, err = s.DB.ExecContext(ctx, support.Rebind(s.DBType, "update user_identities set email = ?, updated_at = ? where provider = ? and subject = ?"),
+			identity.Email, now, identity.Provider, identity.Subject)
+		return userID, err
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if identity.Email != "" {
+		err = tx.QueryRowContext(ctx, support.Rebind(s.DBType, "select id from users where email = ?"), identity.Email).Scan(&userID)
+		switch {
+		case err == nil && !identity.EmailVerified:
+			return 0, ErrUnverifiedEmail
+		case err != nil && !errors.Is(err, sql.ErrNoRows):
+			return 0, err
+		}
+	}
+
+	if userID == 0 {
+		userID, err = s.createUser(ctx, tx, identity, now)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, support.Rebind(s.DBType, `insert into user_identities (user_id, provider, subject, email, created_at, updated_at)
+		values (?, ?, ?, ?, ?, ?)`), userID, identity.Provider, identity.Subject, identity.Email, now, now)
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, tx.Commit()
+}
+
+// createUser adds an active user without a password, so it can only sign in through
+// a provider until a password is set with a reset.
+func (s *SQLIdentityStore) createUser(ctx context.Context, tx *sql.Tx, identity *Identity, now time.Time) (int, error) {
+	first, last := splitName(identity.Name)
+
+	var verifiedAt interface{}
+	if identity.EmailVerified {
+		verifiedAt = now
+	}
+
+	query := support.Rebind(s.DBType, `insert into users (first_name, last_name, user_active, email, password, email_verified_at, created_at, updated_at)
+		values (?, ?, 1, ?, '', ?, ?, ?)`)
+	args := []interface{}{first, last, identity.Email, verifiedAt, now, now}
+
+	switch strings.ToLower(s.DBType) {
+	case "mysql", "mariadb":
+		res, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return 0, err
+		}
+
+		id, err := res.LastInsertId()
+		return int(id), err
+	}
+
+	var id int
+	err := tx.QueryRowContext(ctx, query+" returning id", args...).Scan(&id)
+
+	return id, err
+}
+
+func splitName(name string) (string, string) {
+	parts := strings.Fields(name)
+	switch len(parts) {
+	case 0:
+		return "", ""
+	case 1:
+		return parts[0], ""
+	}
+
+	return strings.Join(parts[:len(parts)-1], " "), parts[len(parts)-1]
+}
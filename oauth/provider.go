@@ -0,0 +1,143 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// Provider is an OAuth2 or OpenID Connect identity provider. With an Issuer the
+// endpoints are read from its discovery document; plain OAuth2 providers set AuthURL,
+// TokenURL and UserInfoURL instead.
+type Provider struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	Issuer      string
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+	JWKSURL     string
+
+	keys *keySet
+}
+
+// ProvidersFromEnv reads the providers named in OAUTH_PROVIDERS, a comma separated list.
+// Each one is configured with OAUTH_<NAME>_CLIENT_ID, OAUTH_<NAME>_CLIENT_SECRET and
+// either OAUTH_<NAME>_ISSUER or OAUTH_<NAME>_AUTH_URL, _TOKEN_URL and _USERINFO_URL.
+// OAUTH_<NAME>_SCOPES defaults to "openid email profile", and OAUTH_<NAME>_REDIRECT_URL
+// to appURL/auth/<name>/callback.
+func ProvidersFromEnv(appURL string) map[string]*Provider {
+	providers := make(map[string]*Provider)
+
+	for _, name := range strings.Split(os.Getenv("OAUTH_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		env := func(key string) string {
+			return os.Getenv(fmt.Sprintf("OAUTH_%s_%s", strings.ToUpper(name), key))
+		}
+
+		scopes := strings.Fields(strings.ReplaceAll(env("SCOPES"), ",", " "))
+		if len(scopes) == 0 {
+			scopes = []string{"openid", "email", "profile"}
+		}
+
+		redirectURL := env("REDIRECT_URL")
+		if redirectURL == "" {
+			redirectURL = fmt.Sprintf("%s/auth/%s/callback", strings.TrimSuffix(appURL, "/"), name)
+		}
+
+		providers[name] = &Provider{
+			Name:         name,
+			ClientID:     env("CLIENT_ID"),
+			ClientSecret: env("CLIENT_SECRET"),
+			RedirectURL:  redirectURL,
+			Scopes:       scopes,
+			Issuer:       env("ISSUER"),
+			AuthURL:      env("AUTH_URL"),
+			TokenURL:     env("TOKEN_URL"),
+			UserInfoURL:  env("USERINFO_URL"),
+		}
+	}
+
+	return providers
+}
+
+// Discover fills the endpoints of an OpenID Connect provider from
+// <issuer>/.well-known/openid-configuration. Endpoints that are already set are kept.
+func (p *Provider) Discover(ctx context.Context, client *http.Client) error {
+	if p.Issuer == "" {
+		return nil
+	}
+
+	url := strings.TrimSuffix(p.Issuer, "/") + "/.well-known/openid-configuration"
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("oauth: discovery for %s returned %s", p.Name, res.Status)
+	}
+
+	var doc struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserinfoEndpoint      string `json:"userinfo_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		return err
+	}
+
+	// the issuer in the document must be the one configured, or tokens could be
+	// accepted from someone else
+	if strings.TrimSuffix(doc.Issuer, "/") != strings.TrimSuffix(p.Issuer, "/") {
+		return fmt.Errorf("oauth: %s issuer mismatch: configured %s, discovered %s", p.Name, p.Issuer, doc.Issuer)
+	}
+
+	setDefault(&p.AuthURL, doc.AuthorizationEndpoint)
+	setDefault(&p.TokenURL, doc.TokenEndpoint)
+	setDefault(&p.UserInfoURL, doc.UserinfoEndpoint)
+	setDefault(&p.JWKSURL, doc.JWKSURI)
+
+	return nil
+}
+
+func (p *Provider) config() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		RedirectURL:  p.RedirectURL,
+		Scopes:       p.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  p.AuthURL,
+			TokenURL: p.TokenURL,
+		},
+	}
+}
+
+func setDefault(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
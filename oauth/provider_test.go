@@ -0,0 +1,66 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestProvidersFromEnv(t *testing.T) {
+	t.Setenv("OAUTH_PROVIDERS", "Company, github")
+	t.Setenv("OAUTH_COMPANY_CLIENT_ID", "id")
+	t.Setenv("OAUTH_COMPANY_CLIENT_SECRET", "secret")
+	t.Setenv("OAUTH_COMPANY_ISSUER", "https://login.example.com")
+	t.Setenv("OAUTH_GITHUB_AUTH_URL", "https://github.com/login/oauth/authorize")
+	t.Setenv("OAUTH_GITHUB_SCOPES", "read:user,user:email")
+	t.Setenv("OAUTH_GITHUB_REDIRECT_URL", "https://app.example.com/gh")
+
+	providers := ProvidersFromEnv("https://app.example.com/")
+
+	company := providers["company"]
+	if company == nil || company.ClientID != "id" || company.Issuer != "https://login.example.com" {
+		t.Fatalf("wrong company provider: %+v", company)
+	}
+
+	if company.RedirectURL != "https://app.example.com/auth/company/callback" {
+		t.Errorf("wrong default redirect url: %s", company.RedirectURL)
+	}
+
+	if !reflect.DeepEqual(company.Scopes, []string{"openid", "email", "profile"}) {
+		t.Errorf("wrong default scopes: %v", company.Scopes)
+	}
+
+	github := providers["github"]
+	if github == nil || github.RedirectURL != "https://app.example.com/gh" || github.oidc() {
+		t.Fatalf("wrong github provider: %+v", github)
+	}
+
+	if !reflect.DeepEqual(github.Scopes, []string{"read:user", "user:email"}) {
+		t.Errorf("wrong scopes: %v", github.Scopes)
+	}
+}
+
+func TestProvider_Discover(t *testing.T) {
+	p := testStub.Provider("")
+	if err := p.Discover(context.Background(), http.DefaultClient); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.AuthURL != testStub.URL+"/authorize" || p.JWKSURL != testStub.URL+"/jwks" {
+		t.Errorf("endpoints not discovered: %+v", p)
+	}
+
+	// a document that claims to be from another issuer
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"issuer": testStub.URL})
+	}))
+	defer other.Close()
+
+	p = testStub.Provider("")
+	p.Issuer = other.URL
+	if err := p.Discover(context.Background(), http.DefaultClient); err == nil {
+		t.Error("expected an error for a document from another issuer")
+	}
+}
@@ -0,0 +1,114 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
+)
+
+var testStub *Stub
+
+func TestMain(m *testing.M) {
+	testStub = NewStub()
+
+	code := m.Run()
+
+	testStub.Close()
+	os.Exit(code)
+}
+
+// memoryIdentities keeps identities in memory; emails lists the users that exist.
+type memoryIdentities struct {
+	mu         sync.Mutex
+	identities map[string]int
+	emails     map[string]int
+	nextID     int
+}
+
+func (s *memoryIdentities) Resolve(ctx context.Context, identity *Identity) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := identity.Provider + ":" + identity.Subject
+	if id, ok := s.identities[key]; ok {
+		return id, nil
+	}
+
+	id, ok := s.emails[identity.Email]
+	if ok && !identity.EmailVerified {
+		return 0, ErrUnverifiedEmail
+	}
+	if !ok {
+		s.nextID++
+		id = s.nextID
+		s.emails[identity.Email] = id
+	}
+
+	s.identities[key] = id
+
+	return id, nil
+}
+
+// testApp is an application with login routes for the stub provider:
+// /login redirects to the stub, and /callback writes the id of the user signed in.
+type testApp struct {
+	*httptest.Server
+	client     *Client
+	identities *memoryIdentities
+	browser    *http.Client
+}
+
+func newTestApp(t *testing.T) *testApp {
+	t.Helper()
+
+	sm := scs.New()
+	sm.Store = memstore.New()
+
+	app := &testApp{
+		identities: &memoryIdentities{
+			identities: make(map[string]int),
+			emails:     make(map[string]int),
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		if err := app.client.Redirect(w, r, "stub"); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	})
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		userID, _, err := app.client.Login(r, "stub")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, userID)
+	})
+
+	app.Server = httptest.NewServer(sm.LoadAndSave(mux))
+	t.Cleanup(app.Close)
+
+	app.client = &Client{
+		Providers:  map[string]*Provider{"stub": testStub.Provider(app.URL + "/callback")},
+		Session:    sm,
+		Identities: app.identities,
+	}
+
+	if err := app.client.Discover(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	jar, _ := cookiejar.New(nil)
+	app.browser = &http.Client{Jar: jar}
+
+	return app
+}
@@ -0,0 +1,252 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Stub is a local OpenID Connect provider for tests. It approves every authorization
+// request as User, without a login page, so a test can follow the redirect from
+// Client.Redirect straight to the callback.
+type Stub struct {
+	*httptest.Server
+
+	ClientID     string
+	ClientSecret string
+	User         StubUser
+
+	mu    sync.Mutex
+	kid   string
+	key   *rsa.PrivateKey
+	codes map[string]stubCode
+}
+
+// StubUser is the user the stub signs in.
+type StubUser struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type stubCode struct {
+	redirectURI string
+	challenge   string
+	nonce       string
+}
+
+// NewStub starts a stub provider; Close it when done.
+func NewStub() *Stub {
+	s := &Stub{
+		ClientID:     "napoleon",
+		ClientSecret: "secret",
+		User: StubUser{
+			Subject:       "1234567890",
+			Email:         "jack@example.com",
+			EmailVerified: true,
+			Name:          "Jack Smith",
+		},
+		codes: make(map[string]stubCode),
+	}
+	s.RotateKey()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/authorize", s.authorize)
+	mux.HandleFunc("/token", s.token)
+	mux.HandleFunc("/userinfo", s.userinfo)
+	mux.HandleFunc("/jwks", s.jwks)
+
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// Provider returns the configuration of a provider called "stub" that uses the stub.
+func (s *Stub) Provider(redirectURL string) *Provider {
+	return &Provider{
+		Name:         "stub",
+		ClientID:     s.ClientID,
+		ClientSecret: s.ClientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"openid", "email", "profile"},
+		Issuer:       s.URL,
+	}
+}
+
+// RotateKey replaces the signing key, as providers do from time to time.
+func (s *Stub) RotateKey() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.key = key
+	s.kid = randomKid()
+}
+
+// IDToken signs an ID token for the stub user with claims added, or replaced, by extra.
+func (s *Stub) IDToken(nonce string, extra map[string]interface{}) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            s.URL,
+		"sub":            s.User.Subject,
+		"aud":            s.ClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          nonce,
+		"email":          s.User.Email,
+		"email_verified": s.User.EmailVerified,
+		"name":           s.User.Name,
+	}
+	for k, v := range extra {
+		claims[k] = v
+	}
+
+	t := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	t.Header["kid"] = s.kid
+
+	signed, err := t.SignedString(s.key)
+	if err != nil {
+		panic(err)
+	}
+
+	return signed
+}
+
+func (s *Stub) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                 s.URL,
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"userinfo_endpoint":      s.URL + "/userinfo",
+		"jwks_uri":               s.URL + "/jwks",
+	})
+}
+
+func (s *Stub) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != s.ClientID || q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	code := randomKid()
+
+	s.mu.Lock()
+	s.codes[code] = stubCode{
+		redirectURI: q.Get("redirect_uri"),
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+	}
+	s.mu.Unlock()
+
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	params := redirect.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	redirect.RawQuery = params.Encode()
+
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *Stub) token(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != s.ClientID || clientSecret != s.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	s.mu.Lock()
+	code, ok := s.codes[r.PostForm.Get("code")]
+	delete(s.codes, r.PostForm.Get("code"))
+	s.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	if !ok || code.redirectURI != r.PostForm.Get("redirect_uri") || code.challenge != challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "stub-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     s.IDToken(code.nonce, nil),
+	})
+}
+
+func (s *Stub) userinfo(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer stub-access-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"sub":            s.User.Subject,
+		"email":          s.User.Email,
+		"email_verified": s.User.EmailVerified,
+		"name":           s.User.Name,
+	})
+}
+
+func (s *Stub) jwks(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pub := s.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": s.kid,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func randomKid() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
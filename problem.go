@@ -0,0 +1,155 @@
+package napoleon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/hilsonxhero/napoleon/internal/support"
+	"github.com/hilsonxhero/napoleon/render"
+)
+
+// MediaProblem is the media type of RFC 7807 problem details.
+const MediaProblem = "application/problem+json"
+
+// Problem is an error with the details of an RFC 7807 problem, written by WriteProblem
+// as problem+json to API clients and as an error page to browsers.
+type Problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Code     string            `json:"code,omitempty"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Errors   map[string]string `json:"errors,omitempty"`
+
+	// Err is the error behind the problem; it is logged, but never shown to clients
+	Err error `json:"-"`
+}
+
+// NewProblem returns a problem with status and detail, titled after the status.
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+func (p *Problem) Error() string {
+	msg := fmt.Sprintf("%d %s", p.Status, p.Title)
+	if p.Detail != "" {
+		msg += ": " + p.Detail
+	}
+	if p.Err != nil {
+		msg += ": " + p.Err.Error()
+	}
+
+	return msg
+}
+
+func (p *Problem) Unwrap() error {
+	return p.Err
+}
+
+// ProblemFrom turns err into a problem. Problems are returned as a copy, with a missing
+// status made 500; failed validations become 422 problems with the field errors, the
+// errors Handle knows get their status, and any other error a 500 problem that does not
+// tell the client more.
+func ProblemFrom(err error) *Problem {
+	var problem *Problem
+	if errors.As(err, &problem) {
+		p := *problem
+		// a problem without a status is a bug, and w.WriteHeader(0) would panic
+		if p.Status == 0 {
+			p.Status = http.StatusInternalServerError
+		}
+		if p.Type == "" {
+			p.Type = "about:blank"
+		}
+		if p.Title == "" {
+			p.Title = http.StatusText(p.Status)
+		}
+		return &p
+	}
+
+	var validation *Validation
+	if errors.As(err, &validation) {
+		p := NewProblem(http.StatusUnprocessableEntity, "The given data was invalid.")
+		p.Code = "validation_failed"
+		p.Errors = validation.Errors
+		p.Err = err
+		return p
+	}
+
+	// refused by the middleware of auth, jwt or authz
+	var statusErr *support.StatusError
+	if errors.As(err, &statusErr) {
+		p := NewProblem(statusErr.Status, statusErr.Message)
+		p.Err = err
+		return p
+	}
+
+	status, ok := errorStatus(err)
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	p := NewProblem(status, "")
+	p.Err = err
+
+	return p
+}
+
+// WriteProblem answers r with err as a problem. API routes, those under /api/ and
+// requests that prefer json, get application/problem+json; browsers get the
+// views/errors/<status> page, or plain text when there is no such view. Debug mode shows
+// the error behind 5xx problems as their detail, never that of client errors.
+func (n *Napoleon) WriteProblem(w http.ResponseWriter, r *http.Request, err error) {
+	p := ProblemFrom(err)
+	p.Instance = middleware.GetReqID(r.Context())
+
+	if n.Debug && p.Status >= http.StatusInternalServerError && p.Detail == "" && p.Err != nil {
+		p.Detail = p.Err.Error()
+	}
+
+	if isAPIRequest(r) {
+		out, err := json.Marshal(p)
+		if err != nil {
+			http.Error(w, p.Title, p.Status)
+			return
+		}
+
+		w.Header().Set("Content-Type", MediaProblem)
+		w.WriteHeader(p.Status)
+		_, _ = w.Write(out)
+		return
+	}
+
+	view := fmt.Sprintf("errors/%d", p.Status)
+	if n.Render != nil && n.Render.Exists(view) {
+		td := &render.TemplateData{
+			Errors: p.Errors,
+			Data:   map[string]interface{}{"status": p.Status, "problem": p},
+		}
+
+		// a page that fails to render has already been answered with the error page
+		_ = n.Render.PageStatus(w, r, p.Status, view, nil, td)
+		return
+	}
+
+	http.Error(w, p.Title, p.Status)
+}
+
+// isAPIRequest reports whether r should be answered with json rather than a page.
+func isAPIRequest(r *http.Request) bool {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		return true
+	}
+
+	return negotiate(r.Header.Get("Accept"), []string{MediaHTML, MediaProblem, MediaJSON}) != MediaHTML
+}
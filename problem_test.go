@@ -0,0 +1,131 @@
+package napoleon
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/hilsonxhero/napoleon/internal/support"
+)
+
+func TestProblemFrom(t *testing.T) {
+	conflict := &Problem{Status: http.StatusConflict, Detail: "Email is taken.", Code: "email_taken"}
+	validation := &Validation{Errors: map[string]string{"email": "Is required"}}
+
+	var tests = []struct {
+		name   string
+		err    error
+		status int
+		title  string
+		detail string
+		errors map[string]string
+	}{
+		{"problem", conflict, http.StatusConflict, "Conflict", "Email is taken.", nil},
+		{"wrapped_problem", fmt.Errorf("saving user: %w", conflict), http.StatusConflict, "Conflict", "Email is taken.", nil},
+		{"validation", validation, http.StatusUnprocessableEntity, "Unprocessable Entity", "The given data was invalid.", validation.Errors},
+		{"not_found", ErrNotFound, http.StatusNotFound, "Not Found", "", nil},
+		{"no_rows", fmt.Errorf("finding user: %w", sql.ErrNoRows), http.StatusNotFound, "Not Found", "", nil},
+		{"unauthorized", ErrUnauthorized, http.StatusUnauthorized, "Unauthorized", "", nil},
+		{"forbidden", ErrForbidden, http.StatusForbidden, "Forbidden", "", nil},
+		{"no_status", &Problem{Detail: "Something broke."}, http.StatusInternalServerError, "Internal Server Error", "Something broke.", nil},
+		{"status_error", &support.StatusError{Status: http.StatusForbidden, Message: "token cannot posts:write"}, http.StatusForbidden, "Forbidden", "token cannot posts:write", nil},
+		{"other", errors.New("disk on fire"), http.StatusInternalServerError, "Internal Server Error", "", nil},
+	}
+
+	for _, e := range tests {
+		p := ProblemFrom(e.err)
+
+		if p.Status != e.status || p.Title != e.title || p.Detail != e.detail || p.Type != "about:blank" {
+			t.Errorf("%s: wrong problem %+v", e.name, p)
+		}
+		if fmt.Sprint(p.Errors) != fmt.Sprint(e.errors) {
+			t.Errorf("%s: expected errors %v, got %v", e.name, e.errors, p.Errors)
+		}
+	}
+
+	// problems are copied, so that writing one does not change the original
+	p := ProblemFrom(conflict)
+	p.Instance = "req-1"
+	if conflict.Instance != "" || conflict.Type != "" {
+		t.Error("ProblemFrom changed the original problem")
+	}
+}
+
+func TestWriteProblem(t *testing.T) {
+	invalid := NewProblem(http.StatusBadRequest, "")
+	invalid.Err = errors.New("sql: syntax error at users.secret_column")
+
+	var tests = []struct {
+		name        string
+		path        string
+		accept      string
+		debug       bool
+		err         error
+		status      int
+		contentType string
+		body        []string
+		notInBody   []string
+	}{
+		{"api", "/api/users/1", "", false, ErrNotFound, http.StatusNotFound, MediaProblem,
+			[]string{`"status":404`, `"title":"Not Found"`, `"instance":"req-1"`}, nil},
+		{"accept_json", "/users/1", MediaJSON, false, ErrForbidden, http.StatusForbidden, MediaProblem,
+			[]string{`"status":403`}, nil},
+		{"validation", "/api/users", "", false, &Validation{Errors: map[string]string{"email": "Is required"}}, http.StatusUnprocessableEntity, MediaProblem,
+			[]string{`"errors":{"email":"Is required"}`, `"code":"validation_failed"`}, nil},
+		{"server_error", "/api/users", "", false, errors.New("disk on fire"), http.StatusInternalServerError, MediaProblem,
+			nil, []string{"disk on fire"}},
+		{"server_error_debug", "/api/users", "", true, errors.New("disk on fire"), http.StatusInternalServerError, MediaProblem,
+			[]string{`"detail":"disk on fire"`}, nil},
+		{"client_error_debug", "/api/users", "", true, invalid, http.StatusBadRequest, MediaProblem,
+			nil, []string{"secret_column"}},
+		{"themed_page", "/users/1", "text/html", false, &Problem{Status: http.StatusNotFound, Detail: "No such user.", Errors: map[string]string{"id": "Unknown"}},
+			http.StatusNotFound, "text/html; charset=utf-8", []string{"404 No such user. id=Unknown"}, nil},
+		{"plain_page", "/users/1", "text/html", false, ErrForbidden, http.StatusForbidden, "text/plain; charset=utf-8",
+			[]string{"Forbidden"}, nil},
+		{"no_status", "/api/users", "", false, &Problem{Code: "oops"}, http.StatusInternalServerError, MediaProblem,
+			[]string{`"status":500`}, nil},
+	}
+
+	for _, e := range tests {
+		app := &Napoleon{Debug: e.debug, Render: testApp.Render}
+
+		r := httptest.NewRequest("GET", e.path, nil)
+		r = r.WithContext(context.WithValue(r.Context(), middleware.RequestIDKey, "req-1"))
+		r = loadSession(t, r)
+		if e.accept != "" {
+			r.Header.Set("Accept", e.accept)
+		}
+		w := httptest.NewRecorder()
+
+		app.WriteProblem(w, r, e.err)
+
+		if w.Code != e.status {
+			t.Errorf("%s: expected status %d, got %d", e.name, e.status, w.Code)
+		}
+		if got := w.Header().Get("Content-Type"); got != e.contentType {
+			t.Errorf("%s: expected content type %q, got %q", e.name, e.contentType, got)
+		}
+
+		body := w.Body.String()
+		if e.contentType == MediaProblem && !json.Valid([]byte(body)) {
+			t.Errorf("%s: invalid json %q", e.name, body)
+		}
+		for _, want := range e.body {
+			if !strings.Contains(body, want) {
+				t.Errorf("%s: body %q does not contain %q", e.name, body, want)
+			}
+		}
+		for _, unwanted := range e.notInBody {
+			if strings.Contains(body, unwanted) {
+				t.Errorf("%s: body %q shows %q", e.name, body, unwanted)
+			}
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/hilsonxhero/napoleon/internal/support"
+)
+
+// DatabaseDriver stores jobs in the jobs and failed_jobs tables created by
+// "make queue". Workers reserve jobs with SELECT ... FOR UPDATE SKIP LOCKED, which needs
+// postgres 9.5+ or mysql 8+. Times are stored as unix milliseconds.
+type DatabaseDriver struct {
+	DB         *sql.DB
+	DBType     string
+	RetryAfter time.Duration
+}
+
+func (d *DatabaseDriver) Push(ctx context.Context, job *Job) error {
+	_, err := d.DB.ExecContext(ctx, support.Rebind(d.DBType, `insert into jobs
+		(id, queue, name, payload, attempts, max_attempts, available_at, created_at)
+		values (?, ?, ?, ?, ?, ?, ?, ?)`),
+		job.ID, job.Queue, job.Name, string(job.Payload), job.Attempts, job.MaxAttempts,
+		job.AvailableAt.UnixMilli(), job.CreatedAt.UnixMilli())
+
+	return err
+}
+
+func (d *DatabaseDriver) Pop(ctx context.Context, queue string) (*Job, error) {
+	tx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+
+	// reserved_at holds the end of the reservation, so expired reservations are picked up again
+	row := tx.QueryRowContext(ctx, support.Rebind(d.DBType, `select id, queue, name, payload, attempts, max_attempts, available_at, created_at
+		from jobs
+		where queue = ? and ((reserved_at is null and available_at <= ?) or reserved_at <= ?)
+		order by available_at
+		limit 1
+		for update skip locked`),
+		queue, now.UnixMilli(), now.UnixMilli())
+
+	var job Job
+	var payload string
+	var availableAt, createdAt int64
+
+	err = row.Scan(&job.ID, &job.Queue, &job.Name, &payload, &job.Attempts, &job.MaxAttempts, &availableAt, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	job.Payload = []byte(payload)
+	job.AvailableAt = time.UnixMilli(availableAt)
+	job.CreatedAt = time.UnixMilli(createdAt)
+	job.Attempts++
+
+	_, err = tx.ExecContext(ctx, support.Rebind(d.DBType, "update jobs set reserved_at = ?, attempts = attempts + 1 where id = ?"),
+		now.Add(d.retryAfter()).UnixMilli(), job.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+func (d *DatabaseDriver) retryAfter() time.Duration {
+	if d.RetryAfter == 0 {
+		return 90 * time.Second
+	}
+	return d.RetryAfter
+}
+
+func (d *DatabaseDriver) Delete(ctx context.Context, job *Job) error {
+	_, err := d.DB.ExecContext(ctx, support.Rebind(d.DBType, "delete from jobs where id = ?"), job.ID)
+	return err
+}
+
+func (d *DatabaseDriver) Release(ctx context.Context, job *Job, delay time.Duration) error {
+	job.AvailableAt = time.Now().Add(delay)
+
+	_, err := d.DB.ExecContext(ctx, support.Rebind(d.DBType, "update jobs set reserved_at = null, attempts = ?, available_at = ? where id = ?"),
+		job.Attempts, job.AvailableAt.UnixMilli(), job.ID)
+
+	return err
+}
+
+func (d *DatabaseDriver) Fail(ctx context.Context, job *Job) error {
+	tx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, support.Rebind(d.DBType, `insert into failed_jobs
+		(id, queue, name, payload, attempts, max_attempts, error, created_at, failed_at)
+		values (?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		job.ID, job.Queue, job.Name, string(job.Payload), job.Attempts, job.MaxAttempts, job.LastError,
+		job.CreatedAt.UnixMilli(), job.FailedAt.UnixMilli())
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, support.Rebind(d.DBType, "delete from jobs where id = ?"), job.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (d *DatabaseDriver) Failed(ctx context.Context) ([]*Job, error) {
+	rows, err := d.DB.QueryContext(ctx, `select id, queue, name, payload, attempts, max_attempts, error, created_at, failed_at
+		from failed_jobs order by failed_at desc`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*Job
+	for rows.Next() {
+		job, err := scanFailed(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+func (d *DatabaseDriver) Retry(ctx context.Context, id string) error {
+	row := d.DB.QueryRowContext(ctx, support.Rebind(d.DBType, `select id, queue, name, payload, attempts, max_attempts, error, created_at, failed_at
+		from failed_jobs where id = ?`), id)
+
+	job, err := scanFailed(row)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := d.Push(ctx, retryable(job)); err != nil {
+		return err
+	}
+
+	return d.Forget(ctx, id)
+}
+
+func (d *DatabaseDriver) Forget(ctx context.Context, id string) error {
+	res, err := d.DB.ExecContext(ctx, support.Rebind(d.DBType, "delete from failed_jobs where id = ?"), id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanFailed(row scanner) (*Job, error) {
+	var job Job
+	var payload string
+	var createdAt, failedAt int64
+
+	err := row.Scan(&job.ID, &job.Queue, &job.Name, &payload, &job.Attempts, &job.MaxAttempts, &job.LastError, &createdAt, &failedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	job.Payload = []byte(payload)
+	job.CreatedAt = time.UnixMilli(createdAt)
+	job.FailedAt = time.UnixMilli(failedAt)
+
+	return &job, nil
+}
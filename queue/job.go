@@ -0,0 +1,71 @@
+package queue
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Job is a unit of work pushed onto a queue. Payload holds the json encoded arguments
+// for the handler registered under Name.
+type Job struct {
+	ID          string          `json:"id"`
+	Queue       string          `json:"queue"`
+	Name        string          `json:"name"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	AvailableAt time.Time       `json:"available_at"`
+	CreatedAt   time.Time       `json:"created_at"`
+	FailedAt    time.Time       `json:"failed_at,omitempty"`
+	LastError   string          `json:"last_error,omitempty"`
+
+	// raw is the encoded job as it was popped, used by drivers to find the reservation
+	raw string
+}
+
+// NewJob creates a job for the handler registered as name, with payload encoded as json.
+func NewJob(name string, payload interface{}) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Job{
+		Name:    name,
+		Payload: data,
+	}, nil
+}
+
+// OnQueue sets the queue the job is pushed onto.
+func (j *Job) OnQueue(queue string) *Job {
+	j.Queue = queue
+	return j
+}
+
+// Delay makes the job available only after d has passed.
+func (j *Job) Delay(d time.Duration) *Job {
+	j.AvailableAt = time.Now().Add(d)
+	return j
+}
+
+// Tries sets how many times the job is attempted before it is moved to the failed jobs.
+func (j *Job) Tries(n int) *Job {
+	j.MaxAttempts = n
+	return j
+}
+
+// Decode unmarshals the job payload into v.
+func (j *Job) Decode(v interface{}) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
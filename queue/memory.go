@@ -0,0 +1,103 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryDriver keeps jobs in process memory. Jobs are lost on restart, so it is meant
+// for tests and local development. The zero value is ready to use.
+type MemoryDriver struct {
+	mu     sync.Mutex
+	jobs   []*Job
+	failed []*Job
+}
+
+func (m *MemoryDriver) Push(ctx context.Context, job *Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j := *job
+	m.jobs = append(m.jobs, &j)
+
+	return nil
+}
+
+func (m *MemoryDriver) Pop(ctx context.Context, queue string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for i, job := range m.jobs {
+		if job.Queue == queue && !job.AvailableAt.After(now) {
+			m.jobs = append(m.jobs[:i], m.jobs[i+1:]...)
+			job.Attempts++
+			return job, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (m *MemoryDriver) Delete(ctx context.Context, job *Job) error {
+	return nil
+}
+
+func (m *MemoryDriver) Release(ctx context.Context, job *Job, delay time.Duration) error {
+	job.AvailableAt = time.Now().Add(delay)
+	return m.Push(ctx, job)
+}
+
+func (m *MemoryDriver) Fail(ctx context.Context, job *Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.failed = append(m.failed, job)
+
+	return nil
+}
+
+func (m *MemoryDriver) Failed(ctx context.Context) ([]*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]*Job{}, m.failed...), nil
+}
+
+func (m *MemoryDriver) Retry(ctx context.Context, id string) error {
+	job, err := m.remove(id)
+	if err != nil {
+		return err
+	}
+
+	return m.Push(ctx, retryable(job))
+}
+
+func (m *MemoryDriver) Forget(ctx context.Context, id string) error {
+	_, err := m.remove(id)
+	return err
+}
+
+func (m *MemoryDriver) remove(id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, job := range m.failed {
+		if job.ID == id {
+			m.failed = append(m.failed[:i], m.failed[i+1:]...)
+			return job, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+// retryable resets a failed job so that it runs again straight away.
+func retryable(job *Job) *Job {
+	job.Attempts = 0
+	job.AvailableAt = time.Now()
+	job.FailedAt = time.Time{}
+	job.LastError = ""
+	return job
+}
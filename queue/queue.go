@@ -0,0 +1,298 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by Retry and Forget for an unknown failed job.
+var ErrNotFound = errors.New("queue: job not found")
+
+// ErrTimeout is returned by Work when jobs may run longer than the driver reserves them,
+// which would hand a running job to a second worker.
+var ErrTimeout = errors.New("queue: Timeout must be shorter than the RetryAfter of the driver")
+
+// Driver stores jobs. Pop reserves the next available job on a queue and counts the
+// attempt, or returns nil if there is none; the reservation ends with Delete, Release or
+// Fail.
+type Driver interface {
+	Push(ctx context.Context, job *Job) error
+	Pop(ctx context.Context, queue string) (*Job, error)
+	Delete(ctx context.Context, job *Job) error
+	Release(ctx context.Context, job *Job, delay time.Duration) error
+	Fail(ctx context.Context, job *Job) error
+	Failed(ctx context.Context) ([]*Job, error)
+	Retry(ctx context.Context, id string) error
+	Forget(ctx context.Context, id string) error
+}
+
+// reserver is a driver whose reservations expire, after which the job is handed out again.
+type reserver interface {
+	retryAfter() time.Duration
+}
+
+// HandlerFunc processes a job. Returning an error retries the job with backoff until it
+// runs out of attempts.
+type HandlerFunc func(ctx context.Context, job *Job) error
+
+// Queue dispatches jobs to a driver and runs workers that hand them to their handlers.
+type Queue struct {
+	Driver      Driver
+	Default     string
+	Tries       int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	Timeout     time.Duration
+	ErrorLog    *log.Logger
+	handlers    map[string]HandlerFunc
+	handlersMux sync.RWMutex
+}
+
+// WorkerOptions configures Work.
+type WorkerOptions struct {
+	Queues      []string
+	Concurrency int
+	Sleep       time.Duration
+}
+
+// Handle registers the handler for jobs called name.
+func (q *Queue) Handle(name string, handler HandlerFunc) {
+	q.handlersMux.Lock()
+	defer q.handlersMux.Unlock()
+
+	if q.handlers == nil {
+		q.handlers = make(map[string]HandlerFunc)
+	}
+	q.handlers[name] = handler
+}
+
+// Register registers a typed handler for jobs called name: the payload is decoded into a
+// T before fn is called.
+func Register[T any](q *Queue, name string, fn func(ctx context.Context, payload T) error) {
+	q.Handle(name, func(ctx context.Context, job *Job) error {
+		var payload T
+		if err := job.Decode(&payload); err != nil {
+			return err
+		}
+		return fn(ctx, payload)
+	})
+}
+
+// Dispatch pushes job onto its queue, filling in defaults for the fields left empty.
+func (q *Queue) Dispatch(ctx context.Context, job *Job) error {
+	if job.ID == "" {
+		id, err := newID()
+		if err != nil {
+			return err
+		}
+		job.ID = id
+	}
+
+	if job.Queue == "" {
+		job.Queue = q.defaultQueue()
+	}
+
+	if job.MaxAttempts == 0 {
+		job.MaxAttempts = q.Tries
+		if job.MaxAttempts == 0 {
+			job.MaxAttempts = 3
+		}
+	}
+
+	now := time.Now()
+	job.CreatedAt = now
+	if job.AvailableAt.IsZero() {
+		job.AvailableAt = now
+	}
+
+	return q.Driver.Push(ctx, job)
+}
+
+// Failed lists the jobs in the dead-letter store.
+func (q *Queue) Failed(ctx context.Context) ([]*Job, error) {
+	return q.Driver.Failed(ctx)
+}
+
+// Retry moves a failed job back onto its queue with its attempts reset.
+func (q *Queue) Retry(ctx context.Context, id string) error {
+	return q.Driver.Retry(ctx, id)
+}
+
+// Forget deletes a failed job.
+func (q *Queue) Forget(ctx context.Context, id string) error {
+	return q.Driver.Forget(ctx, id)
+}
+
+// Work runs workers until ctx is cancelled, then waits for running jobs to finish.
+func (q *Queue) Work(ctx context.Context, opts WorkerOptions) error {
+	if r, ok := q.Driver.(reserver); ok && q.timeout() >= r.retryAfter() {
+		return fmt.Errorf("%w: %s is not below %s", ErrTimeout, q.timeout(), r.retryAfter())
+	}
+
+	if len(opts.Queues) == 0 {
+		opts.Queues = []string{q.defaultQueue()}
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.Sleep == 0 {
+		opts.Sleep = time.Second
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.worker(ctx, opts)
+		}()
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+func (q *Queue) worker(ctx context.Context, opts WorkerOptions) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		worked, err := q.workNext(opts.Queues)
+		if err != nil {
+			q.errorLog().Println("queue:", err)
+		}
+
+		if !worked || err != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(opts.Sleep):
+			}
+		}
+	}
+}
+
+// workNext processes the first available job from queues, which are checked in order of
+// priority. It reports whether there was a job.
+func (q *Queue) workNext(queues []string) (bool, error) {
+	// jobs run to completion even when the worker is stopping
+	ctx := context.Background()
+
+	for _, name := range queues {
+		job, err := q.Driver.Pop(ctx, name)
+		if err != nil {
+			return false, err
+		}
+
+		if job != nil {
+			return true, q.process(ctx, job)
+		}
+	}
+
+	return false, nil
+}
+
+// process runs a popped job; the driver has already counted the attempt, so that a job
+// which crashes the worker still runs out of attempts.
+func (q *Queue) process(ctx context.Context, job *Job) error {
+	// the reservations of earlier attempts expired, so the worker crashed or timed out
+	if job.Attempts > job.MaxAttempts {
+		job.Attempts = job.MaxAttempts
+		return q.fail(ctx, job, errors.New("the job was reserved too many times without finishing"))
+	}
+
+	err := q.run(ctx, job)
+	if err == nil {
+		return q.Driver.Delete(ctx, job)
+	}
+
+	if job.Attempts >= job.MaxAttempts {
+		return q.fail(ctx, job, err)
+	}
+
+	job.LastError = err.Error()
+
+	return q.Driver.Release(ctx, job, q.backoff(job.Attempts))
+}
+
+func (q *Queue) fail(ctx context.Context, job *Job, err error) error {
+	q.errorLog().Printf("queue: job %s (%s) failed after %d attempts: %s", job.ID, job.Name, job.Attempts, err)
+
+	job.LastError = err.Error()
+	job.FailedAt = time.Now()
+
+	return q.Driver.Fail(ctx, job)
+}
+
+func (q *Queue) run(ctx context.Context, job *Job) (err error) {
+	q.handlersMux.RLock()
+	handler, ok := q.handlers[job.Name]
+	q.handlersMux.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("no handler registered for job %q", job.Name)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, q.timeout())
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return handler(ctx, job)
+}
+
+// backoff doubles the delay with every attempt: Backoff, 2*Backoff, 4*Backoff...
+func (q *Queue) backoff(attempts int) time.Duration {
+	base := q.Backoff
+	if base == 0 {
+		base = 10 * time.Second
+	}
+
+	max := q.MaxBackoff
+	if max == 0 {
+		max = time.Hour
+	}
+
+	delay := base
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+
+	if delay > max {
+		delay = max
+	}
+
+	return delay
+}
+
+func (q *Queue) timeout() time.Duration {
+	if q.Timeout == 0 {
+		return time.Minute
+	}
+	return q.Timeout
+}
+
+func (q *Queue) defaultQueue() string {
+	if q.Default == "" {
+		return "default"
+	}
+	return q.Default
+}
+
+func (q *Queue) errorLog() *log.Logger {
+	if q.ErrorLog == nil {
+		return log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime)
+	}
+	return q.ErrorLog
+}
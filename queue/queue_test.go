@@ -0,0 +1,276 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type greeting struct {
+	Name string `json:"name"`
+}
+
+var drivers = []struct {
+	name   string
+	driver Driver
+}{
+	{"memory", &MemoryDriver{}},
+	{"redis", &testRedisDriver},
+}
+
+func TestQueue_Dispatch(t *testing.T) {
+	ctx := context.Background()
+
+	for _, e := range drivers {
+		q := &Queue{Driver: e.driver}
+
+		var got string
+		Register(q, "greet", func(ctx context.Context, g greeting) error {
+			got = g.Name
+			return nil
+		})
+
+		job, err := NewJob("greet", greeting{Name: "napoleon"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = q.Dispatch(ctx, job)
+		if err != nil {
+			t.Errorf("%s: %s", e.name, err)
+		}
+
+		worked, err := q.workNext([]string{"default"})
+		if err != nil {
+			t.Errorf("%s: %s", e.name, err)
+		}
+
+		if !worked {
+			t.Errorf("%s: dispatched job was not worked", e.name)
+		}
+
+		if got != "napoleon" {
+			t.Errorf("%s: handler got wrong payload %q", e.name, got)
+		}
+
+		worked, _ = q.workNext([]string{"default"})
+		if worked {
+			t.Errorf("%s: job was worked twice", e.name)
+		}
+	}
+}
+
+func TestQueue_Delay(t *testing.T) {
+	ctx := context.Background()
+
+	for _, e := range drivers {
+		q := &Queue{Driver: e.driver}
+		q.Handle("later", func(ctx context.Context, job *Job) error {
+			return nil
+		})
+
+		job, _ := NewJob("later", nil)
+		_ = q.Dispatch(ctx, job.OnQueue("delayed").Delay(50*time.Millisecond))
+
+		worked, _ := q.workNext([]string{"delayed"})
+		if worked {
+			t.Errorf("%s: delayed job was worked too early", e.name)
+		}
+
+		time.Sleep(60 * time.Millisecond)
+
+		worked, _ = q.workNext([]string{"delayed"})
+		if !worked {
+			t.Errorf("%s: delayed job was not worked once due", e.name)
+		}
+	}
+}
+
+func TestQueue_Failed(t *testing.T) {
+	ctx := context.Background()
+
+	for _, e := range drivers {
+		q := &Queue{Driver: e.driver, Backoff: time.Millisecond}
+
+		var calls int32
+		q.Handle("broken", func(ctx context.Context, job *Job) error {
+			atomic.AddInt32(&calls, 1)
+			return errors.New("broken")
+		})
+
+		job, _ := NewJob("broken", nil)
+		_ = q.Dispatch(ctx, job.OnQueue("failing").Tries(2))
+
+		_, _ = q.workNext([]string{"failing"})
+		time.Sleep(5 * time.Millisecond)
+		_, _ = q.workNext([]string{"failing"})
+
+		if atomic.LoadInt32(&calls) != 2 {
+			t.Errorf("%s: expected 2 attempts, got %d", e.name, calls)
+		}
+
+		failed, err := q.Failed(ctx)
+		if err != nil {
+			t.Errorf("%s: %s", e.name, err)
+		}
+
+		if len(failed) != 1 || failed[0].ID != job.ID || failed[0].LastError != "broken" {
+			t.Errorf("%s: job not found in failed jobs: %v", e.name, failed)
+			continue
+		}
+
+		err = q.Retry(ctx, job.ID)
+		if err != nil {
+			t.Errorf("%s: %s", e.name, err)
+		}
+
+		worked, _ := q.workNext([]string{"failing"})
+		if !worked {
+			t.Errorf("%s: retried job was not put back on the queue", e.name)
+		}
+
+		err = q.Retry(ctx, "no-such-job")
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound, got %v", e.name, err)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+		_, _ = q.workNext([]string{"failing"})
+
+		err = q.Forget(ctx, job.ID)
+		if err != nil {
+			t.Errorf("%s: %s", e.name, err)
+		}
+
+		failed, _ = q.Failed(ctx)
+		if len(failed) != 0 {
+			t.Errorf("%s: forgotten job still in failed jobs", e.name)
+		}
+	}
+}
+
+func TestQueue_Work(t *testing.T) {
+	q := &Queue{Driver: &MemoryDriver{}}
+
+	var calls int32
+	q.Handle("count", func(ctx context.Context, job *Job) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	})
+
+	for i := 0; i < 10; i++ {
+		job, _ := NewJob("count", i)
+		_ = q.Dispatch(context.Background(), job)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if err := q.Work(ctx, WorkerOptions{Concurrency: 3, Sleep: 10 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+
+	if atomic.LoadInt32(&calls) != 10 {
+		t.Errorf("expected 10 jobs to be worked, got %d", calls)
+	}
+}
+
+func TestQueue_backoff(t *testing.T) {
+	q := &Queue{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}
+	for i, e := range expected {
+		if got := q.backoff(i + 1); got != e {
+			t.Errorf("attempt %d: expected backoff %s, got %s", i+1, e, got)
+		}
+	}
+}
+
+func TestRedisDriver_ExpiredReservation(t *testing.T) {
+	ctx := context.Background()
+	d := RedisDriver{Conn: testRedisDriver.Conn, Prefix: "test-reserve", RetryAfter: 10 * time.Millisecond}
+	q := &Queue{Driver: &d}
+
+	job, _ := NewJob("crash", nil)
+	_ = q.Dispatch(ctx, job)
+
+	popped, err := d.Pop(ctx, "default")
+	if err != nil || popped == nil {
+		t.Fatal("could not pop job", err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	popped, err = d.Pop(ctx, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if popped == nil || popped.ID != job.ID {
+		t.Fatal("job with an expired reservation was not put back on the queue")
+	}
+
+	if popped.Attempts != 2 {
+		t.Errorf("expected the expired reservation to count as an attempt, got %d attempts", popped.Attempts)
+	}
+}
+
+func TestQueue_CrashedJobFails(t *testing.T) {
+	ctx := context.Background()
+	d := RedisDriver{Conn: testRedisDriver.Conn, Prefix: "test-crash", RetryAfter: 10 * time.Millisecond}
+	q := &Queue{Driver: &d, Timeout: time.Millisecond}
+
+	var calls int32
+	q.Handle("crash", func(ctx context.Context, job *Job) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	})
+
+	job, _ := NewJob("crash", nil)
+	_ = q.Dispatch(ctx, job.Tries(2))
+
+	// two workers die while running the job
+	for i := 0; i < 2; i++ {
+		if popped, err := d.Pop(ctx, "default"); err != nil || popped == nil {
+			t.Fatal("could not pop job", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	worked, err := q.workNext([]string{"default"})
+	if err != nil || !worked {
+		t.Fatal("job was not handed out again", err)
+	}
+
+	if atomic.LoadInt32(&calls) != 0 {
+		t.Error("job ran after it used up its attempts")
+	}
+
+	failed, _ := q.Failed(ctx)
+	if len(failed) != 1 || failed[0].ID != job.ID || failed[0].Attempts != 2 {
+		t.Errorf("crashed job not found in failed jobs: %v", failed)
+	}
+}
+
+func TestQueue_WorkTimeout(t *testing.T) {
+	for _, e := range []struct {
+		name    string
+		queue   *Queue
+		wantErr bool
+	}{
+		{"memory", &Queue{Driver: &MemoryDriver{}, Timeout: time.Hour}, false},
+		{"below_retry_after", &Queue{Driver: &RedisDriver{RetryAfter: time.Minute}, Timeout: 30 * time.Second}, false},
+		{"default_timeout", &Queue{Driver: &RedisDriver{RetryAfter: time.Minute}}, true},
+		{"above_retry_after", &Queue{Driver: &DatabaseDriver{}, Timeout: 2 * time.Minute}, true},
+	} {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := e.queue.Work(ctx, WorkerOptions{})
+		if errors.Is(err, ErrTimeout) != e.wantErr {
+			t.Errorf("%s: unexpected error %v", e.name, err)
+		}
+	}
+}
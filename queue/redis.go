@@ -0,0 +1,250 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// popScript moves due delayed jobs and expired reservations back onto the ready list,
+// then pops the next job and reserves it until ARGV[2]. The attempt is counted in the
+// KEYS[4] hash, which outlives the reservation, and returned with the job.
+var popScript = redis.NewScript(4, `
+local function migrate(from)
+	local due = redis.call("ZRANGEBYSCORE", from, "-inf", ARGV[1], "LIMIT", 0, 100)
+	for _, raw in ipairs(due) do
+		redis.call("ZREM", from, raw)
+		redis.call("RPUSH", KEYS[1], raw)
+	end
+end
+
+migrate(KEYS[2])
+migrate(KEYS[3])
+
+local raw = redis.call("LPOP", KEYS[1])
+if not raw then
+	return false
+end
+
+redis.call("ZADD", KEYS[3], ARGV[2], raw)
+
+local job = cjson.decode(raw)
+local attempts = tonumber(redis.call("HGET", KEYS[4], job.id) or job.attempts) + 1
+redis.call("HSET", KEYS[4], job.id, attempts)
+
+return {raw, attempts}`)
+
+// RedisDriver stores each queue as a ready list plus sorted sets of delayed and reserved
+// jobs. A reservation that is not finished within RetryAfter (a crashed worker) is
+// put back on the queue.
+type RedisDriver struct {
+	Conn       *redis.Pool
+	Prefix     string
+	RetryAfter time.Duration
+}
+
+func (r *RedisDriver) Push(ctx context.Context, job *Job) error {
+	raw, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	conn, err := r.Conn.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if job.AvailableAt.After(time.Now()) {
+		_, err = conn.Do("ZADD", r.key(job.Queue, "delayed"), job.AvailableAt.UnixMilli(), raw)
+	} else {
+		_, err = conn.Do("RPUSH", r.key(job.Queue, ""), raw)
+	}
+
+	return err
+}
+
+func (r *RedisDriver) Pop(ctx context.Context, queue string) (*Job, error) {
+	conn, err := r.Conn.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	now := time.Now()
+	reply, err := redis.Values(popScript.Do(conn,
+		r.key(queue, ""), r.key(queue, "delayed"), r.key(queue, "reserved"), r.key(queue, "attempts"),
+		now.UnixMilli(), now.Add(r.retryAfter()).UnixMilli()))
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var raw string
+	var job Job
+	if _, err := redis.Scan(reply, &raw, &job.Attempts); err != nil {
+		return nil, err
+	}
+
+	attempts := job.Attempts
+	if err := json.Unmarshal([]byte(raw), &job); err != nil {
+		return nil, err
+	}
+	job.Attempts = attempts
+	job.raw = raw
+
+	return &job, nil
+}
+
+func (r *RedisDriver) retryAfter() time.Duration {
+	if r.RetryAfter == 0 {
+		return 90 * time.Second
+	}
+	return r.RetryAfter
+}
+
+// Delete ends the reservation of job and forgets its count of attempts; Release pushes the
+// job again with its attempts in the json.
+func (r *RedisDriver) Delete(ctx context.Context, job *Job) error {
+	conn, err := r.Conn.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	conn.Send("MULTI")
+	conn.Send("ZREM", r.key(job.Queue, "reserved"), job.raw)
+	conn.Send("HDEL", r.key(job.Queue, "attempts"), job.ID)
+	_, err = conn.Do("EXEC")
+
+	return err
+}
+
+func (r *RedisDriver) Release(ctx context.Context, job *Job, delay time.Duration) error {
+	if err := r.Delete(ctx, job); err != nil {
+		return err
+	}
+
+	job.AvailableAt = time.Now().Add(delay)
+
+	return r.Push(ctx, job)
+}
+
+func (r *RedisDriver) Fail(ctx context.Context, job *Job) error {
+	if err := r.Delete(ctx, job); err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	conn, err := r.Conn.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Do("HSET", r.failedKey(), job.ID, raw)
+
+	return err
+}
+
+func (r *RedisDriver) Failed(ctx context.Context) ([]*Job, error) {
+	conn, err := r.Conn.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	values, err := redis.StringMap(conn.Do("HGETALL", r.failedKey()))
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]*Job, 0, len(values))
+	for _, raw := range values {
+		var job Job
+		if err := json.Unmarshal([]byte(raw), &job); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, &job)
+	}
+
+	return jobs, nil
+}
+
+func (r *RedisDriver) Retry(ctx context.Context, id string) error {
+	job, err := r.failedJob(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := r.Push(ctx, retryable(job)); err != nil {
+		return err
+	}
+
+	return r.Forget(ctx, id)
+}
+
+func (r *RedisDriver) Forget(ctx context.Context, id string) error {
+	conn, err := r.Conn.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	n, err := redis.Int(conn.Do("HDEL", r.failedKey(), id))
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *RedisDriver) failedJob(ctx context.Context, id string) (*Job, error) {
+	conn, err := r.Conn.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	raw, err := redis.Bytes(conn.Do("HGET", r.failedKey(), id))
+	if err == redis.ErrNil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var job Job
+	if err := json.Unmarshal(raw, &job); err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// key returns the redis key for a queue; the hash tag keeps all keys of one queue in the
+// same cluster slot, which the pop script requires.
+func (r *RedisDriver) key(queue, suffix string) string {
+	key := fmt.Sprintf("%s:queue:{%s}", r.Prefix, queue)
+	if suffix != "" {
+		key += ":" + suffix
+	}
+	return key
+}
+
+func (r *RedisDriver) failedKey() string {
+	return fmt.Sprintf("%s:queue:failed", r.Prefix)
+}
@@ -0,0 +1,40 @@
+package queue
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+)
+
+var testRedisServer *miniredis.Miniredis
+
+var testRedisDriver RedisDriver
+
+func TestMain(m *testing.M) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	testRedisServer = s
+
+	pool := redis.Pool{
+		MaxIdle:     50,
+		MaxActive:   1000,
+		IdleTimeout: 240 * time.Second,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", s.Addr())
+		},
+	}
+
+	testRedisDriver.Conn = &pool
+	testRedisDriver.Prefix = "test-napoleon"
+
+	defer testRedisDriver.Conn.Close()
+
+	os.Exit(m.Run())
+}
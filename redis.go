@@ -0,0 +1,283 @@
+package napoleon
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// redisConfigFromEnv reads the redis settings from the environment. Only REDIS_HOST is
+// required for a single server; REDIS_SENTINEL_ADDRS or REDIS_CLUSTER_ADDRS switch the
+// pool to sentinel or cluster mode.
+func (n *Napoleon) redisConfigFromEnv() RedisConfig {
+	maxIdle, err := strconv.Atoi(os.Getenv("REDIS_MAX_IDLE"))
+	if err != nil {
+		maxIdle = 50
+	}
+
+	maxActive, err := strconv.Atoi(os.Getenv("REDIS_MAX_ACTIVE"))
+	if err != nil {
+		maxActive = 10000
+	}
+
+	idleTimeout, err := strconv.Atoi(os.Getenv("REDIS_IDLE_TIMEOUT"))
+	if err != nil {
+		idleTimeout = 240
+	}
+
+	database, _ := strconv.Atoi(os.Getenv("REDIS_DB"))
+	useTLS, _ := strconv.ParseBool(os.Getenv("REDIS_TLS"))
+	skipVerify, _ := strconv.ParseBool(os.Getenv("REDIS_TLS_SKIP_VERIFY"))
+
+	return RedisConfig{
+		host:             os.Getenv("REDIS_HOST"),
+		username:         os.Getenv("REDIS_USERNAME"),
+		password:         os.Getenv("REDIS_PASSWORD"),
+		prefix:           os.Getenv("REDIS_PREFIX"),
+		database:         database,
+		maxIdle:          maxIdle,
+		maxActive:        maxActive,
+		idleTimeout:      time.Duration(idleTimeout) * time.Second,
+		useTLS:           useTLS,
+		tlsCA:            os.Getenv("REDIS_TLS_CA"),
+		tlsCert:          os.Getenv("REDIS_TLS_CERT"),
+		tlsKey:           os.Getenv("REDIS_TLS_KEY"),
+		tlsSkipVerify:    skipVerify,
+		sentinelAddrs:    splitList(os.Getenv("REDIS_SENTINEL_ADDRS")),
+		sentinelMaster:   os.Getenv("REDIS_SENTINEL_MASTER"),
+		sentinelPassword: os.Getenv("REDIS_SENTINEL_PASSWORD"),
+		clusterAddrs:     splitList(os.Getenv("REDIS_CLUSTER_ADDRS")),
+	}
+}
+
+// createRedisPool returns the pool shared by the redis cache and the redis session store.
+// Depending on the configuration, connections go to a single server, to the master
+// reported by sentinel, or are routed by hash slot across a cluster.
+//
+// On a cluster, a MULTI/EXEC transaction or a pipeline of Send calls goes to one node,
+// the one owning the key of its first keyed command, so all of its keys must hash to
+// the same slot; the node refuses the others with MOVED. Keys share a slot when they
+// share a hash tag, such as {user:42} in "{user:42}:sessions" and "{user:42}:profile".
+func (n *Napoleon) createRedisPool() *redis.Pool {
+	cfg := n.config.redis
+
+	pool := &redis.Pool{
+		MaxIdle:     cfg.maxIdle,
+		MaxActive:   cfg.maxActive,
+		IdleTimeout: cfg.idleTimeout,
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			_, err := c.Do("PING")
+			return err
+		},
+	}
+
+	switch {
+	case len(cfg.clusterAddrs) > 0:
+		cluster := &redisCluster{
+			addrs: cfg.clusterAddrs,
+			dial:  n.dialRedis,
+		}
+		pool.Dial = func() (redis.Conn, error) {
+			return &clusterConn{cluster: cluster}, nil
+		}
+
+	case len(cfg.sentinelAddrs) > 0:
+		sentinel := &redisSentinel{
+			addrs:  cfg.sentinelAddrs,
+			master: cfg.sentinelMaster,
+			dial:   n.dialSentinel,
+		}
+		pool.Dial = func() (redis.Conn, error) {
+			addr, err := sentinel.masterAddr()
+			if err != nil {
+				return nil, err
+			}
+			return n.dialRedis(addr)
+		}
+		// after a failover the old master comes back as a replica, so connections
+		// to it must be discarded and redialed against the new master
+		pool.TestOnBorrow = func(c redis.Conn, t time.Time) error {
+			role, err := redis.Values(c.Do("ROLE"))
+			if err != nil {
+				return err
+			}
+			if len(role) == 0 {
+				return errors.New("redis: empty ROLE reply")
+			}
+			if r, _ := redis.String(role[0], nil); r != "master" {
+				return fmt.Errorf("redis: connected to %s, not master", r)
+			}
+			return nil
+		}
+
+	default:
+		pool.Dial = func() (redis.Conn, error) {
+			return n.dialRedis(cfg.host)
+		}
+	}
+
+	return pool
+}
+
+// dialRedis opens a single connection to a redis server using the configured
+// credentials, database and TLS settings.
+func (n *Napoleon) dialRedis(addr string) (redis.Conn, error) {
+	cfg := n.config.redis
+
+	options := []redis.DialOption{
+		redis.DialConnectTimeout(5 * time.Second),
+		redis.DialPassword(cfg.password),
+	}
+
+	if cfg.username != "" {
+		options = append(options, redis.DialUsername(cfg.username))
+	}
+
+	// redis cluster only supports database 0
+	if cfg.database > 0 && len(cfg.clusterAddrs) == 0 {
+		options = append(options, redis.DialDatabase(cfg.database))
+	}
+
+	if cfg.useTLS {
+		tlsConfig, err := n.redisTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, redis.DialUseTLS(true), redis.DialTLSConfig(tlsConfig))
+	}
+
+	return redis.Dial("tcp", addr, options...)
+}
+
+// dialSentinel opens a connection to a sentinel. Sentinels share the TLS settings of
+// the data nodes, but have their own password.
+func (n *Napoleon) dialSentinel(addr string) (redis.Conn, error) {
+	cfg := n.config.redis
+
+	options := []redis.DialOption{
+		redis.DialConnectTimeout(500 * time.Millisecond),
+		redis.DialReadTimeout(500 * time.Millisecond),
+		redis.DialPassword(cfg.sentinelPassword),
+	}
+
+	if cfg.useTLS {
+		tlsConfig, err := n.redisTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, redis.DialUseTLS(true), redis.DialTLSConfig(tlsConfig))
+	}
+
+	return redis.Dial("tcp", addr, options...)
+}
+
+// redisTLSConfig builds the tls config from the CA bundle and client certificate given in
+// the environment. Both are optional; without a CA the system roots are used.
+func (n *Napoleon) redisTLSConfig() (*tls.Config, error) {
+	cfg := n.config.redis
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.tlsSkipVerify,
+	}
+
+	if cfg.tlsCA != "" {
+		ca, err := os.ReadFile(cfg.tlsCA)
+		if err != nil {
+			return nil, err
+		}
+
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("redis: no certificates found in %s", cfg.tlsCA)
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	if cfg.tlsCert != "" && cfg.tlsKey != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.tlsCert, cfg.tlsKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// redisSentinel discovers the current master through a list of sentinels.
+type redisSentinel struct {
+	addrs  []string
+	master string
+	dial   func(addr string) (redis.Conn, error)
+	mu     sync.Mutex
+}
+
+// masterAddr asks each sentinel in turn for the address of the master. The sentinel that
+// answers is moved to the front of the list so that it is asked first next time.
+func (s *redisSentinel) masterAddr() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var lastErr error
+
+	for i, addr := range s.addrs {
+		master, err := s.queryMaster(addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if i > 0 {
+			s.addrs[0], s.addrs[i] = s.addrs[i], s.addrs[0]
+		}
+
+		return master, nil
+	}
+
+	if lastErr == nil {
+		lastErr = errors.New("no sentinel addresses configured")
+	}
+
+	return "", fmt.Errorf("redis: could not discover master %q: %w", s.master, lastErr)
+}
+
+func (s *redisSentinel) queryMaster(addr string) (string, error) {
+	conn, err := s.dial(addr)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	res, err := redis.Strings(conn.Do("SENTINEL", "get-master-addr-by-name", s.master))
+	if err != nil {
+		return "", err
+	}
+
+	if len(res) != 2 {
+		return "", fmt.Errorf("sentinel %s does not know master %q", addr, s.master)
+	}
+
+	return fmt.Sprintf("%s:%s", res[0], res[1]), nil
+}
+
+func splitList(str string) []string {
+	var list []string
+
+	for _, x := range strings.Split(str, ",") {
+		x = strings.TrimSpace(x)
+		if x != "" {
+			list = append(list, x)
+		}
+	}
+
+	return list
+}
@@ -0,0 +1,417 @@
+package napoleon
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const redisClusterSlots = 16384
+
+// redisCluster keeps the slot map of a redis cluster and a pool of connections per node.
+type redisCluster struct {
+	addrs []string
+	dial  func(addr string) (redis.Conn, error)
+
+	mu    sync.RWMutex
+	slots []string
+	pools map[string]*redis.Pool
+}
+
+// refresh reloads the slot map with CLUSTER SLOTS from the first node that answers.
+func (c *redisCluster) refresh() error {
+	c.mu.RLock()
+	addrs := append([]string{}, c.addrs...)
+	for addr := range c.pools {
+		addrs = append(addrs, addr)
+	}
+	c.mu.RUnlock()
+
+	var lastErr error
+	for _, addr := range addrs {
+		slots, err := c.fetchSlots(addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		c.mu.Lock()
+		c.slots = slots
+		c.mu.Unlock()
+		return nil
+	}
+
+	if lastErr == nil {
+		lastErr = errors.New("no cluster addresses configured")
+	}
+
+	return fmt.Errorf("redis: could not load cluster slots: %w", lastErr)
+}
+
+func (c *redisCluster) fetchSlots(addr string) ([]string, error) {
+	conn, err := c.dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	ranges, err := redis.Values(conn.Do("CLUSTER", "SLOTS"))
+	if err != nil {
+		return nil, err
+	}
+
+	slots := make([]string, redisClusterSlots)
+	for _, r := range ranges {
+		info, err := redis.Values(r, nil)
+		if err != nil || len(info) < 3 {
+			return nil, errors.New("redis: malformed CLUSTER SLOTS reply")
+		}
+
+		start, _ := redis.Int(info[0], nil)
+		end, _ := redis.Int(info[1], nil)
+		master, err := redis.Values(info[2], nil)
+		if err != nil || len(master) < 2 {
+			return nil, errors.New("redis: malformed CLUSTER SLOTS reply")
+		}
+
+		host, _ := redis.String(master[0], nil)
+		port, _ := redis.Int(master[1], nil)
+		// an empty host means "the node you are talking to"
+		if host == "" {
+			host = strings.Split(addr, ":")[0]
+		}
+
+		for i := start; i <= end && i < redisClusterSlots; i++ {
+			slots[i] = fmt.Sprintf("%s:%d", host, port)
+		}
+	}
+
+	return slots, nil
+}
+
+// addrForSlot returns the node serving slot, loading the slot map on first use.
+func (c *redisCluster) addrForSlot(slot int) (string, error) {
+	c.mu.RLock()
+	loaded := c.slots != nil
+	c.mu.RUnlock()
+
+	if !loaded {
+		if err := c.refresh(); err != nil {
+			return "", err
+		}
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if addr := c.slots[slot]; addr != "" {
+		return addr, nil
+	}
+
+	return c.addrs[0], nil
+}
+
+// anyAddr returns a node for commands that do not take a key.
+func (c *redisCluster) anyAddr() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, addr := range c.slots {
+		if addr != "" {
+			return addr
+		}
+	}
+
+	return c.addrs[0]
+}
+
+// get borrows a connection to the node at addr.
+func (c *redisCluster) get(addr string) redis.Conn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pools == nil {
+		c.pools = make(map[string]*redis.Pool)
+	}
+
+	pool, ok := c.pools[addr]
+	if !ok {
+		pool = &redis.Pool{
+			MaxIdle:     10,
+			IdleTimeout: 240 * time.Second,
+			Dial: func() (redis.Conn, error) {
+				return c.dial(addr)
+			},
+		}
+		c.pools[addr] = pool
+	}
+
+	return pool.Get()
+}
+
+type redisCommand struct {
+	name string
+	args []interface{}
+}
+
+// clusterConn is a redis.Conn that sends each command to the node owning its key. Pipelined
+// commands (Send/Flush/Receive, including MULTI/EXEC) all go to the node of the first
+// keyed command, so their keys must hash to the same slot. SCAN is routed by the hash tag
+// in its MATCH pattern; give the cache a prefix like "{myapp}" so Empty works on a cluster.
+type clusterConn struct {
+	cluster *redisCluster
+	pending []redisCommand
+	conn    redis.Conn
+	replies int
+}
+
+func (c *clusterConn) Close() error {
+	c.pending = nil
+	c.replies = 0
+	if c.conn != nil {
+		err := c.conn.Close()
+		c.conn = nil
+		return err
+	}
+
+	return nil
+}
+
+func (c *clusterConn) Err() error {
+	return nil
+}
+
+func (c *clusterConn) Send(commandName string, args ...interface{}) error {
+	c.pending = append(c.pending, redisCommand{name: commandName, args: args})
+	return nil
+}
+
+func (c *clusterConn) Flush() error {
+	if len(c.pending) == 0 {
+		return nil
+	}
+
+	if c.conn == nil {
+		addr, err := c.route(c.pending)
+		if err != nil {
+			return err
+		}
+		c.conn = c.cluster.get(addr)
+	}
+
+	for _, cmd := range c.pending {
+		if err := c.conn.Send(cmd.name, cmd.args...); err != nil {
+			return err
+		}
+	}
+
+	c.replies += len(c.pending)
+	c.pending = nil
+
+	return c.conn.Flush()
+}
+
+func (c *clusterConn) Receive() (interface{}, error) {
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+
+	if c.replies == 0 {
+		return nil, errors.New("redis: no pending replies")
+	}
+
+	reply, err := c.conn.Receive()
+	c.replies--
+	if c.replies == 0 {
+		c.conn.Close()
+		c.conn = nil
+	}
+
+	return reply, err
+}
+
+func (c *clusterConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	// a lone command can follow MOVED and ASK redirections
+	if len(c.pending) == 0 && c.replies == 0 {
+		if commandName == "" {
+			return nil, nil
+		}
+		return c.doSingle(redisCommand{name: commandName, args: args})
+	}
+
+	if commandName != "" {
+		c.pending = append(c.pending, redisCommand{name: commandName, args: args})
+	}
+
+	n := c.replies + len(c.pending)
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+
+	replies := make([]interface{}, n)
+	var lastErr error
+	for i := range replies {
+		reply, err := c.Receive()
+		if err != nil {
+			if _, ok := err.(redis.Error); !ok {
+				return nil, err
+			}
+			lastErr = err
+		}
+		replies[i] = reply
+	}
+
+	if commandName == "" {
+		return replies, nil
+	}
+
+	if lastErr != nil && replies[n-1] == nil {
+		return nil, lastErr
+	}
+
+	return replies[n-1], nil
+}
+
+func (c *clusterConn) doSingle(cmd redisCommand) (interface{}, error) {
+	addr, err := c.route([]redisCommand{cmd})
+	if err != nil {
+		return nil, err
+	}
+
+	asking := false
+	for attempt := 0; attempt < 3; attempt++ {
+		conn := c.cluster.get(addr)
+		if asking {
+			_, _ = conn.Do("ASKING")
+		}
+		reply, err := conn.Do(cmd.name, cmd.args...)
+		conn.Close()
+
+		redisErr, ok := err.(redis.Error)
+		if !ok {
+			return reply, err
+		}
+
+		// redirections look like "MOVED 3999 127.0.0.1:6381"
+		parts := strings.Fields(string(redisErr))
+		if len(parts) != 3 || (parts[0] != "MOVED" && parts[0] != "ASK") {
+			return reply, err
+		}
+
+		addr = parts[2]
+		asking = parts[0] == "ASK"
+		if parts[0] == "MOVED" {
+			_ = c.cluster.refresh()
+		}
+	}
+
+	return nil, fmt.Errorf("redis: too many cluster redirections for %s", cmd.name)
+}
+
+// route picks the node for a batch of commands from the first command that has a key.
+func (c *clusterConn) route(cmds []redisCommand) (string, error) {
+	for _, cmd := range cmds {
+		if key, ok := commandKey(cmd); ok {
+			return c.cluster.addrForSlot(keySlot(key))
+		}
+	}
+
+	if err := c.ensureSlots(); err != nil {
+		return "", err
+	}
+
+	return c.cluster.anyAddr(), nil
+}
+
+func (c *clusterConn) ensureSlots() error {
+	c.cluster.mu.RLock()
+	loaded := c.cluster.slots != nil
+	c.cluster.mu.RUnlock()
+
+	if loaded {
+		return nil
+	}
+
+	return c.cluster.refresh()
+}
+
+// commandKey returns the key that decides which node a command is sent to.
+func commandKey(cmd redisCommand) (string, bool) {
+	switch strings.ToUpper(cmd.name) {
+	case "", "PING", "MULTI", "EXEC", "DISCARD", "UNWATCH", "INFO", "AUTH", "SELECT", "ECHO",
+		"TIME", "CLUSTER", "ROLE", "ASKING", "READONLY", "KEYS":
+		return "", false
+
+	case "EVAL", "EVALSHA":
+		if len(cmd.args) < 3 {
+			return "", false
+		}
+		if numKeys, _ := strconv.Atoi(argString(cmd.args[1])); numKeys == 0 {
+			return "", false
+		}
+		return argString(cmd.args[2]), true
+
+	case "SCAN":
+		for i := 0; i < len(cmd.args)-1; i++ {
+			if strings.EqualFold(argString(cmd.args[i]), "MATCH") {
+				pattern := argString(cmd.args[i+1])
+				if hashTag(pattern) != pattern {
+					return pattern, true
+				}
+			}
+		}
+		return "", false
+	}
+
+	if len(cmd.args) == 0 {
+		return "", false
+	}
+
+	return argString(cmd.args[0]), true
+}
+
+func argString(arg interface{}) string {
+	switch v := arg.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// hashTag returns the part of key between the first "{" and the following "}", or the
+// whole key if there is no non-empty tag.
+func hashTag(key string) string {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return key[start+1 : start+1+end]
+		}
+	}
+
+	return key
+}
+
+// keySlot computes the cluster hash slot of key: CRC16 (XMODEM) modulo 16384.
+func keySlot(key string) int {
+	var crc uint16
+
+	for _, b := range []byte(hashTag(key)) {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return int(crc) % redisClusterSlots
+}
@@ -0,0 +1,81 @@
+package napoleon
+
+import "testing"
+
+func TestKeySlot(t *testing.T) {
+	var tests = []struct {
+		key  string
+		slot int
+	}{
+		// the example of the cluster specification
+		{"123456789", 12739},
+		{"", 0},
+		{"foo", 12182},
+		{"bar", 5061},
+		{"hello", 866},
+		// only the hash tag is hashed
+		{"{bar}", 5061},
+		{"foo{bar}", 5061},
+		{"{user1000}.following", keySlot("user1000")},
+		{"{user1000}.followers", keySlot("user1000")},
+	}
+
+	for _, e := range tests {
+		if got := keySlot(e.key); got != e.slot {
+			t.Errorf("%q: expected slot %d, got %d", e.key, e.slot, got)
+		}
+	}
+}
+
+func TestHashTag(t *testing.T) {
+	// the cases of the cluster specification
+	var tests = []struct {
+		key string
+		tag string
+	}{
+		{"{user1000}.following", "user1000"},
+		{"foo{bar}{zap}", "bar"},
+		{"foo{{bar}}zap", "{bar"},
+		{"foo{}{bar}", "foo{}{bar}"},
+		{"foo{bar", "foo{bar"},
+		{"foo}bar{", "foo}bar{"},
+		{"plain", "plain"},
+	}
+
+	for _, e := range tests {
+		if got := hashTag(e.key); got != e.tag {
+			t.Errorf("%q: expected %q, got %q", e.key, e.tag, got)
+		}
+	}
+}
+
+func TestCommandKey(t *testing.T) {
+	var tests = []struct {
+		name string
+		cmd  redisCommand
+		key  string
+		ok   bool
+	}{
+		{"get", redisCommand{"GET", []interface{}{"app:user"}}, "app:user", true},
+		{"lower_case", redisCommand{"set", []interface{}{"app:user", "jack"}}, "app:user", true},
+		{"bytes", redisCommand{"GET", []interface{}{[]byte("app:user")}}, "app:user", true},
+		{"no_args", redisCommand{"DBSIZE", nil}, "", false},
+		{"ping", redisCommand{"PING", nil}, "", false},
+		{"multi", redisCommand{"MULTI", nil}, "", false},
+		{"exec", redisCommand{"EXEC", nil}, "", false},
+		{"keys", redisCommand{"KEYS", []interface{}{"app:*"}}, "", false},
+		{"eval", redisCommand{"EVAL", []interface{}{"return 1", 1, "app:lock"}}, "app:lock", true},
+		{"evalsha", redisCommand{"EVALSHA", []interface{}{"abc", "2", "app:a", "app:b"}}, "app:a", true},
+		{"eval_no_keys", redisCommand{"EVAL", []interface{}{"return 1", 0}}, "", false},
+		{"scan_tagged", redisCommand{"SCAN", []interface{}{0, "MATCH", "{app}:*", "COUNT", 100}}, "{app}:*", true},
+		{"scan_untagged", redisCommand{"SCAN", []interface{}{0, "match", "app:*"}}, "", false},
+		{"scan_all", redisCommand{"SCAN", []interface{}{0}}, "", false},
+	}
+
+	for _, e := range tests {
+		key, ok := commandKey(e.cmd)
+		if key != e.key || ok != e.ok {
+			t.Errorf("%s: expected %q %v, got %q %v", e.name, e.key, e.ok, key, ok)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package render
+
+import (
+	"net/http"
+	"path"
+)
+
+// Composer adds data to the pages it is registered for, such as the current user or
+// the enabled feature flags, so that handlers do not each have to.
+type Composer func(r *http.Request, td *TemplateData)
+
+type composer struct {
+	views []string
+	fn    Composer
+}
+
+// Compose registers fn to run before the views matching any of the patterns are
+// rendered, or before all views without patterns. Patterns are those of path.Match,
+// such as "admin/*".
+func (c *Render) Compose(fn Composer, views ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.composers = append(c.composers, composer{views: views, fn: fn})
+}
+
+// compose runs the composers for view, in the order they were registered.
+func (c *Render) compose(r *http.Request, view string, td *TemplateData) {
+	c.mu.RLock()
+	composers := c.composers
+	c.mu.RUnlock()
+
+	for _, cp := range composers {
+		if !cp.matches(view) {
+			continue
+		}
+
+		if td.Data == nil {
+			td.Data = make(map[string]interface{})
+		}
+
+		cp.fn(r, td)
+	}
+}
+
+func (cp composer) matches(view string) bool {
+	if len(cp.views) == 0 {
+		return true
+	}
+
+	for _, pattern := range cp.views {
+		if ok, _ := path.Match(pattern, view); ok {
+			return true
+		}
+	}
+
+	return false
+}
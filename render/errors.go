@@ -0,0 +1,146 @@
+package render
+
+import (
+	"bufio"
+	"bytes"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// templateErrorLocation finds the template and line in the errors of html/template,
+// such as `template: home.page.tmpl:3:2: executing...`, and of Jet, such as
+// `Jet Runtime Error ("/home.jet":3): ...`.
+var templateErrorLocation = regexp.MustCompile(`(?:template: |Jet Runtime Error \(")([^:"\s]+)"?:(\d+)`)
+
+// renderError answers a request whose page failed to render with status 500. In Debug
+// mode that is a page with the error and the template source around it; otherwise
+// ErrorView, if set, or plain text. ErrorView gets the messages and form state of td,
+// the data of the failed page.
+func (n *Render) renderError(w http.ResponseWriter, r *http.Request, view string, td *TemplateData, err error) {
+	if n.Debug {
+		n.debugPage(w, view, err)
+		return
+	}
+
+	if n.ErrorView != "" && n.ErrorView != view {
+		buf := getBuffer()
+		defer putBuffer(buf)
+
+		errorData := *td
+		errorData.Data = map[string]interface{}{"status": http.StatusInternalServerError}
+		n.compose(r, n.ErrorView, &errorData)
+
+		var viewErr error
+		switch strings.ToLower(n.Renderer) {
+		case "go":
+			viewErr = n.renderGo(buf, n.ErrorView, &errorData)
+		case "jet":
+			viewErr = n.renderJet(buf, r, n.ErrorView, nil, &errorData)
+		}
+
+		if viewErr == nil {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = buf.WriteTo(w)
+			return
+		}
+	}
+
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// sourceLine is a line of template source on the debug page.
+type sourceLine struct {
+	Number int
+	Text   string
+	Failed bool
+}
+
+func (n *Render) debugPage(w http.ResponseWriter, view string, err error) {
+	data := struct {
+		View     string
+		Error    string
+		Template string
+		Line     int
+		Source   []sourceLine
+	}{
+		View:  view,
+		Error: err.Error(),
+	}
+
+	if m := templateErrorLocation.FindStringSubmatch(err.Error()); m != nil {
+		data.Template = m[1]
+		data.Line, _ = strconv.Atoi(m[2])
+		data.Source = n.templateSource(data.Template, data.Line, 5)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+
+	_ = debugTemplate.Execute(w, data)
+}
+
+// templateSource returns the lines of the template called name around line, looking
+// for the file under views by its path and then by its base name, as Go templates are
+// named after the file without its directory.
+func (n *Render) templateSource(name string, line, context int) []sourceLine {
+	views := n.views()
+	file := fsPath(name)
+
+	if _, err := fs.Stat(views, file); err != nil {
+		file = ""
+		_ = fs.WalkDir(views, ".", func(p string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && path.Base(p) == path.Base(name) {
+				file = p
+				return fs.SkipAll
+			}
+			return nil
+		})
+	}
+
+	if file == "" {
+		return nil
+	}
+
+	content, err := fs.ReadFile(views, file)
+	if err != nil {
+		return nil
+	}
+
+	var lines []sourceLine
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for number := 1; scanner.Scan(); number++ {
+		if number >= line-context && number <= line+context {
+			lines = append(lines, sourceLine{Number: number, Text: scanner.Text(), Failed: number == line})
+		}
+	}
+
+	return lines
+}
+
+var debugTemplate = template.Must(template.New("debug").Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Template error</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+pre { background: #f6f6f6; padding: 1em; overflow: auto; }
+.failed { background: #fdd; display: block; }
+.number { color: #999; display: inline-block; width: 3em; }
+</style>
+</head>
+<body>
+<h1>Error rendering {{.View}}</h1>
+<pre>{{.Error}}</pre>
+{{if .Template}}<h2>{{.Template}}, line {{.Line}}</h2>{{end}}
+{{if .Source}}<pre>{{range .Source}}<span{{if .Failed}} class="failed"{{end}}><span class="number">{{.Number}}</span>{{.Text}}
+</span>{{end}}</pre>{{end}}
+</body>
+</html>
+`))
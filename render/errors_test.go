@@ -0,0 +1,135 @@
+package render
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRender_PageError(t *testing.T) {
+	defer func() {
+		testRenderer.Debug = false
+		testRenderer.ErrorView = ""
+	}()
+
+	tests := []struct {
+		name      string
+		debug     bool
+		errorView string
+		contains  []string
+	}{
+		{"plain", false, "", []string{"Internal Server Error"}},
+		{"error view", false, "errors/500", []string{"something went wrong 500"}},
+		{"debug", true, "errors/500", []string{"broken", "line 2", "nope"}},
+	}
+
+	for _, engine := range []string{"go", "jet"} {
+		for _, tt := range tests {
+			testRenderer.Renderer = engine
+			testRenderer.RootPath = "./testdata"
+			testRenderer.Debug = tt.debug
+			testRenderer.ErrorView = tt.errorView
+
+			r := loadSession(t, httptest.NewRequest("GET", "/", nil))
+			w := httptest.NewRecorder()
+
+			if err := testRenderer.Page(w, r, "broken", nil, nil); err == nil {
+				t.Errorf("%s %s: expected an error", engine, tt.name)
+			}
+
+			if w.Code != http.StatusInternalServerError {
+				t.Errorf("%s %s: expected 500, got %d", engine, tt.name, w.Code)
+			}
+
+			body := w.Body.String()
+			if strings.Contains(body, "first line") && !tt.debug {
+				t.Errorf("%s %s: half rendered page written", engine, tt.name)
+			}
+
+			for _, s := range tt.contains {
+				if !strings.Contains(strings.ToLower(body), strings.ToLower(s)) {
+					t.Errorf("%s %s: expected %q in %q", engine, tt.name, s, body)
+				}
+			}
+		}
+	}
+}
+
+func TestRender_PageStatus(t *testing.T) {
+	testRenderer.Renderer = "go"
+	testRenderer.RootPath = "./testdata"
+
+	r := loadSession(t, httptest.NewRequest("GET", "/", nil))
+	w := httptest.NewRecorder()
+
+	if err := testRenderer.PageStatus(w, r, http.StatusNotFound, "home", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Errorf("wrong response: %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+func TestRender_PageErrorKeepsMessages(t *testing.T) {
+	defer func() {
+		testRenderer.ErrorView = ""
+	}()
+
+	for _, engine := range []string{"go", "jet"} {
+		testRenderer.Renderer = engine
+		testRenderer.RootPath = "./testdata"
+		testRenderer.ErrorView = "errors/500"
+
+		r := loadSession(t, httptest.NewRequest("GET", "/", nil))
+		testRenderer.Session.Put(r.Context(), FlashKey, " saved")
+		w := httptest.NewRecorder()
+
+		_ = testRenderer.Page(w, r, "broken", nil, nil)
+
+		// the flash was popped for the broken page, so the error page must show it
+		if body := w.Body.String(); body != "something went wrong 500 saved" {
+			t.Errorf("%s: expected the flash on the error page, got %q", engine, body)
+		}
+	}
+}
+
+func TestRender_String(t *testing.T) {
+	for _, engine := range []string{"go", "jet"} {
+		testRenderer.Renderer = engine
+		testRenderer.RootPath = "./testdata"
+
+		s, err := testRenderer.String("greeting", struct{ Name string }{"Jack"})
+		if err != nil {
+			t.Errorf("%s: %v", engine, err)
+		}
+
+		if s != "Hello Jack" {
+			t.Errorf("%s: expected Hello Jack, got %q", engine, s)
+		}
+	}
+}
+
+func TestRender_Text(t *testing.T) {
+	testRenderer.RootPath = "./testdata"
+	data := struct{ Name string }{"Jack & Jill"}
+
+	for _, engine := range []string{"go", "jet"} {
+		testRenderer.Renderer = engine
+
+		html, err := testRenderer.String("greeting", data)
+		if err != nil {
+			t.Fatalf("%s: %v", engine, err)
+		}
+
+		text, err := testRenderer.Text("greeting", data)
+		if err != nil {
+			t.Fatalf("%s: %v", engine, err)
+		}
+
+		if html != "Hello Jack &amp; Jill" || text != "Hello Jack & Jill" {
+			t.Errorf("%s: expected only the html to be escaped, got %q and %q", engine, html, text)
+		}
+	}
+}
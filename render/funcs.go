@@ -0,0 +1,114 @@
+package render
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/CloudyKit/jet/v6"
+)
+
+// AddFunc makes fn available as name in both Jet and Go templates, so JetViews must be
+// a set made by jet.NewSet. Go templates parsed before are parsed again, as Go binds
+// functions at parse time.
+func (c *Render) AddFunc(name string, fn interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.FuncMap == nil {
+		c.FuncMap = template.FuncMap{}
+	}
+	c.FuncMap[name] = fn
+	c.templates = nil
+	c.textTemplates = nil
+
+	c.JetViews.AddGlobal(name, fn)
+	if c.JetTextViews != nil {
+		c.JetTextViews.AddGlobal(name, fn)
+	}
+}
+
+// AddDefaultFuncs adds the helpers every application gets:
+//
+//	url("/users", "page", 2)     /users?page=2, after BaseURL
+//	asset("css/app.css")         /public/css/app.css?v=<Version>
+//	date(t, "Jan 2, 2006")       t formatted, as 2006-01-02 without a layout
+//	csrf_field(.)                the hidden csrf_token input; csrf_field() in Jet
+func (c *Render) AddDefaultFuncs() {
+	c.AddFunc("url", c.url)
+	c.AddFunc("asset", c.asset)
+	c.AddFunc("date", formatDate)
+	c.AddFunc("csrf_field", csrfField)
+
+	// Jet has no dot to pass, so its csrf_field reads the token from the page data
+	c.JetViews.AddGlobalFunc("csrf_field", func(a jet.Arguments) reflect.Value {
+		a.RequireNumOfArguments("csrf_field", 0, 1)
+
+		var td *TemplateData
+		if a.NumOfArguments() == 1 {
+			td, _ = a.Get(0).Interface().(*TemplateData)
+		} else if ctx := a.Runtime().Context(); ctx.IsValid() {
+			td, _ = ctx.Interface().(*TemplateData)
+		}
+
+		// a renderer writes past Jet's escaping, as template.HTML does for Go
+		field := string(csrfField(td))
+		return reflect.ValueOf(jet.RendererFunc(func(r *jet.Runtime) {
+			_, _ = io.WriteString(r.Writer, field)
+		}))
+	})
+}
+
+// url returns the address of path under BaseURL, with pairs of query parameters.
+func (c *Render) url(path string, query ...interface{}) string {
+	u := strings.TrimSuffix(c.BaseURL, "/") + "/" + strings.TrimPrefix(path, "/")
+
+	if len(query) > 0 {
+		values := url.Values{}
+		for i := 0; i+1 < len(query); i += 2 {
+			values.Add(fmt.Sprint(query[i]), fmt.Sprint(query[i+1]))
+		}
+		u += "?" + values.Encode()
+	}
+
+	return u
+}
+
+// asset returns the address of a file under AssetsURL, versioned to bust caches.
+func (c *Render) asset(path string) string {
+	base := c.AssetsURL
+	if base == "" {
+		base = "/public"
+	}
+
+	u := strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(path, "/")
+	if c.Version != "" {
+		u += "?v=" + url.QueryEscape(c.Version)
+	}
+
+	return u
+}
+
+func formatDate(t time.Time, layout ...string) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	if len(layout) > 0 && layout[0] != "" {
+		return t.Format(layout[0])
+	}
+
+	return t.Format("2006-01-02")
+}
+
+func csrfField(td *TemplateData) template.HTML {
+	if td == nil {
+		return ""
+	}
+
+	return template.HTML(`<input type="hidden" name="csrf_token" value="` + template.HTMLEscapeString(td.CSRFToken) + `">`)
+}
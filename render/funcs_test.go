@@ -0,0 +1,71 @@
+package render
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRender_DefaultFuncs(t *testing.T) {
+	testRenderer.RootPath = "./testdata"
+	testRenderer.BaseURL = "https://example.com/"
+	testRenderer.Version = "1.2"
+	testRenderer.AddDefaultFuncs()
+	testRenderer.Compose(func(r *http.Request, td *TemplateData) {
+		td.Data["user"] = "jack"
+	}, "funcs")
+
+	for _, engine := range []string{"go", "jet"} {
+		testRenderer.Renderer = engine
+
+		r := loadSession(t, httptest.NewRequest("GET", "/", nil))
+		w := httptest.NewRecorder()
+
+		if err := testRenderer.Page(w, r, "funcs", nil, nil); err != nil {
+			t.Fatalf("%s: %v", engine, err)
+		}
+
+		// the csrf token is empty outside of nosurf
+		want := `<input type="hidden" name="csrf_token" value="">|https://example.com/users?page=2|/public/css/app.css?v=1.2|jack`
+		if got := w.Body.String(); got != want {
+			t.Errorf("%s: expected %s, got %s", engine, want, got)
+		}
+	}
+}
+
+func TestRender_Compose(t *testing.T) {
+	rnd := &Render{}
+
+	var order []string
+	rnd.Compose(func(r *http.Request, td *TemplateData) { order = append(order, "all") })
+	rnd.Compose(func(r *http.Request, td *TemplateData) { order = append(order, "admin") }, "admin/*")
+
+	td := &TemplateData{}
+	rnd.compose(nil, "admin/users", td)
+	rnd.compose(nil, "home", td)
+
+	if len(order) != 3 || order[0] != "all" || order[1] != "admin" || order[2] != "all" {
+		t.Errorf("composers ran wrong: %v", order)
+	}
+
+	if td.Data == nil {
+		t.Error("expected composers to get a data map")
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	d := time.Date(2024, 3, 9, 10, 0, 0, 0, time.UTC)
+
+	if got := formatDate(d); got != "2024-03-09" {
+		t.Errorf("wrong default format: %s", got)
+	}
+
+	if got := formatDate(d, "Jan 2, 2006"); got != "Mar 9, 2024" {
+		t.Errorf("wrong format: %s", got)
+	}
+
+	if got := formatDate(time.Time{}); got != "" {
+		t.Errorf("expected zero time to be empty, got %s", got)
+	}
+}
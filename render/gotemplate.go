@@ -0,0 +1,148 @@
+package render
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Go templates are named after their role: views/<name>.page.tmpl are pages, and every
+// *.layout.tmpl and *.partial.tmpl under views is parsed with each page, so pages can
+// {{template "base" .}} a shared layout and use shared partials.
+const (
+	pageSuffix    = ".page.tmpl"
+	layoutSuffix  = ".layout.tmpl"
+	partialSuffix = ".partial.tmpl"
+)
+
+// executor is a parsed html/template or text/template.
+type executor interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// goTemplate is a parsed page with the modification time of its newest file.
+type goTemplate struct {
+	tmpl     executor
+	modified time.Time
+}
+
+// goTemplate returns the parsed page called view from the cache, parsing it first if
+// needed. In Debug mode the files are checked on every call, and changed pages parsed
+// again, so that edits show up without a restart.
+func (c *Render) goTemplate(view string) (executor, error) {
+	return c.parsedTemplate(view, false)
+}
+
+// textTemplate is goTemplate for output that is not html, such as the plain text part
+// of emails, parsed with text/template so that it is not html escaped.
+func (c *Render) textTemplate(view string) (executor, error) {
+	return c.parsedTemplate(view, true)
+}
+
+func (c *Render) parsedTemplate(view string, text bool) (executor, error) {
+	cache := &c.templates
+	if text {
+		cache = &c.textTemplates
+	}
+
+	c.mu.RLock()
+	cached, ok := (*cache)[view]
+	c.mu.RUnlock()
+
+	if ok && !c.Debug {
+		return cached.tmpl, nil
+	}
+
+	files, err := c.goTemplateFiles(view)
+	if err != nil {
+		return nil, err
+	}
+
+	modified, err := newest(c.views(), files)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok && !modified.After(cached.modified) {
+		return cached.tmpl, nil
+	}
+
+	var tmpl executor
+	if text {
+		tmpl, err = texttemplate.New(path.Base(files[0])).Funcs(texttemplate.FuncMap(c.FuncMap)).ParseFS(c.views(), files...)
+	} else {
+		tmpl, err = template.New(path.Base(files[0])).Funcs(c.FuncMap).ParseFS(c.views(), files...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if *cache == nil {
+		*cache = make(map[string]goTemplate)
+	}
+	(*cache)[view] = goTemplate{tmpl: tmpl, modified: modified}
+
+	return tmpl, nil
+}
+
+// CreateTemplateCache parses all Go template pages, so that template errors show up at
+// start up rather than on the first request for a page.
+func (c *Render) CreateTemplateCache() error {
+	return fs.WalkDir(c.views(), ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(name, pageSuffix) {
+			return err
+		}
+
+		_, err = c.goTemplate(strings.TrimSuffix(name, pageSuffix))
+		return err
+	})
+}
+
+// goTemplateFiles returns the page file for view followed by all layouts and partials.
+func (c *Render) goTemplateFiles(view string) ([]string, error) {
+	files := []string{fsPath(view + pageSuffix)}
+
+	err := fs.WalkDir(c.views(), ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		if strings.HasSuffix(name, layoutSuffix) || strings.HasSuffix(name, partialSuffix) {
+			files = append(files, name)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("render: reading views: %w", err)
+	}
+
+	return files, nil
+}
+
+// newest returns the latest modification time of files, failing if one is missing.
+// Embedded files have no modification time, which is fine as they cannot change.
+func newest(fsys fs.FS, files []string) (time.Time, error) {
+	var modified time.Time
+
+	for _, file := range files {
+		info, err := fs.Stat(fsys, file)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if info.ModTime().After(modified) {
+			modified = info.ModTime()
+		}
+	}
+
+	return modified, nil
+}
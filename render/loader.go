@@ -0,0 +1,45 @@
+package render
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FSLoader is a Jet loader for views in an fs.FS, such as an embed.FS, so that an
+// application can ship its views inside its binary.
+type FSLoader struct {
+	FS fs.FS
+}
+
+// NewFSLoader returns a Jet loader for the views in fsys.
+func NewFSLoader(fsys fs.FS) *FSLoader {
+	return &FSLoader{FS: fsys}
+}
+
+func (l *FSLoader) Exists(templatePath string) bool {
+	info, err := fs.Stat(l.FS, fsPath(templatePath))
+	return err == nil && !info.IsDir()
+}
+
+func (l *FSLoader) Open(templatePath string) (io.ReadCloser, error) {
+	return l.FS.Open(fsPath(templatePath))
+}
+
+// fsPath turns the absolute, slash separated paths Jet uses into fs.FS paths.
+func fsPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+}
+
+// views returns the file system the views are read from: Views, or else the views
+// directory under RootPath.
+func (c *Render) views() fs.FS {
+	if c.Views != nil {
+		return c.Views
+	}
+
+	return os.DirFS(filepath.Join(c.RootPath, "views"))
+}
@@ -0,0 +1,59 @@
+package render
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/CloudyKit/jet/v6"
+	"github.com/alexedwards/scs/v2"
+)
+
+var testViews = fstest.MapFS{
+	"home.jet":                     {Data: []byte("embedded jet")},
+	"pages/about.page.tmpl":        {Data: []byte(`{{template "base" .}}{{define "content"}}about{{end}}`)},
+	"layouts/base.layout.tmpl":     {Data: []byte(`{{define "base"}}[{{block "content" .}}{{end}}]{{end}}`)},
+	"partials/unused.partial.tmpl": {Data: []byte(`{{define "unused"}}{{end}}`)},
+}
+
+func TestFSLoader(t *testing.T) {
+	l := NewFSLoader(testViews)
+
+	if !l.Exists("/home.jet") || l.Exists("/missing.jet") || l.Exists("/pages") {
+		t.Error("wrong templates reported as existing")
+	}
+
+	if !l.Exists("/../home.jet") {
+		t.Error("expected paths to be cleaned")
+	}
+}
+
+func TestRender_Views(t *testing.T) {
+	rnd := &Render{
+		Views:    testViews,
+		JetViews: *jet.NewSet(NewFSLoader(testViews)),
+		Session:  *scs.New(),
+	}
+
+	tests := map[string]string{"jet": "embedded jet", "go": "[about]"}
+	views := map[string]string{"jet": "home", "go": "pages/about"}
+
+	for engine, want := range tests {
+		rnd.Renderer = engine
+
+		r := httptest.NewRequest("GET", "/", nil)
+		ctx, _ := rnd.Session.Load(r.Context(), "")
+		r = r.WithContext(ctx)
+
+		w := httptest.NewRecorder()
+		if err := rnd.Page(w, r, views[engine], nil, nil); err != nil {
+			t.Errorf("%s: %v", engine, err)
+			continue
+		}
+
+		if got := strings.TrimSpace(w.Body.String()); got != want {
+			t.Errorf("%s: expected %q, got %q", engine, want, got)
+		}
+	}
+}
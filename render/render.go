@@ -1,12 +1,15 @@
 package render
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"html/template"
-	"log"
+	"io/fs"
 	"net/http"
+	"reflect"
 	"strings"
+	"sync"
 
 	"github.com/CloudyKit/jet/v6"
 	"github.com/alexedwards/scs/v2"
@@ -20,66 +23,268 @@ type Render struct {
 	Port       string
 	ServerName string
 	JetViews   jet.Set
-	Session    scs.SessionManager
+	// JetTextViews renders the Jet views of Text; it must load the views of JetViews
+	// without html escaping, as jet.NewSet(loader, jet.WithSafeWriter(nil)) does
+	JetTextViews *jet.Set
+	Session      scs.SessionManager
+	// IsAuthenticated decides TemplateData.IsAuth; without it a userID in the session
+	// counts as logged in
+	IsAuthenticated func(r *http.Request) bool
+	// Can answers the can(action, resource) checks of Jet templates; without it they deny
+	Can func(r *http.Request, action string, resource interface{}) bool
+	// Debug reparses changed Go templates on every request instead of caching them
+	Debug bool
+	// FuncMap holds the functions available to Go templates
+	FuncMap template.FuncMap
+	// Views holds the views, such as an embedded views directory; without it they are
+	// read from the views directory under RootPath
+	Views fs.FS
+	// ErrorView is the page shown with status 500 when a page fails to render; Debug
+	// mode shows the error with the failing template source instead
+	ErrorView string
+
+	// BaseURL prefixes the urls of the url template function
+	BaseURL string
+	// AssetsURL is where the asset template function points, "/public" by default
+	AssetsURL string
+	// Version is added to asset urls, so browsers fetch assets again after a release
+	Version string
+
+	mu            sync.RWMutex
+	templates     map[string]goTemplate
+	textTemplates map[string]goTemplate
+	composers     []composer
 }
 
+// Session keys used to carry messages and form state over to the next rendered page.
+const (
+	FlashKey    = "flash"
+	ErrorKey    = "error"
+	WarningKey  = "warning"
+	OldInputKey = "oldInput"
+	ErrorsKey   = "validationErrors"
+)
+
 type TemplateData struct {
 	IsAuth     bool
-	initMap    map[string]int
-	StringMap  map[string]int
+	IntMap     map[string]int
+	StringMap  map[string]string
 	FloatMap   map[string]float32
 	Data       map[string]interface{}
 	CSRFToken  string
 	Port       string
 	ServerName string
 	Secure     bool
+	Flash      string
+	Error      string
+	Warning    string
+	OldInput   map[string]string
+	Errors     map[string]string
+}
+
+// Old returns the value submitted for field by the previous request, to refill forms.
+func (td *TemplateData) Old(field string) string {
+	return td.OldInput[field]
+}
+
+// HasError reports whether validation failed for field.
+func (td *TemplateData) HasError(field string) bool {
+	_, ok := td.Errors[field]
+	return ok
+}
+
+// ErrorFor returns the validation error for field, or "".
+func (td *TemplateData) ErrorFor(field string) string {
+	return td.Errors[field]
 }
 
 func (c *Render) defaultData(td *TemplateData, r *http.Request) *TemplateData {
+	ctx := r.Context()
+
 	td.Secure = c.Secure
 	td.ServerName = c.ServerName
 	td.CSRFToken = nosurf.Token(r)
 	td.Port = c.Port
-	if c.Session.Exists(r.Context(), "userID") {
+	if c.IsAuthenticated != nil {
+		td.IsAuth = c.IsAuthenticated(r)
+	} else if c.Session.Exists(ctx, "userID") {
 		td.IsAuth = true
 	}
 
+	// messages and form state are shown once, so pop them from the session
+	td.Flash = c.Session.PopString(ctx, FlashKey)
+	td.Error = c.Session.PopString(ctx, ErrorKey)
+	td.Warning = c.Session.PopString(ctx, WarningKey)
+
+	if old, ok := c.Session.Pop(ctx, OldInputKey).(map[string]string); ok {
+		td.OldInput = old
+	}
+
+	if errs, ok := c.Session.Pop(ctx, ErrorsKey).(map[string]string); ok {
+		td.Errors = errs
+	}
+
 	return td
 }
 
+// canFunc is the can(action, resource) function of Jet templates. The resource is
+// optional, so that can("posts:edit") works like can("edit", "posts").
+func (n *Render) canFunc(r *http.Request) jet.Func {
+	return func(a jet.Arguments) reflect.Value {
+		a.RequireNumOfArguments("can", 1, 2)
+
+		if n.Can == nil {
+			return reflect.ValueOf(false)
+		}
+
+		action := fmt.Sprint(a.Get(0).Interface())
+
+		var resource interface{}
+		if a.NumOfArguments() == 2 {
+			resource = a.Get(1).Interface()
+		} else {
+			resource, action, _ = strings.Cut(action, ":")
+		}
+
+		return reflect.ValueOf(n.Can(r, action, resource))
+	}
+}
+
+// Page renders view with the configured engine and writes it with status 200.
 func (n *Render) Page(w http.ResponseWriter, r *http.Request, view string, variables, data interface{}) error {
+	return n.PageStatus(w, r, http.StatusOK, view, variables, data)
+}
+
+// PageStatus renders view with the configured engine and writes it with status.
+func (n *Render) PageStatus(w http.ResponseWriter, r *http.Request, status int, view string, variables, data interface{}) error {
 	switch strings.ToLower(n.Renderer) {
 	case "go":
-		return n.GoPage(w, r, view, data)
+		td := n.templateData(r, view, data)
+		return n.write(w, r, status, view, td, func(buf *bytes.Buffer) error {
+			return n.renderGo(buf, view, td)
+		})
 	case "jet":
-		return n.JetPage(w, r, view, variables, data)
-	default:
+		td := n.templateData(r, view, data)
+		return n.write(w, r, status, view, td, func(buf *bytes.Buffer) error {
+			return n.renderJet(buf, r, view, variables, td)
+		})
 	}
 
 	return errors.New("no rendering engine specified")
 }
 
 func (n *Render) GoPage(w http.ResponseWriter, r *http.Request, view string, data interface{}) error {
-	tmpl, err := template.ParseFiles(fmt.Sprintf("%s/views/%s.page.tmpl", n.RootPath, view))
-	if err != nil {
+	td := n.templateData(r, view, data)
+	return n.write(w, r, http.StatusOK, view, td, func(buf *bytes.Buffer) error {
+		return n.renderGo(buf, view, td)
+	})
+}
+
+func (n *Render) JetPage(w http.ResponseWriter, r *http.Request, templateName string, variables, data interface{}) error {
+	td := n.templateData(r, templateName, data)
+	return n.write(w, r, http.StatusOK, templateName, td, func(buf *bytes.Buffer) error {
+		return n.renderJet(buf, r, templateName, variables, td)
+	})
+}
+
+// String renders view outside of a request, such as for emails. The data is passed to
+// the template as is, without the request dependent defaults of pages.
+func (n *Render) String(view string, data interface{}) (string, error) {
+	return n.renderString(view, data, false)
+}
+
+// Text renders view like String, but without html escaping: Go templates with
+// text/template, and Jet views with JetTextViews.
+func (n *Render) Text(view string, data interface{}) (string, error) {
+	return n.renderString(view, data, true)
+}
+
+func (n *Render) renderString(view string, data interface{}, text bool) (string, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	switch strings.ToLower(n.Renderer) {
+	case "go":
+		parse := n.goTemplate
+		if text {
+			parse = n.textTemplate
+		}
+
+		tmpl, err := parse(view)
+		if err != nil {
+			return "", err
+		}
+		if err := tmpl.Execute(buf, data); err != nil {
+			return "", err
+		}
+	case "jet":
+		views := &n.JetViews
+		if text {
+			if n.JetTextViews == nil {
+				return "", errors.New("render: Text needs JetTextViews to render jet views")
+			}
+			views = n.JetTextViews
+		}
+
+		t, err := views.GetTemplate(fmt.Sprintf("%s.jet", view))
+		if err != nil {
+			return "", err
+		}
+		if err := t.Execute(buf, make(jet.VarMap), data); err != nil {
+			return "", err
+		}
+	default:
+		return "", errors.New("no rendering engine specified")
+	}
+
+	return buf.String(), nil
+}
+
+// write renders into a pooled buffer, and only writes the page once rendering worked,
+// so a template failing halfway shows the error page instead of half a page. The error
+// page gets td, as its messages have already been popped from the session.
+func (n *Render) write(w http.ResponseWriter, r *http.Request, status int, view string, td *TemplateData, render func(buf *bytes.Buffer) error) error {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if err := render(buf); err != nil {
+		n.renderError(w, r, view, td, err)
 		return err
 	}
 
-	td := &TemplateData{}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+	w.WriteHeader(status)
+
+	_, err := buf.WriteTo(w)
+	return err
+}
 
+// templateData fills in the defaults of the data of a page, popping its messages and
+// form state from the session, and runs the composers of view.
+func (n *Render) templateData(r *http.Request, view string, data interface{}) *TemplateData {
+	td := &TemplateData{}
 	if data != nil {
 		td = data.(*TemplateData)
 	}
 
-	err = tmpl.Execute(w, &td)
+	td = n.defaultData(td, r)
+	n.compose(r, view, td)
 
-	if data != nil {
+	return td
+}
+
+func (n *Render) renderGo(buf *bytes.Buffer, view string, td *TemplateData) error {
+	tmpl, err := n.goTemplate(view)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	return tmpl.Execute(buf, td)
 }
-func (n *Render) JetPage(w http.ResponseWriter, r *http.Request, templateName string, variables, data interface{}) error {
+
+func (n *Render) renderJet(buf *bytes.Buffer, r *http.Request, templateName string, variables interface{}, td *TemplateData) error {
 	var vars jet.VarMap
 
 	if variables == nil {
@@ -88,24 +293,48 @@ func (n *Render) JetPage(w http.ResponseWriter, r *http.Request, templateName st
 		vars = variables.(jet.VarMap)
 	}
 
-	td := &TemplateData{}
-	if data != nil {
-		td = data.(*TemplateData)
+	if _, ok := vars["can"]; !ok {
+		vars.SetFunc("can", n.canFunc(r))
 	}
 
-	td = n.defaultData(td, r)
-
 	t, err := n.JetViews.GetTemplate(fmt.Sprintf("%s.jet", templateName))
-
 	if err != nil {
-		log.Println(err)
 		return err
 	}
 
-	if err = t.Execute(w, vars, td); err != nil {
-		log.Println(err)
-		return err
+	return t.Execute(buf, vars, td)
+}
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// putBuffer returns buf to the pool, unless a huge page made it too big to keep around.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > 1<<20 {
+		return
+	}
+
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// Exists reports whether view can be rendered by the configured engine.
+func (n *Render) Exists(view string) bool {
+	switch strings.ToLower(n.Renderer) {
+	case "go":
+		_, err := fs.Stat(n.views(), fsPath(view+pageSuffix))
+		return err == nil
+	case "jet":
+		_, err := n.JetViews.GetTemplate(fmt.Sprintf("%s.jet", view))
+		return err == nil
 	}
 
-	return nil
+	return false
 }
@@ -1,9 +1,14 @@
 package render
 
 import (
+	"html/template"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 var pageData = []struct {
@@ -27,6 +32,7 @@ func TestRender_Page(t *testing.T) {
 			t.Error(err)
 		}
 
+		r = loadSession(t, r)
 		w := httptest.NewRecorder()
 
 		testRenderer.Renderer = e.renderer
@@ -44,3 +50,154 @@ func TestRender_Page(t *testing.T) {
 		}
 	}
 }
+
+func loadSession(t *testing.T, r *http.Request) *http.Request {
+	ctx, err := testRenderer.Session.Load(r.Context(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r.WithContext(ctx)
+}
+
+func TestRender_DefaultData(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/some-url", nil)
+	r = loadSession(t, r)
+	ctx := r.Context()
+
+	testRenderer.Session.Put(ctx, FlashKey, "saved")
+	testRenderer.Session.Put(ctx, ErrorKey, "failed")
+	testRenderer.Session.Put(ctx, OldInputKey, map[string]string{"email": "me@here.com"})
+	testRenderer.Session.Put(ctx, ErrorsKey, map[string]string{"name": "This field cannot be blank"})
+
+	td := testRenderer.defaultData(&TemplateData{}, r)
+
+	if td.Flash != "saved" || td.Error != "failed" || td.Warning != "" {
+		t.Error("messages not copied from the session")
+	}
+
+	if td.Old("email") != "me@here.com" {
+		t.Error("old input not copied from the session")
+	}
+
+	if !td.HasError("name") || td.HasError("email") || td.ErrorFor("name") == "" {
+		t.Error("validation errors not copied from the session")
+	}
+
+	td = testRenderer.defaultData(&TemplateData{}, r)
+	if td.Flash != "" || td.OldInput != nil || td.Errors != nil {
+		t.Error("messages shown twice, and they should be popped from the session")
+	}
+}
+
+func TestRender_JetCan(t *testing.T) {
+	r := loadSession(t, httptest.NewRequest("GET", "/", nil))
+
+	testRenderer.Renderer = "jet"
+	defer func() { testRenderer.Can = nil }()
+
+	// without Can everything is denied
+	w := httptest.NewRecorder()
+	if err := testRenderer.Page(w, r, "can", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if body := strings.TrimSpace(w.Body.String()); body != "" {
+		t.Errorf("expected nothing to be allowed, got %q", body)
+	}
+
+	testRenderer.Can = func(r *http.Request, action string, resource interface{}) bool {
+		return resource == "posts" && action == "edit"
+	}
+
+	w = httptest.NewRecorder()
+	if err := testRenderer.Page(w, r, "can", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if body := strings.TrimSpace(w.Body.String()); body != "edit" {
+		t.Errorf("expected only edit to be allowed, got %q", body)
+	}
+}
+
+func TestRender_GoPage_Layout(t *testing.T) {
+	r := loadSession(t, httptest.NewRequest("GET", "/", nil))
+
+	testRenderer.Renderer = "go"
+	testRenderer.RootPath = "./testdata"
+
+	w := httptest.NewRecorder()
+	if err := testRenderer.Page(w, r, "layout", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if body := strings.TrimSpace(w.Body.String()); body != "<main>page</main><footer>FOOTER</footer>" {
+		t.Errorf("layout and partial not applied, got %q", body)
+	}
+}
+
+func TestRender_GoPage_Cache(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "views"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	page := filepath.Join(dir, "views", "cached.page.tmpl")
+
+	render := func(rnd *Render) string {
+		r := loadSession(t, httptest.NewRequest("GET", "/", nil))
+		w := httptest.NewRecorder()
+		if err := rnd.GoPage(w, r, "cached", nil); err != nil {
+			t.Fatal(err)
+		}
+		return w.Body.String()
+	}
+
+	for _, debug := range []bool{false, true} {
+		_ = os.WriteFile(page, []byte("before"), 0644)
+		rnd := &Render{RootPath: dir, Session: testRenderer.Session, Debug: debug}
+
+		if got := render(rnd); got != "before" {
+			t.Fatalf("expected before, got %q", got)
+		}
+
+		_ = os.WriteFile(page, []byte("after"), 0644)
+		later := time.Now().Add(time.Second)
+		_ = os.Chtimes(page, later, later)
+
+		want := "before"
+		if debug {
+			want = "after"
+		}
+		if got := render(rnd); got != want {
+			t.Errorf("debug %v: expected %q, got %q", debug, want, got)
+		}
+	}
+}
+
+func TestRender_CreateTemplateCache(t *testing.T) {
+	rnd := &Render{RootPath: "./testdata", JetViews: *views, FuncMap: template.FuncMap{"shout": strings.ToUpper}}
+	rnd.AddDefaultFuncs()
+	if err := rnd.CreateTemplateCache(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, view := range []string{"home", "layout"} {
+		if _, ok := rnd.templates[view]; !ok {
+			t.Errorf("expected %s to be cached", view)
+		}
+	}
+}
+
+func TestRender_Exists(t *testing.T) {
+	testRenderer.RootPath = "./testdata"
+
+	for _, engine := range []string{"go", "jet"} {
+		testRenderer.Renderer = engine
+
+		if !testRenderer.Exists("home") || !testRenderer.Exists("errors/500") {
+			t.Errorf("%s: expected views to exist", engine)
+		}
+
+		if testRenderer.Exists("errors/404") {
+			t.Errorf("%s: expected errors/404 not to exist", engine)
+		}
+	}
+}
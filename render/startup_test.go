@@ -1,10 +1,13 @@
 package render
 
 import (
+	"html/template"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/CloudyKit/jet/v6"
+	"github.com/alexedwards/scs/v2"
 )
 
 var views = jet.NewSet(
@@ -16,6 +19,13 @@ var testRenderer = Render{
 	Renderer: "",
 	RootPath: "",
 	JetViews: *views,
+	JetTextViews: jet.NewSet(
+		jet.NewOSFileSystemLoader("./testdata/views"),
+		jet.InDevelopmentMode(),
+		jet.WithSafeWriter(nil),
+	),
+	Session: *scs.New(),
+	FuncMap: template.FuncMap{"shout": strings.ToUpper},
 }
 
 func TestMain(m *testing.M) {
@@ -0,0 +1,213 @@
+package napoleon
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// ReadOption changes how ReadJSON reads a request body.
+type ReadOption func(*readOptions)
+
+type readOptions struct {
+	maxBytes      int64
+	disallowExtra bool
+	requireJSON   bool
+	allowGzip     bool
+}
+
+// MaxBytes limits bodies to n bytes, one megabyte by default. Gzipped bodies are
+// limited both before and after decompressing.
+func MaxBytes(n int64) ReadOption {
+	return func(o *readOptions) {
+		o.maxBytes = n
+	}
+}
+
+// DisallowUnknownFields refuses bodies with fields the destination does not have.
+func DisallowUnknownFields() ReadOption {
+	return func(o *readOptions) {
+		o.disallowExtra = true
+	}
+}
+
+// RequireJSON refuses bodies that are not sent as application/json, or a +json type.
+func RequireJSON() ReadOption {
+	return func(o *readOptions) {
+		o.requireJSON = true
+	}
+}
+
+// AllowGzip accepts bodies sent with Content-Encoding: gzip; without it they get 415.
+func AllowGzip() ReadOption {
+	return func(o *readOptions) {
+		o.allowGzip = true
+	}
+}
+
+// ReadJSON decodes the single json value of the request body into data. Bodies that
+// cannot be read are answered by a *Problem, with the field at fault in its Errors where
+// there is one, so that WriteProblem, or a Handle handler returning it, tells the client
+// what is wrong. Bodies with a Content-Encoding other than identity get 415 Unsupported
+// Media Type, gzipped ones too unless AllowGzip is given.
+func (c *Napoleon) ReadJSON(w http.ResponseWriter, r *http.Request, data interface{}, opts ...ReadOption) error {
+	o := readOptions{maxBytes: 1048576} // one megabyte
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.requireJSON && !isJSON(r.Header.Get("Content-Type")) {
+		p := NewProblem(http.StatusUnsupportedMediaType, "Content-Type must be application/json.")
+		p.Code = "unsupported_media_type"
+		return p
+	}
+
+	body, err := requestBody(w, r, o)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	return decodeJSON(body, data, o)
+}
+
+// requestBody returns the body of r limited to o.maxBytes, decompressed when it is
+// gzipped and o allows it.
+func requestBody(w http.ResponseWriter, r *http.Request, o readOptions) (io.ReadCloser, error) {
+	body := http.MaxBytesReader(w, r.Body, o.maxBytes)
+
+	switch encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); {
+	case encoding == "" || encoding == "identity":
+		return body, nil
+
+	case encoding == "gzip" && o.allowGzip:
+		gz, err := gzip.NewReader(body)
+		if errors.Is(err, io.EOF) {
+			return body, nil
+		}
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			return nil, readProblem(err, o)
+		}
+		if err != nil {
+			p := NewProblem(http.StatusBadRequest, "Body is not valid gzip.")
+			p.Code = "invalid_encoding"
+			p.Err = err
+			return nil, p
+		}
+		return http.MaxBytesReader(w, gz, o.maxBytes), nil
+
+	default:
+		p := NewProblem(http.StatusUnsupportedMediaType, fmt.Sprintf("Content-Encoding %s is not supported.", encoding))
+		p.Code = "unsupported_encoding"
+		return nil, p
+	}
+}
+
+func decodeJSON(body io.Reader, data interface{}, o readOptions) error {
+	dec := json.NewDecoder(body)
+	if o.disallowExtra {
+		dec.DisallowUnknownFields()
+	}
+
+	if err := dec.Decode(data); err != nil {
+		return readProblem(err, o)
+	}
+
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		p := NewProblem(http.StatusBadRequest, "Body must only contain a single JSON value.")
+		p.Code = "invalid_json"
+		return p
+	}
+
+	return nil
+}
+
+// readProblem translates the errors of reading and decoding a json body into problems.
+func readProblem(err error, o readOptions) error {
+	var syntaxError *json.SyntaxError
+	var typeError *json.UnmarshalTypeError
+	var maxBytesError *http.MaxBytesError
+	var invalidUnmarshal *json.InvalidUnmarshalError
+
+	p := NewProblem(http.StatusBadRequest, "")
+	p.Code = "invalid_json"
+	p.Err = err
+
+	switch {
+	case errors.As(err, &invalidUnmarshal):
+		// the handler passed something json cannot decode into
+		return err
+
+	case errors.As(err, &maxBytesError):
+		p = NewProblem(http.StatusRequestEntityTooLarge, fmt.Sprintf("Body must not be larger than %d bytes.", o.maxBytes))
+		p.Code = "body_too_large"
+		p.Err = err
+
+	case errors.As(err, &syntaxError):
+		p.Detail = fmt.Sprintf("Body contains badly-formed JSON (at character %d).", syntaxError.Offset)
+
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		p.Detail = "Body contains badly-formed JSON."
+
+	case errors.Is(err, gzip.ErrChecksum):
+		p.Detail = "Body is not valid gzip."
+		p.Code = "invalid_encoding"
+
+	case errors.As(err, &typeError):
+		if typeError.Field == "" {
+			p.Detail = fmt.Sprintf("Body must be a JSON %s.", jsonKind(typeError.Type.Kind()))
+			break
+		}
+		p.Detail = fmt.Sprintf("Body contains the wrong JSON type for field %q.", typeError.Field)
+		p.Errors = map[string]string{typeError.Field: fmt.Sprintf("Must be a %s", jsonKind(typeError.Type.Kind()))}
+
+	case errors.Is(err, io.EOF):
+		p.Detail = "Body must not be empty."
+
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no type for this error
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		p.Detail = fmt.Sprintf("Body contains unknown field %q.", field)
+		p.Errors = map[string]string{field: "Unknown field"}
+
+	default:
+		return err
+	}
+
+	return p
+}
+
+// jsonKind names a Go kind the way a client sending json thinks of it.
+func jsonKind(kind reflect.Kind) string {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+
+	return kind.String()
+}
+
+// isJSON reports whether contentType is application/json or a +json type.
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == MediaJSON || strings.HasSuffix(mediaType, "+json")
+}
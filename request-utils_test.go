@@ -0,0 +1,121 @@
+package napoleon
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testPayload struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func gzipped(t *testing.T, s string) string {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.String()
+}
+
+func TestReadJSON(t *testing.T) {
+	bomb := `{"name":"` + strings.Repeat("a", 100000) + `"}`
+
+	var tests = []struct {
+		name        string
+		body        string
+		contentType string
+		encoding    string
+		opts        []ReadOption
+		status      int
+		detail      string
+		errors      map[string]string
+	}{
+		{"valid", `{"name":"Jack","age":30}`, MediaJSON, "", nil, 0, "", nil},
+		{"unknown_field_allowed", `{"name":"Jack","extra":1}`, MediaJSON, "", nil, 0, "", nil},
+		{"malformed", `{"name" "Jack"}`, MediaJSON, "", nil, http.StatusBadRequest, "Body contains badly-formed JSON (at character 9).", nil},
+		{"truncated", `{"name":`, MediaJSON, "", nil, http.StatusBadRequest, "Body contains badly-formed JSON.", nil},
+		{"wrong_type", `{"age":"thirty"}`, MediaJSON, "", nil, http.StatusBadRequest,
+			`Body contains the wrong JSON type for field "age".`, map[string]string{"age": "Must be a number"}},
+		{"wrong_body_type", `[1, 2]`, MediaJSON, "", nil, http.StatusBadRequest, "Body must be a JSON object.", nil},
+		{"unknown_field", `{"name":"Jack","extra":1}`, MediaJSON, "", []ReadOption{DisallowUnknownFields()}, http.StatusBadRequest,
+			`Body contains unknown field "extra".`, map[string]string{"extra": "Unknown field"}},
+		{"empty", ``, MediaJSON, "", nil, http.StatusBadRequest, "Body must not be empty.", nil},
+		{"trailing_data", `{"name":"Jack"}{"name":"Jill"}`, MediaJSON, "", nil, http.StatusBadRequest, "Body must only contain a single JSON value.", nil},
+		{"too_large", `{"name":"Jack"}`, MediaJSON, "", []ReadOption{MaxBytes(8)}, http.StatusRequestEntityTooLarge, "Body must not be larger than 8 bytes.", nil},
+		{"require_json", `{"name":"Jack"}`, "text/plain", "", []ReadOption{RequireJSON()}, http.StatusUnsupportedMediaType, "Content-Type must be application/json.", nil},
+		{"require_json_suffix", `{"name":"Jack"}`, "application/vnd.api+json", "", []ReadOption{RequireJSON()}, 0, "", nil},
+		{"identity", `{"name":"Jack"}`, MediaJSON, "identity", nil, 0, "", nil},
+		{"gzip_not_allowed", gzipped(t, `{"name":"Jack"}`), MediaJSON, "gzip", nil, http.StatusUnsupportedMediaType, "Content-Encoding gzip is not supported.", nil},
+		{"unknown_encoding", `{"name":"Jack"}`, MediaJSON, "br", []ReadOption{AllowGzip()}, http.StatusUnsupportedMediaType, "Content-Encoding br is not supported.", nil},
+		{"gzip", gzipped(t, `{"name":"Jack"}`), MediaJSON, "gzip", []ReadOption{AllowGzip()}, 0, "", nil},
+		{"gzip_invalid", `{"name":"Jack"}`, MediaJSON, "gzip", []ReadOption{AllowGzip()}, http.StatusBadRequest, "Body is not valid gzip.", nil},
+		{"gzip_bomb", gzipped(t, bomb), MediaJSON, "gzip", []ReadOption{AllowGzip(), MaxBytes(1000)}, http.StatusRequestEntityTooLarge, "Body must not be larger than 1000 bytes.", nil},
+	}
+
+	for _, e := range tests {
+		if e.name == "gzip_bomb" && len(e.body) >= 1000 {
+			t.Fatalf("%s: compressed body of %d bytes is not under the limit", e.name, len(e.body))
+		}
+
+		r := httptest.NewRequest("POST", "/api/users", strings.NewReader(e.body))
+		r.Header.Set("Content-Type", e.contentType)
+		if e.encoding != "" {
+			r.Header.Set("Content-Encoding", e.encoding)
+		}
+		w := httptest.NewRecorder()
+
+		var payload testPayload
+		err := testApp.ReadJSON(w, r, &payload, e.opts...)
+
+		if e.status == 0 {
+			if err != nil {
+				t.Errorf("%s: %v", e.name, err)
+			} else if payload.Name != "Jack" {
+				t.Errorf("%s: expected name Jack, got %q", e.name, payload.Name)
+			}
+			continue
+		}
+
+		var p *Problem
+		if !errors.As(err, &p) {
+			t.Errorf("%s: expected a problem, got %v", e.name, err)
+			continue
+		}
+
+		if p.Status != e.status || p.Detail != e.detail {
+			t.Errorf("%s: expected %d %q, got %d %q", e.name, e.status, e.detail, p.Status, p.Detail)
+		}
+		if len(p.Errors) != len(e.errors) {
+			t.Errorf("%s: expected errors %v, got %v", e.name, e.errors, p.Errors)
+		}
+		for field, message := range e.errors {
+			if p.Errors[field] != message {
+				t.Errorf("%s: expected %q for %s, got %q", e.name, message, field, p.Errors[field])
+			}
+		}
+	}
+}
+
+func TestReadJSON_InvalidDestination(t *testing.T) {
+	r := httptest.NewRequest("POST", "/api/users", strings.NewReader(`{"name":"Jack"}`))
+	w := httptest.NewRecorder()
+
+	// a handler bug, not the fault of the client
+	err := testApp.ReadJSON(w, r, testPayload{})
+
+	var p *Problem
+	if err == nil || errors.As(err, &p) {
+		t.Error("expected a plain error for a destination that is not a pointer, got", err)
+	}
+}
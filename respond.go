@@ -0,0 +1,344 @@
+package napoleon
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hilsonxhero/napoleon/render"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Media types Respond can write.
+const (
+	MediaJSON    = "application/json"
+	MediaXML     = "application/xml"
+	MediaHTML    = "text/html"
+	MediaCSV     = "text/csv"
+	MediaMsgPack = "application/msgpack"
+)
+
+// ErrNotAcceptable is returned by Respond when the client accepts none of the formats
+// it can write; the client gets a 406 listing them.
+var ErrNotAcceptable = errors.New("none of the acceptable formats can be written")
+
+// CSVMarshaler is implemented by values that know how to be written as csv rows.
+type CSVMarshaler interface {
+	MarshalCSV() ([][]string, error)
+}
+
+// RespondOption changes how Respond writes a response.
+type RespondOption func(*respondOptions)
+
+type respondOptions struct {
+	view      string
+	variables interface{}
+	pretty    bool
+	headers   http.Header
+	filename  string
+}
+
+// View offers html, rendered from the named view. The data is available to the view as
+// .Data["data"], unless it is a *render.TemplateData already.
+func View(name string, variables ...interface{}) RespondOption {
+	return func(o *respondOptions) {
+		o.view = name
+		if len(variables) > 0 {
+			o.variables = variables[0]
+		}
+	}
+}
+
+// Pretty indents json and xml; by default they are only indented in Debug mode.
+func Pretty(pretty bool) RespondOption {
+	return func(o *respondOptions) {
+		o.pretty = pretty
+	}
+}
+
+// Headers adds headers to the response.
+func Headers(headers http.Header) RespondOption {
+	return func(o *respondOptions) {
+		o.headers = headers
+	}
+}
+
+// Filename has browsers download csv responses as name.
+func Filename(name string) RespondOption {
+	return func(o *respondOptions) {
+		o.filename = name
+	}
+}
+
+// Respond writes data with status in the format the Accept header of r prefers: json,
+// xml, csv, MessagePack, or html when a View is given. Without an Accept header it
+// writes json, and when nothing acceptable can be written it answers 406 and returns
+// ErrNotAcceptable.
+func (n *Napoleon) Respond(w http.ResponseWriter, r *http.Request, status int, data interface{}, opts ...RespondOption) error {
+	o := respondOptions{pretty: n.Debug}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	offers := []string{MediaJSON, MediaXML, MediaCSV, MediaMsgPack}
+	if o.view != "" {
+		// browsers accept anything, but should get the page
+		offers = append([]string{MediaHTML}, offers...)
+	}
+
+	for key, value := range o.headers {
+		w.Header()[key] = value
+	}
+	w.Header().Add("Vary", "Accept")
+
+	mediaType := negotiate(r.Header.Get("Accept"), offers)
+
+	var body []byte
+	var err error
+
+	switch mediaType {
+	case MediaHTML:
+		td, ok := data.(*render.TemplateData)
+		if !ok {
+			td = &render.TemplateData{Data: map[string]interface{}{"data": data}}
+		}
+		return n.Render.PageStatus(w, r, status, o.view, o.variables, td)
+
+	case MediaJSON:
+		if o.pretty {
+			body, err = json.MarshalIndent(data, "", "\t")
+		} else {
+			body, err = json.Marshal(data)
+		}
+
+	case MediaXML:
+		if o.pretty {
+			body, err = xml.MarshalIndent(data, "", "   ")
+		} else {
+			body, err = xml.Marshal(data)
+		}
+
+	case MediaCSV:
+		body, err = marshalCSV(data)
+		if err == nil && o.filename != "" {
+			w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": o.filename}))
+		}
+
+	case MediaMsgPack:
+		body, err = msgpack.Marshal(data)
+
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusNotAcceptable)
+		_, _ = fmt.Fprintf(w, "%s\navailable: %s\n", http.StatusText(http.StatusNotAcceptable), strings.Join(offers, ", "))
+		return ErrNotAcceptable
+	}
+
+	if err != nil {
+		return err
+	}
+
+	contentType := mediaType
+	if mediaType == MediaJSON || mediaType == MediaCSV {
+		contentType += "; charset=utf-8"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	_, err = w.Write(body)
+
+	return err
+}
+
+// acceptRange is a media range of an Accept header.
+type acceptRange struct {
+	mediaType string
+	q         float64
+	order     int
+}
+
+// negotiate returns the first of offers the accept header prefers, or "" when it
+// accepts none of them. An empty header accepts anything.
+func negotiate(accept string, offers []string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+
+	var ranges []acceptRange
+	for i, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+
+		ranges = append(ranges, acceptRange{mediaType: mediaType, q: q, order: i})
+	}
+
+	// highest quality first; of equal quality, the most specific, then as listed
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return specificity(ranges[i].mediaType) > specificity(ranges[j].mediaType)
+	})
+
+	for _, ar := range ranges {
+		if ar.q <= 0 {
+			break
+		}
+
+		for _, offer := range offers {
+			if matchesRange(ar.mediaType, offer) && !excluded(ranges, offer) {
+				return offer
+			}
+		}
+	}
+
+	return ""
+}
+
+// excluded reports whether the header refuses offer with q=0 for its exact type.
+func excluded(ranges []acceptRange, offer string) bool {
+	for _, ar := range ranges {
+		if ar.mediaType == offer && ar.q <= 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesRange(mediaRange, offer string) bool {
+	if mediaRange == "*/*" || mediaRange == offer {
+		return true
+	}
+
+	if strings.HasSuffix(mediaRange, "/*") {
+		return strings.HasPrefix(offer, strings.TrimSuffix(mediaRange, "*"))
+	}
+
+	// the older names some clients still send
+	switch mediaRange {
+	case "text/xml":
+		return offer == MediaXML
+	case "application/x-msgpack":
+		return offer == MediaMsgPack
+	}
+
+	return false
+}
+
+func specificity(mediaType string) int {
+	switch {
+	case mediaType == "*/*":
+		return 0
+	case strings.HasSuffix(mediaType, "/*"):
+		return 1
+	}
+
+	return 2
+}
+
+// marshalCSV writes [][]string, CSVMarshalers, and slices of structs, whose exported
+// fields become the columns, named by their csv or json tag.
+func marshalCSV(data interface{}) ([]byte, error) {
+	var rows [][]string
+
+	switch d := data.(type) {
+	case [][]string:
+		rows = d
+	case CSVMarshaler:
+		var err error
+		if rows, err = d.MarshalCSV(); err != nil {
+			return nil, err
+		}
+	default:
+		var err error
+		if rows, err = structRows(data); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	if err := cw.WriteAll(rows); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func structRows(data interface{}) ([][]string, error) {
+	v := reflect.Indirect(reflect.ValueOf(data))
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		v = reflect.ValueOf([]interface{}{data})
+	}
+
+	var rows [][]string
+	var fields []int
+
+	for i := 0; i < v.Len(); i++ {
+		item := v.Index(i)
+		for item.Kind() == reflect.Pointer || item.Kind() == reflect.Interface {
+			item = item.Elem()
+		}
+
+		if item.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("cannot write %s as csv", item.Kind())
+		}
+
+		if rows == nil {
+			var header []string
+			for f := 0; f < item.NumField(); f++ {
+				name, ok := csvName(item.Type().Field(f))
+				if ok {
+					header = append(header, name)
+					fields = append(fields, f)
+				}
+			}
+			rows = append(rows, header)
+		}
+
+		row := make([]string, 0, len(fields))
+		for _, f := range fields {
+			row = append(row, fmt.Sprint(item.Field(f).Interface()))
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func csvName(f reflect.StructField) (string, bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+
+	for _, key := range []string{"csv", "json"} {
+		tag := strings.Split(f.Tag.Get(key), ",")[0]
+		if tag == "-" {
+			return "", false
+		}
+		if tag != "" {
+			return tag, true
+		}
+	}
+
+	return f.Name, true
+}
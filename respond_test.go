@@ -0,0 +1,231 @@
+package napoleon
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type testItem struct {
+	Name   string  `json:"name" xml:"name" msgpack:"name"`
+	Price  float64 `json:"price" xml:"price" msgpack:"price" csv:"cost"`
+	Secret string  `json:"-" xml:"-" msgpack:"-"`
+	note   string
+}
+
+// testRows writes itself as csv.
+type testRows struct{}
+
+func (testRows) MarshalCSV() ([][]string, error) {
+	return [][]string{{"a", "b"}, {"1", "2"}}, nil
+}
+
+func TestNegotiate(t *testing.T) {
+	apiOffers := []string{MediaJSON, MediaXML, MediaCSV, MediaMsgPack}
+	pageOffers := append([]string{MediaHTML}, apiOffers...)
+
+	var tests = []struct {
+		name   string
+		accept string
+		offers []string
+		want   string
+	}{
+		{"empty", "", apiOffers, MediaJSON},
+		{"exact", "application/xml", apiOffers, MediaXML},
+		{"anything", "*/*", apiOffers, MediaJSON},
+		{"q_values", "application/json;q=0.5, application/xml", apiOffers, MediaXML},
+		{"q_values_listed_last", "text/csv;q=0.9, application/msgpack", apiOffers, MediaMsgPack},
+		{"wildcard_low_q", "*/*;q=0.1, text/csv", apiOffers, MediaCSV},
+		{"specific_before_wildcard", "text/*, text/csv", pageOffers, MediaCSV},
+		{"subtype_wildcard", "text/*", apiOffers, MediaCSV},
+		{"excluded", "*/*, application/json;q=0", apiOffers, MediaXML},
+		{"only_excluded", "application/json;q=0", apiOffers, ""},
+		{"browser", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", pageOffers, MediaHTML},
+		{"legacy_xml", "text/xml", apiOffers, MediaXML},
+		{"legacy_msgpack", "application/x-msgpack", apiOffers, MediaMsgPack},
+		{"bad_q", "application/json;q=abc, application/xml", apiOffers, MediaXML},
+		{"unparsable", ";;;", apiOffers, ""},
+		{"none", "image/png", apiOffers, ""},
+	}
+
+	for _, e := range tests {
+		if got := negotiate(e.accept, e.offers); got != e.want {
+			t.Errorf("%s: expected %q, got %q", e.name, e.want, got)
+		}
+	}
+}
+
+func TestMarshalCSV(t *testing.T) {
+	var tests = []struct {
+		name          string
+		data          interface{}
+		want          string
+		errorExpected bool
+	}{
+		{"rows", [][]string{{"a", "b"}, {"1", "2,3"}}, "a,b\n1,\"2,3\"\n", false},
+		{"marshaler", testRows{}, "a,b\n1,2\n", false},
+		{"structs", []testItem{{Name: "Pen", Price: 1.5, Secret: "x", note: "y"}, {Name: "Ink", Price: 2}}, "name,cost\nPen,1.5\nInk,2\n", false},
+		{"pointers", []*testItem{{Name: "Pen", Price: 1.5}}, "name,cost\nPen,1.5\n", false},
+		{"interfaces", []interface{}{testItem{Name: "Pen"}}, "name,cost\nPen,0\n", false},
+		{"single_struct", testItem{Name: "Pen", Price: 3}, "name,cost\nPen,3\n", false},
+		{"untagged", []struct{ ID int }{{1}}, "ID\n1\n", false},
+		{"not_structs", []int{1, 2}, "", true},
+		{"scalar", "text", "", true},
+	}
+
+	for _, e := range tests {
+		out, err := marshalCSV(e.data)
+		if e.errorExpected {
+			if err == nil {
+				t.Errorf("%s: expected an error", e.name)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %v", e.name, err)
+			continue
+		}
+
+		if string(out) != e.want {
+			t.Errorf("%s: expected %q, got %q", e.name, e.want, out)
+		}
+	}
+}
+
+func TestRespond(t *testing.T) {
+	item := testItem{Name: "Pen", Price: 1.5}
+
+	var tests = []struct {
+		name        string
+		accept      string
+		opts        []RespondOption
+		contentType string
+		check       func(body []byte) error
+	}{
+		{"json", "", nil, "application/json; charset=utf-8", func(body []byte) error {
+			if string(body) != `{"name":"Pen","price":1.5}` {
+				return errors.New(string(body))
+			}
+			return nil
+		}},
+		{"pretty_json", MediaJSON, []RespondOption{Pretty(true)}, "application/json; charset=utf-8", func(body []byte) error {
+			if !strings.Contains(string(body), "\n\t\"name\": \"Pen\"") {
+				return errors.New(string(body))
+			}
+			return nil
+		}},
+		{"xml", MediaXML, nil, MediaXML, func(body []byte) error {
+			var got testItem
+			if err := xml.Unmarshal(body, &got); err != nil {
+				return err
+			}
+			if got.Name != "Pen" || got.Price != 1.5 {
+				return errors.New(string(body))
+			}
+			return nil
+		}},
+		{"csv", MediaCSV, nil, "text/csv; charset=utf-8", func(body []byte) error {
+			if string(body) != "name,cost\nPen,1.5\n" {
+				return errors.New(string(body))
+			}
+			return nil
+		}},
+		{"msgpack", MediaMsgPack, nil, MediaMsgPack, func(body []byte) error {
+			var got testItem
+			if err := msgpack.Unmarshal(body, &got); err != nil {
+				return err
+			}
+			if got.Name != "Pen" || got.Price != 1.5 {
+				return errors.New("wrong item decoded")
+			}
+			return nil
+		}},
+		{"html", "text/html,*/*;q=0.8", []RespondOption{View("item")}, "text/html; charset=utf-8", func(body []byte) error {
+			if string(body) != "Pen" {
+				return errors.New(string(body))
+			}
+			return nil
+		}},
+		{"json_from_view_route", MediaJSON, []RespondOption{View("item")}, "application/json; charset=utf-8", func(body []byte) error {
+			var got testItem
+			return json.Unmarshal(body, &got)
+		}},
+	}
+
+	for _, e := range tests {
+		r := loadSession(t, httptest.NewRequest("GET", "/items/1", nil))
+		if e.accept != "" {
+			r.Header.Set("Accept", e.accept)
+		}
+		w := httptest.NewRecorder()
+
+		if err := testApp.Respond(w, r, http.StatusCreated, item, e.opts...); err != nil {
+			t.Errorf("%s: %v", e.name, err)
+			continue
+		}
+
+		if w.Code != http.StatusCreated {
+			t.Errorf("%s: expected status 201, got %d", e.name, w.Code)
+		}
+		if got := w.Header().Get("Content-Type"); got != e.contentType {
+			t.Errorf("%s: expected content type %q, got %q", e.name, e.contentType, got)
+		}
+		if w.Header().Get("Vary") != "Accept" {
+			t.Errorf("%s: expected Vary: Accept", e.name)
+		}
+		if err := e.check(w.Body.Bytes()); err != nil {
+			t.Errorf("%s: wrong body: %v", e.name, err)
+		}
+	}
+}
+
+func TestRespond_Options(t *testing.T) {
+	r := httptest.NewRequest("GET", "/items.csv", nil)
+	r.Header.Set("Accept", MediaCSV)
+	w := httptest.NewRecorder()
+
+	err := testApp.Respond(w, r, http.StatusOK, [][]string{{"a"}}, Filename("items.csv"),
+		Headers(http.Header{"Cache-Control": {"no-store"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := w.Header().Get("Content-Disposition"); got != "attachment; filename=items.csv" {
+		t.Errorf("wrong Content-Disposition %q", got)
+	}
+	if w.Header().Get("Cache-Control") != "no-store" {
+		t.Error("headers option not applied")
+	}
+}
+
+func TestRespond_NotAcceptable(t *testing.T) {
+	r := httptest.NewRequest("GET", "/items/1", nil)
+	r.Header.Set("Accept", "image/png")
+	w := httptest.NewRecorder()
+
+	err := testApp.Respond(w, r, http.StatusOK, testItem{Name: "Pen"})
+	if !errors.Is(err, ErrNotAcceptable) {
+		t.Fatal("expected ErrNotAcceptable, got", err)
+	}
+
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("expected status 406, got %d", w.Code)
+	}
+
+	body := w.Body.String()
+	for _, offer := range []string{MediaJSON, MediaXML, MediaCSV, MediaMsgPack} {
+		if !strings.Contains(body, offer) {
+			t.Errorf("406 body does not list %s: %q", offer, body)
+		}
+	}
+	if strings.Contains(body, MediaHTML) {
+		t.Error("406 body lists html without a view")
+	}
+}
@@ -3,34 +3,21 @@ package napoleon
 import (
 	"encoding/json"
 	"encoding/xml"
-	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"path"
 	"path/filepath"
 )
 
-func (c *Napoleon) ReadJSON(w http.ResponseWriter, r *http.Request, data interface{}) error {
-	maxBytes := 1048576 // one megabyte
-	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
-
-	dec := json.NewDecoder(r.Body)
-	err := dec.Decode(data)
-	if err != nil {
-		return err
-	}
-
-	err = dec.Decode(&struct{}{})
-	if err != io.EOF {
-		return errors.New("body must only have a single json value")
-	}
-
-	return nil
-}
-
+// WriteJSON writes data as json, indented in Debug mode only
 func (n *Napoleon) WriteJSON(w http.ResponseWriter, status int, data interface{}, headers ...http.Header) error {
-	out, err := json.MarshalIndent(data, "", "\t")
+	var out []byte
+	var err error
+	if n.Debug {
+		out, err = json.MarshalIndent(data, "", "\t")
+	} else {
+		out, err = json.Marshal(data)
+	}
 	if err != nil {
 		return err
 	}
@@ -51,9 +38,15 @@ func (n *Napoleon) WriteJSON(w http.ResponseWriter, status int, data interface{}
 	return nil
 }
 
-// WriteXML writes xml from arbitrary data
+// WriteXML writes xml from arbitrary data, indented in Debug mode only
 func (c *Napoleon) WriteXML(w http.ResponseWriter, status int, data interface{}, headers ...http.Header) error {
-	out, err := xml.MarshalIndent(data, "", "   ")
+	var out []byte
+	var err error
+	if c.Debug {
+		out, err = xml.MarshalIndent(data, "", "   ")
+	} else {
+		out, err = xml.Marshal(data)
+	}
 	if err != nil {
 		return err
 	}
@@ -84,25 +77,26 @@ func (c *Napoleon) DownloadFile(w http.ResponseWriter, r *http.Request, pathToFi
 
 // Error404 returns page not found response
 func (c *Napoleon) Error404(w http.ResponseWriter, r *http.Request) {
-	c.ErrorStatus(w, http.StatusNotFound)
+	c.WriteProblem(w, r, NewProblem(http.StatusNotFound, ""))
 }
 
 // Error500 returns internal server error response
 func (c *Napoleon) Error500(w http.ResponseWriter, r *http.Request) {
-	c.ErrorStatus(w, http.StatusInternalServerError)
+	c.WriteProblem(w, r, NewProblem(http.StatusInternalServerError, ""))
 }
 
 // ErrorUnauthorized sends an unauthorized status (client is not known)
 func (c *Napoleon) ErrorUnauthorized(w http.ResponseWriter, r *http.Request) {
-	c.ErrorStatus(w, http.StatusUnauthorized)
+	c.WriteProblem(w, r, NewProblem(http.StatusUnauthorized, ""))
 }
 
 // ErrorForbidden returns a forbidden status message (client is known)
 func (c *Napoleon) ErrorForbidden(w http.ResponseWriter, r *http.Request) {
-	c.ErrorStatus(w, http.StatusForbidden)
+	c.WriteProblem(w, r, NewProblem(http.StatusForbidden, ""))
 }
 
-// ErrorStatus returns a response with the supplied http status
+// ErrorStatus returns a plain text response with the supplied http status; with the
+// request at hand, WriteProblem answers API clients and browsers better
 func (c *Napoleon) ErrorStatus(w http.ResponseWriter, status int) {
 	http.Error(w, http.StatusText(status), status)
 }
@@ -18,6 +18,9 @@ func (n *Napoleon) routes() http.Handler {
 
 	mux.Use(middleware.Recoverer)
 	mux.Use(n.SessionLoad)
+	mux.Use(n.RememberMe)
+	mux.Use(n.TrackSession)
+	mux.Use(n.Authorization)
 	mux.Use(n.NoSurf)
 
 	return mux
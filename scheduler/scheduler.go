@@ -0,0 +1,301 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hilsonxhero/napoleon/cache"
+	"github.com/hilsonxhero/napoleon/lock"
+	"github.com/robfig/cron/v3"
+)
+
+// ErrUnknownJob is returned by Run for a name that was never added.
+var ErrUnknownJob = errors.New("scheduler: unknown job")
+
+// ErrSkipped is recorded when a run is skipped because the previous one is still going,
+// or because another server holds the job's lock.
+var ErrSkipped = errors.New("scheduler: run skipped")
+
+// Scheduler runs named jobs on cron schedules. A job never overlaps with itself, and jobs
+// added with OnOneServer only run on the instance that gets the job's lock. The result of
+// the last run is kept in Cache when it is set, so every instance (and the cli) sees it.
+type Scheduler struct {
+	Locker   lock.Locker
+	Cache    cache.Cache
+	ErrorLog *log.Logger
+
+	mu      sync.Mutex
+	cron    *cron.Cron
+	jobs    map[string]*job
+	started bool
+}
+
+// Option configures a job added with Add.
+type Option func(*job)
+
+// OnOneServer makes the job run on a single instance per tick, using the scheduler's
+// Locker. The lock is held until just before the next tick, or for ttl when that is
+// shorter; ttl also bounds how long it is held if the instance dies mid-run.
+func OnOneServer(ttl time.Duration) Option {
+	return func(j *job) {
+		j.oneServer = true
+		j.lockTTL = ttl
+	}
+}
+
+// Status describes a job and the outcome of its last run.
+type Status struct {
+	Name         string        `json:"name"`
+	Spec         string        `json:"spec"`
+	Next         time.Time     `json:"next"`
+	Running      bool          `json:"running"`
+	LastRun      time.Time     `json:"last_run"`
+	LastDuration time.Duration `json:"last_duration"`
+	LastError    string        `json:"last_error"`
+}
+
+type job struct {
+	name      string
+	spec      string
+	fn        func(ctx context.Context) error
+	entryID   cron.EntryID
+	oneServer bool
+	lockTTL   time.Duration
+	running   int32
+
+	mu     sync.Mutex
+	status Status
+}
+
+// Add registers fn to run on the cron spec (five fields, or a descriptor like "@daily").
+func (s *Scheduler) Add(name, spec string, fn func(ctx context.Context) error, opts ...Option) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.init()
+
+	if _, exists := s.jobs[name]; exists {
+		return fmt.Errorf("scheduler: job %q already added", name)
+	}
+
+	j := &job{
+		name:    name,
+		spec:    spec,
+		fn:      fn,
+		lockTTL: time.Hour,
+	}
+	for _, opt := range opts {
+		opt(j)
+	}
+	j.status = Status{Name: name, Spec: spec}
+
+	id, err := s.cron.AddFunc(spec, func() {
+		_ = s.run(context.Background(), j)
+	})
+	if err != nil {
+		return err
+	}
+
+	j.entryID = id
+	s.jobs[name] = j
+
+	return nil
+}
+
+// Start runs the scheduled jobs in the background.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.init()
+
+	if !s.started {
+		s.cron.Start()
+		s.started = true
+	}
+}
+
+// Stop stops scheduling jobs and waits for running ones to finish or ctx to be done.
+func (s *Scheduler) Stop(ctx context.Context) {
+	s.mu.Lock()
+	if !s.started {
+		s.mu.Unlock()
+		return
+	}
+	s.started = false
+	done := s.cron.Stop()
+	s.mu.Unlock()
+
+	select {
+	case <-done.Done():
+	case <-ctx.Done():
+	}
+}
+
+// Run runs the named job now, with the same overlap and lock rules as scheduled runs.
+func (s *Scheduler) Run(ctx context.Context, name string) error {
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	s.mu.Unlock()
+
+	if !ok {
+		return ErrUnknownJob
+	}
+
+	return s.run(ctx, j)
+}
+
+// List returns the status of every job, sorted by name.
+func (s *Scheduler) List() []Status {
+	s.mu.Lock()
+	jobs := make([]*job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	s.mu.Unlock()
+
+	list := make([]Status, 0, len(jobs))
+	for _, j := range jobs {
+		status := s.lastStatus(j)
+		status.Running = atomic.LoadInt32(&j.running) == 1
+		if s.cron != nil {
+			status.Next = j.schedule(s.cron).Next(time.Now())
+		}
+		list = append(list, status)
+	}
+
+	sort.Slice(list, func(a, b int) bool {
+		return list[a].Name < list[b].Name
+	})
+
+	return list
+}
+
+func (s *Scheduler) run(ctx context.Context, j *job) error {
+	if !atomic.CompareAndSwapInt32(&j.running, 0, 1) {
+		return ErrSkipped
+	}
+	defer atomic.StoreInt32(&j.running, 0)
+
+	if j.oneServer && s.Locker != nil {
+		l, err := s.Locker.Acquire(ctx, "schedule:"+j.name, j.lockTTL)
+		if errors.Is(err, lock.ErrNotAcquired) {
+			return ErrSkipped
+		}
+		if err != nil {
+			s.errorLog().Printf("scheduler: could not lock %s: %s", j.name, err)
+			return err
+		}
+		defer s.holdLock(j, l, time.Now())
+	}
+
+	start := time.Now()
+	err := s.call(ctx, j)
+
+	status := Status{
+		Name:         j.name,
+		Spec:         j.spec,
+		LastRun:      start,
+		LastDuration: time.Since(start),
+	}
+	if err != nil {
+		status.LastError = err.Error()
+		s.errorLog().Printf("scheduler: %s failed: %s", j.name, err)
+	}
+	s.record(j, status)
+
+	return err
+}
+
+// holdLock keeps the lock of a run that started at start until a second before the next
+// tick, so that servers whose clocks are behind do not run the job again for the same
+// tick. A lock whose ttl ends sooner is left to expire.
+func (s *Scheduler) holdLock(j *job, l *lock.Lock, start time.Time) {
+	ctx := context.Background()
+	hold := time.Until(j.schedule(s.cron).Next(start)) - time.Second
+
+	var err error
+	switch {
+	case hold <= 0:
+		// the run took until the next tick, which is due now
+		err = s.Locker.Release(ctx, l)
+	case hold < time.Until(l.Expires):
+		err = s.Locker.Extend(ctx, l, hold)
+	}
+
+	if err != nil && !errors.Is(err, lock.ErrNotHeld) {
+		s.errorLog().Printf("scheduler: could not keep the lock of %s: %s", j.name, err)
+	}
+}
+
+func (s *Scheduler) call(ctx context.Context, j *job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return j.fn(ctx)
+}
+
+func (s *Scheduler) record(j *job, status Status) {
+	j.mu.Lock()
+	j.status = status
+	j.mu.Unlock()
+
+	if s.Cache != nil {
+		b, err := json.Marshal(status)
+		if err == nil {
+			err = s.Cache.Set("schedule:"+j.name, string(b))
+		}
+		if err != nil {
+			s.errorLog().Printf("scheduler: could not record status of %s: %s", j.name, err)
+		}
+	}
+}
+
+// lastStatus prefers the status in the cache, which may come from another instance.
+func (s *Scheduler) lastStatus(j *job) Status {
+	j.mu.Lock()
+	status := j.status
+	j.mu.Unlock()
+
+	if s.Cache != nil {
+		if v, err := s.Cache.Get("schedule:" + j.name); err == nil {
+			if str, ok := v.(string); ok {
+				var cached Status
+				if json.Unmarshal([]byte(str), &cached) == nil && cached.LastRun.After(status.LastRun) {
+					status = cached
+				}
+			}
+		}
+	}
+
+	return status
+}
+
+func (j *job) schedule(c *cron.Cron) cron.Schedule {
+	return c.Entry(j.entryID).Schedule
+}
+
+func (s *Scheduler) init() {
+	if s.cron == nil {
+		s.cron = cron.New()
+		s.jobs = make(map[string]*job)
+	}
+}
+
+func (s *Scheduler) errorLog() *log.Logger {
+	if s.ErrorLog == nil {
+		return log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime)
+	}
+	return s.ErrorLog
+}
@@ -0,0 +1,178 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hilsonxhero/napoleon/lock"
+)
+
+func TestScheduler_Add(t *testing.T) {
+	s := &Scheduler{}
+
+	noop := func(ctx context.Context) error { return nil }
+
+	err := s.Add("nightly", "@daily", noop)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = s.Add("nightly", "@daily", noop)
+	if err == nil {
+		t.Error("no error adding the same job twice")
+	}
+
+	err = s.Add("broken", "not a spec", noop)
+	if err == nil {
+		t.Error("no error adding a job with an invalid spec")
+	}
+
+	list := s.List()
+	if len(list) != 1 || list[0].Name != "nightly" {
+		t.Fatalf("unexpected job list %v", list)
+	}
+
+	if list[0].Next.IsZero() {
+		t.Error("next run time not reported")
+	}
+}
+
+func TestScheduler_Run(t *testing.T) {
+	s := &Scheduler{}
+
+	_ = s.Add("ok", "@hourly", func(ctx context.Context) error {
+		time.Sleep(time.Millisecond)
+		return nil
+	})
+	_ = s.Add("fails", "@hourly", func(ctx context.Context) error {
+		return errors.New("boom")
+	})
+
+	if err := s.Run(context.Background(), "ok"); err != nil {
+		t.Error(err)
+	}
+
+	if err := s.Run(context.Background(), "fails"); err == nil {
+		t.Error("expected error from failing job")
+	}
+
+	if err := s.Run(context.Background(), "missing"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("expected ErrUnknownJob, got %v", err)
+	}
+
+	for _, status := range s.List() {
+		if status.LastRun.IsZero() {
+			t.Errorf("%s: last run not recorded", status.Name)
+		}
+
+		switch status.Name {
+		case "ok":
+			if status.LastError != "" || status.LastDuration < time.Millisecond {
+				t.Errorf("ok: unexpected status %+v", status)
+			}
+		case "fails":
+			if status.LastError != "boom" {
+				t.Errorf("fails: error not recorded, got %q", status.LastError)
+			}
+		}
+	}
+}
+
+func TestScheduler_NoOverlap(t *testing.T) {
+	s := &Scheduler{}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	_ = s.Add("slow", "@hourly", func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	})
+
+	done := make(chan error)
+	go func() {
+		done <- s.Run(context.Background(), "slow")
+	}()
+
+	<-started
+	if err := s.Run(context.Background(), "slow"); !errors.Is(err, ErrSkipped) {
+		t.Errorf("expected overlapping run to be skipped, got %v", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestScheduler_OnOneServer(t *testing.T) {
+	locker := &lock.MemoryLocker{}
+	s := &Scheduler{Locker: locker}
+
+	ran := false
+	_ = s.Add("report", "@daily", func(ctx context.Context) error {
+		ran = true
+		return nil
+	}, OnOneServer(time.Minute))
+
+	// another instance is running the job
+	l, err := locker.Acquire(context.Background(), "schedule:report", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Run(context.Background(), "report"); !errors.Is(err, ErrSkipped) {
+		t.Errorf("expected run to be skipped while locked, got %v", err)
+	}
+
+	_ = locker.Release(context.Background(), l)
+
+	if err := s.Run(context.Background(), "report"); err != nil {
+		t.Error(err)
+	}
+
+	if !ran {
+		t.Error("job did not run once the lock was free")
+	}
+
+	// a server whose clock is behind fires the same tick after the run has finished
+	if err := s.Run(context.Background(), "report"); !errors.Is(err, ErrSkipped) {
+		t.Errorf("expected the lock to be kept after the run, got %v", err)
+	}
+}
+
+// extendLocker records the ttl locks are extended by.
+type extendLocker struct {
+	*lock.MemoryLocker
+	ttl time.Duration
+}
+
+func (l *extendLocker) Extend(ctx context.Context, lk *lock.Lock, ttl time.Duration) error {
+	l.ttl = ttl
+	return l.MemoryLocker.Extend(ctx, lk, ttl)
+}
+
+func TestScheduler_OnOneServerTickWindow(t *testing.T) {
+	locker := &extendLocker{MemoryLocker: &lock.MemoryLocker{}}
+	s := &Scheduler{Locker: locker}
+
+	_ = s.Add("sync", "@every 5s", func(ctx context.Context) error {
+		return nil
+	}, OnOneServer(time.Hour))
+
+	if err := s.Run(context.Background(), "sync"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the lock must be free again for the next tick
+	if locker.ttl <= 0 || locker.ttl >= 5*time.Second {
+		t.Errorf("expected the lock to be kept until before the next tick, got %s", locker.ttl)
+	}
+
+	if _, err := locker.Acquire(context.Background(), "schedule:sync", time.Minute); !errors.Is(err, lock.ErrNotAcquired) {
+		t.Errorf("expected the lock to be held, got %v", err)
+	}
+}
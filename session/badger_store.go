@@ -0,0 +1,88 @@
+package session
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// BadgerStore is an scs store backed by badger. Sessions expire through the entry TTL, so
+// no cleanup job is needed.
+type BadgerStore struct {
+	Conn   *badger.DB
+	Prefix string
+}
+
+// NewBadgerStore returns a store that keeps sessions in db under the "scs:session:" prefix.
+func NewBadgerStore(db *badger.DB) *BadgerStore {
+	return &BadgerStore{
+		Conn:   db,
+		Prefix: "scs:session:",
+	}
+}
+
+func (b *BadgerStore) Find(token string) ([]byte, bool, error) {
+	var data []byte
+
+	err := b.Conn.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(b.Prefix + token))
+		if err != nil {
+			return err
+		}
+
+		data, err = item.ValueCopy(nil)
+		return err
+	})
+
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return data, true, nil
+}
+
+func (b *BadgerStore) Commit(token string, data []byte, expiry time.Time) error {
+	return b.Conn.Update(func(txn *badger.Txn) error {
+		e := badger.NewEntry([]byte(b.Prefix+token), data).WithTTL(time.Until(expiry))
+		return txn.SetEntry(e)
+	})
+}
+
+func (b *BadgerStore) Delete(token string) error {
+	return b.Conn.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(b.Prefix + token))
+	})
+}
+
+// All returns every unexpired session, keyed by token, for scs.IterableStore.
+func (b *BadgerStore) All() (map[string][]byte, error) {
+	sessions := make(map[string][]byte)
+
+	err := b.Conn.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte(b.Prefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+
+			data, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			sessions[string(item.Key()[len(prefix):])] = data
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
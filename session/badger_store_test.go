@@ -0,0 +1,82 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+func TestBadgerStore_Commit(t *testing.T) {
+	store := NewBadgerStore(testBadgerConn)
+
+	err := store.Commit("alpha", []byte("beta"), time.Now().Add(time.Minute))
+	if err != nil {
+		t.Error(err)
+	}
+
+	data, found, err := store.Find("alpha")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !found || string(data) != "beta" {
+		t.Error("committed session not found")
+	}
+
+	err = store.Delete("alpha")
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, found, _ = store.Find("alpha")
+	if found {
+		t.Error("deleted session found, and it shouldn't be there")
+	}
+}
+
+func TestBadgerStore_Expiry(t *testing.T) {
+	store := NewBadgerStore(testBadgerConn)
+
+	_ = store.Commit("short", []byte("lived"), time.Now().Add(time.Second))
+	time.Sleep(1100 * time.Millisecond)
+
+	_, found, _ := store.Find("short")
+	if found {
+		t.Error("expired session found, and it shouldn't be there")
+	}
+}
+
+func TestBadgerStore_All(t *testing.T) {
+	store := &BadgerStore{Conn: testBadgerConn, Prefix: "all:"}
+
+	_ = store.Commit("one", []byte("1"), time.Now().Add(time.Minute))
+	_ = store.Commit("two", []byte("2"), time.Now().Add(time.Minute))
+
+	var _ scs.IterableStore = store
+
+	all, err := store.All()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(all) != 2 || string(all["one"]) != "1" || string(all["two"]) != "2" {
+		t.Error("wrong sessions returned:", all)
+	}
+}
+
+func TestSession_InitBadgerSession(t *testing.T) {
+	c := &Session{
+		SessionType: "badger",
+		BadgerConn:  testBadgerConn,
+	}
+
+	ses, err := c.InitSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := ses.Store.(*BadgerStore); !ok {
+		t.Errorf("expected a badger store, got %T", ses.Store)
+	}
+}
@@ -0,0 +1,211 @@
+package session
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// maxCookieSize is the most browsers are guaranteed to store for a single cookie.
+const maxCookieSize = 4096
+
+// ErrCookieTooLarge is returned when the encrypted session data does not fit in a cookie.
+var ErrCookieTooLarge = errors.New("session: data too large for a cookie session")
+
+// Encrypter encrypts the data of cookie sessions; napoleon.Encryption implements it.
+type Encrypter interface {
+	Encrypt(text string) (string, error)
+	Decrypt(cryptoText string) (string, error)
+}
+
+// CookieStore is an scs store that keeps the session data in the session cookie itself,
+// encrypted with Encrypter and signed with an HMAC derived from Key, so nothing is stored
+// on the server. Because the cookie value changes whenever the data does, requests must
+// go through the store's LoadAndSave rather than the session manager's.
+type CookieStore struct {
+	Encrypter Encrypter
+	Key       []byte
+}
+
+type cookieContextKey struct{}
+
+// cookieValue carries the new cookie value from CommitCtx back to the middleware.
+type cookieValue struct {
+	value string
+}
+
+// LoadAndSave is the cookie store equivalent of scs.SessionManager.LoadAndSave.
+func (c *CookieStore) LoadAndSave(sm *scs.SessionManager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Cookie")
+
+			var token string
+			cookie, err := r.Cookie(sm.Cookie.Name)
+			if err == nil {
+				token = cookie.Value
+			}
+
+			holder := &cookieValue{}
+			ctx := context.WithValue(r.Context(), cookieContextKey{}, holder)
+
+			ctx, err = sm.Load(ctx, token)
+			if err != nil {
+				sm.ErrorFunc(w, r, err)
+				return
+			}
+
+			sr := r.WithContext(ctx)
+			cw := &cookieResponseWriter{
+				ResponseWriter: w,
+				request:        sr,
+				session:        sm,
+				holder:         holder,
+			}
+
+			next.ServeHTTP(cw, sr)
+
+			if !cw.written {
+				cw.commit()
+			}
+		})
+	}
+}
+
+// Find decodes the session data from the cookie value, which scs passes as the token.
+func (c *CookieStore) Find(token string) ([]byte, bool, error) {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return nil, false, nil
+	}
+
+	cipherText, mac := token[:i], token[i+1:]
+	sum, err := base64.RawURLEncoding.DecodeString(mac)
+	if err != nil || !hmac.Equal(sum, c.sign(cipherText)) {
+		// tampered with, or signed with another key: start a new session
+		return nil, false, nil
+	}
+
+	plainText, err := c.Encrypter.Decrypt(cipherText)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(plainText) < 8 {
+		return nil, false, nil
+	}
+
+	expiry := time.Unix(int64(binary.BigEndian.Uint64([]byte(plainText[:8]))), 0)
+	if time.Now().After(expiry) {
+		return nil, false, nil
+	}
+
+	return []byte(plainText[8:]), true, nil
+}
+
+func (c *CookieStore) Commit(token string, b []byte, expiry time.Time) error {
+	return errors.New("session: the cookie store must be used with its LoadAndSave middleware")
+}
+
+func (c *CookieStore) Delete(token string) error {
+	return nil
+}
+
+func (c *CookieStore) FindCtx(ctx context.Context, token string) ([]byte, bool, error) {
+	return c.Find(token)
+}
+
+// CommitCtx encodes the session data and hands it to LoadAndSave to write as the cookie.
+func (c *CookieStore) CommitCtx(ctx context.Context, token string, b []byte, expiry time.Time) error {
+	holder, ok := ctx.Value(cookieContextKey{}).(*cookieValue)
+	if !ok {
+		return c.Commit(token, b, expiry)
+	}
+
+	plainText := make([]byte, 8, 8+len(b))
+	binary.BigEndian.PutUint64(plainText, uint64(expiry.Unix()))
+	plainText = append(plainText, b...)
+
+	cipherText, err := c.Encrypter.Encrypt(string(plainText))
+	if err != nil {
+		return err
+	}
+
+	value := cipherText + "." + base64.RawURLEncoding.EncodeToString(c.sign(cipherText))
+	if len(value) > maxCookieSize {
+		return ErrCookieTooLarge
+	}
+
+	holder.value = value
+
+	return nil
+}
+
+func (c *CookieStore) DeleteCtx(ctx context.Context, token string) error {
+	return nil
+}
+
+// sign computes the HMAC of value with a key derived from Key, so that the signing and
+// encryption keys differ.
+func (c *CookieStore) sign(value string) []byte {
+	key := sha256.Sum256(append([]byte("napoleon cookie session:"), c.Key...))
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+// cookieResponseWriter writes the session cookie before the response headers go out.
+type cookieResponseWriter struct {
+	http.ResponseWriter
+	request *http.Request
+	session *scs.SessionManager
+	holder  *cookieValue
+	written bool
+}
+
+func (cw *cookieResponseWriter) Write(b []byte) (int, error) {
+	if !cw.written {
+		cw.commit()
+		cw.written = true
+	}
+
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *cookieResponseWriter) WriteHeader(code int) {
+	if !cw.written {
+		cw.commit()
+		cw.written = true
+	}
+
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *cookieResponseWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+func (cw *cookieResponseWriter) commit() {
+	ctx := cw.request.Context()
+
+	switch cw.session.Status(ctx) {
+	case scs.Modified:
+		_, expiry, err := cw.session.Commit(ctx)
+		if err != nil {
+			cw.session.ErrorFunc(cw.ResponseWriter, cw.request, err)
+			return
+		}
+
+		cw.session.WriteSessionCookie(ctx, cw.ResponseWriter, cw.holder.value, expiry)
+	case scs.Destroyed:
+		cw.session.WriteSessionCookie(ctx, cw.ResponseWriter, "", time.Time{})
+	}
+}
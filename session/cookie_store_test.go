@@ -0,0 +1,109 @@
+package session
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testEncrypter only encodes; the store's own signature is what protects the data here.
+type testEncrypter struct{}
+
+func (testEncrypter) Encrypt(text string) (string, error) {
+	return base64.URLEncoding.EncodeToString([]byte(text)), nil
+}
+
+func (testEncrypter) Decrypt(cryptoText string) (string, error) {
+	b, err := base64.URLEncoding.DecodeString(cryptoText)
+	return string(b), err
+}
+
+func newCookieSession() (*CookieStore, http.Handler) {
+	c := &Session{
+		CookieName:    "napoleon",
+		SessionType:   "cookie",
+		Encrypter:     testEncrypter{},
+		EncryptionKey: "abcdefghijklmnopqrstuvwxyz123456",
+	}
+
+	sm, _ := c.InitSession()
+	store := sm.Store.(*CookieStore)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/put", func(w http.ResponseWriter, r *http.Request) {
+		sm.Put(r.Context(), "name", "napoleon")
+	})
+	mux.HandleFunc("/get", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, sm.GetString(r.Context(), "name"))
+	})
+	mux.HandleFunc("/destroy", func(w http.ResponseWriter, r *http.Request) {
+		_ = sm.Destroy(r.Context())
+	})
+
+	return store, store.LoadAndSave(sm)(mux)
+}
+
+func TestCookieStore_LoadAndSave(t *testing.T) {
+	_, handler := newCookieSession()
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/put", nil))
+
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatal("expected a session cookie, got", len(cookies))
+	}
+
+	if strings.Contains(cookies[0].Value, "napoleon") {
+		t.Error("session data is readable in the cookie")
+	}
+
+	req := httptest.NewRequest("GET", "/get", nil)
+	req.AddCookie(cookies[0])
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Body.String() != "napoleon" {
+		t.Errorf("session value not read back from the cookie, got %q", rr.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/destroy", nil)
+	req.AddCookie(cookies[0])
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	destroyed := rr.Result().Cookies()
+	if len(destroyed) != 1 || destroyed[0].MaxAge >= 0 {
+		t.Error("destroying the session did not expire the cookie")
+	}
+}
+
+func TestCookieStore_Tampered(t *testing.T) {
+	store, handler := newCookieSession()
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/put", nil))
+	cookie := rr.Result().Cookies()[0]
+
+	// swap in data that is validly encrypted, but not signed by the store
+	plainText, _ := testEncrypter{}.Decrypt(cookie.Value[:strings.LastIndexByte(cookie.Value, '.')])
+	forged, _ := testEncrypter{}.Encrypt(strings.Replace(plainText, "napoleon", "imposter", 1))
+	cookie.Value = forged + cookie.Value[strings.LastIndexByte(cookie.Value, '.'):]
+
+	req := httptest.NewRequest("GET", "/get", nil)
+	req.AddCookie(cookie)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Body.String() != "" {
+		t.Errorf("tampered cookie was accepted, got %q", rr.Body.String())
+	}
+
+	_, found, _ := store.Find("not-a-session")
+	if found {
+		t.Error("garbage cookie value was accepted")
+	}
+}
@@ -0,0 +1,312 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// MemoryIndex keeps the session index in memory. It suits a single server with memory,
+// badger or cookie sessions. Revoked cookie sessions are remembered until they expire,
+// so that Track can refuse them.
+type MemoryIndex struct {
+	mu      sync.Mutex
+	devices map[string]Device
+	revoked map[string]time.Time
+}
+
+func (i *MemoryIndex) Save(ctx context.Context, d Device) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.devices == nil {
+		i.devices = make(map[string]Device)
+	}
+
+	if old, ok := i.devices[d.Token]; ok && d.CreatedAt.IsZero() {
+		d.CreatedAt = old.CreatedAt
+	}
+	if d.CreatedAt.IsZero() {
+		d.CreatedAt = d.LastSeen
+	}
+
+	i.devices[d.Token] = d
+	return nil
+}
+
+func (i *MemoryIndex) Remove(ctx context.Context, token string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	delete(i.devices, token)
+	return nil
+}
+
+func (i *MemoryIndex) ForUser(ctx context.Context, userID int) ([]Device, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	var devices []Device
+	now := time.Now()
+
+	for token, d := range i.devices {
+		if now.After(d.Expiry) {
+			delete(i.devices, token)
+			continue
+		}
+		if d.UserID == userID {
+			devices = append(devices, d)
+		}
+	}
+
+	return devices, nil
+}
+
+func (i *MemoryIndex) Revoke(ctx context.Context, token string, expiry time.Time) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.revoked == nil {
+		i.revoked = make(map[string]time.Time)
+	}
+
+	i.revoked[token] = expiry
+	return nil
+}
+
+func (i *MemoryIndex) Revoked(ctx context.Context, token string) (bool, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := time.Now()
+	for t, expiry := range i.revoked {
+		if now.After(expiry) {
+			delete(i.revoked, t)
+		}
+	}
+
+	_, ok := i.revoked[token]
+	return ok, nil
+}
+
+// RedisIndex keeps a set of session tokens per user, and a hash with the device details
+// of each session that expires together with the session. The two live in different
+// cluster slots, so only the commands on one key are run as a transaction.
+type RedisIndex struct {
+	Conn   *redis.Pool
+	Prefix string
+}
+
+func (i *RedisIndex) userKey(userID int) string {
+	return fmt.Sprintf("%sscs:user:%d", i.Prefix, userID)
+}
+
+func (i *RedisIndex) deviceKey(token string) string {
+	return fmt.Sprintf("%sscs:device:%s", i.Prefix, token)
+}
+
+func (i *RedisIndex) Save(ctx context.Context, d Device) error {
+	conn, err := i.Conn.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if d.CreatedAt.IsZero() {
+		d.CreatedAt = d.LastSeen
+	}
+
+	key := i.deviceKey(d.Token)
+
+	conn.Send("MULTI")
+	conn.Send("HSETNX", key, "created_at", d.CreatedAt.Unix())
+	conn.Send("HSET", key,
+		"user_id", d.UserID,
+		"ip", d.IP,
+		"user_agent", d.UserAgent,
+		"last_seen", d.LastSeen.Unix(),
+		"expiry", d.Expiry.Unix(),
+	)
+	conn.Send("EXPIREAT", key, d.Expiry.Unix())
+	if _, err := conn.Do("EXEC"); err != nil {
+		return err
+	}
+
+	_, err = conn.Do("SADD", i.userKey(d.UserID), d.Token)
+
+	return err
+}
+
+func (i *RedisIndex) Remove(ctx context.Context, token string) error {
+	conn, err := i.Conn.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	key := i.deviceKey(token)
+
+	userID, err := redis.Int(conn.Do("HGET", key, "user_id"))
+	if err == redis.ErrNil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// a token left in the set without its details is dropped by ForUser
+	if _, err := conn.Do("DEL", key); err != nil {
+		return err
+	}
+
+	_, err = conn.Do("SREM", i.userKey(userID), token)
+
+	return err
+}
+
+func (i *RedisIndex) ForUser(ctx context.Context, userID int) ([]Device, error) {
+	conn, err := i.Conn.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	tokens, err := redis.Strings(conn.Do("SMEMBERS", i.userKey(userID)))
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []Device
+	for _, token := range tokens {
+		fields, err := redis.StringMap(conn.Do("HGETALL", i.deviceKey(token)))
+		if err != nil {
+			return nil, err
+		}
+
+		// the details expired with the session, so drop it from the set as well
+		if len(fields) == 0 {
+			if _, err := conn.Do("SREM", i.userKey(userID), token); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		devices = append(devices, Device{
+			ID:        sessionID(token),
+			Token:     token,
+			UserID:    userID,
+			IP:        fields["ip"],
+			UserAgent: fields["user_agent"],
+			CreatedAt: unixField(fields["created_at"]),
+			LastSeen:  unixField(fields["last_seen"]),
+			Expiry:    unixField(fields["expiry"]),
+		})
+	}
+
+	return devices, nil
+}
+
+func unixField(value string) time.Time {
+	seconds, _ := strconv.ParseInt(value, 10, 64)
+	return time.Unix(seconds, 0)
+}
+
+// SQLIndex keeps the session index in the user_sessions table created by
+// "napoleon make session".
+type SQLIndex struct {
+	DB     *sql.DB
+	DBType string
+}
+
+func (i *SQLIndex) Save(ctx context.Context, d Device) error {
+	if d.CreatedAt.IsZero() {
+		d.CreatedAt = d.LastSeen
+	}
+
+	var query string
+
+	switch i.DBType {
+	case "postgres", "postgresql":
+		query = `insert into user_sessions (token, user_id, ip, user_agent, created_at, last_seen, expiry)
+			values ($1, $2, $3, $4, $5, $6, $7)
+			on conflict (token) do update set user_id = excluded.user_id, ip = excluded.ip,
+			user_agent = excluded.user_agent, last_seen = excluded.last_seen, expiry = excluded.expiry`
+	default:
+		query = `insert into user_sessions (token, user_id, ip, user_agent, created_at, last_seen, expiry)
+			values (?, ?, ?, ?, ?, ?, ?)
+			on duplicate key update user_id = values(user_id), ip = values(ip),
+			user_agent = values(user_agent), last_seen = values(last_seen), expiry = values(expiry)`
+	}
+
+	// the columns are varchar(45) and varchar(512); user agents can be longer
+	_, err := i.DB.ExecContext(ctx, query, d.Token, d.UserID, truncate(d.IP, 45), truncate(d.UserAgent, 512),
+		d.CreatedAt.UTC(), d.LastSeen.UTC(), d.Expiry.UTC())
+
+	return err
+}
+
+// truncate cuts s to at most n characters.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+
+	return string([]rune(s)[:n])
+}
+
+func (i *SQLIndex) Remove(ctx context.Context, token string) error {
+	query := "delete from user_sessions where token = ?"
+	if i.DBType == "postgres" || i.DBType == "postgresql" {
+		query = "delete from user_sessions where token = $1"
+	}
+
+	_, err := i.DB.ExecContext(ctx, query, token)
+	return err
+}
+
+// ForUser returns the sessions of a user, deleting the ones that have expired, so the
+// table does not grow forever.
+func (i *SQLIndex) ForUser(ctx context.Context, userID int) ([]Device, error) {
+	prune := "delete from user_sessions where user_id = ? and expiry <= ?"
+	if i.DBType == "postgres" || i.DBType == "postgresql" {
+		prune = "delete from user_sessions where user_id = $1 and expiry <= $2"
+	}
+
+	if _, err := i.DB.ExecContext(ctx, prune, userID, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+
+	query := `select token, ip, user_agent, created_at, last_seen, expiry from user_sessions
+		where user_id = ? and expiry > ?`
+	if i.DBType == "postgres" || i.DBType == "postgresql" {
+		query = `select token, ip, user_agent, created_at, last_seen, expiry from user_sessions
+			where user_id = $1 and expiry > $2`
+	}
+
+	rows, err := i.DB.QueryContext(ctx, query, userID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var devices []Device
+	for rows.Next() {
+		d := Device{UserID: userID}
+
+		err := rows.Scan(&d.Token, &d.IP, &d.UserAgent, &d.CreatedAt, &d.LastSeen, &d.Expiry)
+		if err != nil {
+			return nil, err
+		}
+
+		d.ID = sessionID(d.Token)
+		devices = append(devices, d)
+	}
+
+	return devices, rows.Err()
+}
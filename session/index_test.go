@@ -0,0 +1,26 @@
+package session
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTruncate(t *testing.T) {
+	var tests = []struct {
+		name string
+		s    string
+		n    int
+		want string
+	}{
+		{"short", "Mozilla/5.0", 512, "Mozilla/5.0"},
+		{"exact", "127.0.0.1", 9, "127.0.0.1"},
+		{"long", strings.Repeat("a", 600), 512, strings.Repeat("a", 512)},
+		{"multibyte", "héllo", 2, "hé"},
+	}
+
+	for _, e := range tests {
+		if got := truncate(e.s, e.n); got != e.want {
+			t.Errorf("%s: expected %q, got %q", e.name, e.want, got)
+		}
+	}
+}
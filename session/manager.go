@@ -0,0 +1,335 @@
+package session
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/hilsonxhero/napoleon/internal/support"
+)
+
+var (
+	// ErrUnknownSession is returned when revoking a session that the user does not have.
+	ErrUnknownSession = errors.New("session: unknown session")
+	// ErrCannotRevoke is returned when revoking cookie sessions with an index that cannot
+	// remember revoked sessions.
+	ErrCannotRevoke = errors.New("session: sessions of this store cannot be revoked")
+)
+
+const (
+	lastSeenKey = "__lastSeen"
+	// indexTokenKey holds the token a session is indexed by. It is the session token,
+	// except with cookie sessions, whose token is the cookie and changes with the data.
+	indexTokenKey = "__indexToken"
+)
+
+// Device is one active session of a user. ID identifies the session without exposing its
+// token, which would let anyone who sees it take over the session.
+type Device struct {
+	ID        string    `json:"id"`
+	Token     string    `json:"-"`
+	UserID    int       `json:"user_id"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+	LastSeen  time.Time `json:"last_seen"`
+	Expiry    time.Time `json:"-"`
+	Current   bool      `json:"current"`
+}
+
+// Index keeps track of which sessions belong to which user.
+type Index interface {
+	Save(ctx context.Context, d Device) error
+	Remove(ctx context.Context, token string) error
+	ForUser(ctx context.Context, userID int) ([]Device, error)
+}
+
+// RevocationList is implemented by indexes that remember revoked sessions until they
+// expire. Cookie sessions live on the client, where they cannot be deleted, so they can
+// only be revoked by refusing them afterwards.
+type RevocationList interface {
+	Revoke(ctx context.Context, token string, expiry time.Time) error
+	Revoked(ctx context.Context, token string) (bool, error)
+}
+
+// Manager lists and revokes the sessions of a user. Sessions are indexed when a user logs
+// in with Login, and their device details are refreshed by the Track middleware.
+type Manager struct {
+	Session *scs.SessionManager
+	Index   Index
+	UserKey string
+	// TouchInterval limits how often Track writes the last seen time of a session
+	TouchInterval time.Duration
+}
+
+// Login renews the session token, stores userID in the session and indexes it.
+func (m *Manager) Login(r *http.Request, userID int) error {
+	ctx := r.Context()
+
+	if err := m.RenewID(ctx); err != nil {
+		return err
+	}
+
+	token := m.Session.Token(ctx)
+	m.Session.Put(ctx, m.userKey(), userID)
+	m.Session.Put(ctx, lastSeenKey, time.Now().Unix())
+	m.Session.Put(ctx, indexTokenKey, token)
+
+	now := time.Now()
+	return m.Index.Save(ctx, Device{
+		ID:        sessionID(token),
+		Token:     token,
+		UserID:    userID,
+		IP:        support.ClientIP(r),
+		UserAgent: r.UserAgent(),
+		CreatedAt: now,
+		LastSeen:  now,
+		Expiry:    m.expiry(),
+	})
+}
+
+// LoggedIn reports whether a user is logged in to the session of ctx.
+func (m *Manager) LoggedIn(ctx context.Context) bool {
+	return m.Session.Exists(ctx, m.userKey())
+}
+
+// UserID returns the id of the user logged in to the session of ctx, or 0.
+func (m *Manager) UserID(ctx context.Context) int {
+	return m.Session.GetInt(ctx, m.userKey())
+}
+
+// Logout removes the session from the index and destroys it.
+func (m *Manager) Logout(ctx context.Context) error {
+	if token := m.indexToken(ctx); token != "" {
+		if err := m.Index.Remove(ctx, token); err != nil {
+			return err
+		}
+	}
+
+	return m.Session.Destroy(ctx)
+}
+
+// RenewID gives the current session a new token while keeping its data, which prevents
+// session fixation. Call it whenever the privileges of a session change.
+func (m *Manager) RenewID(ctx context.Context) error {
+	old := m.indexToken(ctx)
+
+	if err := m.Session.RenewToken(ctx); err != nil {
+		return err
+	}
+
+	if old == "" || !m.Session.Exists(ctx, m.userKey()) {
+		return nil
+	}
+
+	devices, err := m.Index.ForUser(ctx, m.Session.GetInt(ctx, m.userKey()))
+	if err != nil {
+		return err
+	}
+
+	for _, d := range devices {
+		if d.Token == old {
+			if err := m.Index.Remove(ctx, old); err != nil {
+				return err
+			}
+
+			d.Token = m.Session.Token(ctx)
+			d.ID = sessionID(d.Token)
+			m.Session.Put(ctx, indexTokenKey, d.Token)
+			return m.Index.Save(ctx, d)
+		}
+	}
+
+	return nil
+}
+
+// ListUserSessions returns the active sessions of a user, most recently seen first. The
+// session of the request in ctx, if any, is marked as current.
+func (m *Manager) ListUserSessions(ctx context.Context, userID int) ([]Device, error) {
+	devices, err := m.Index.ForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	current := m.indexToken(ctx)
+	for i := range devices {
+		devices[i].Current = devices[i].Token == current
+	}
+
+	sort.Slice(devices, func(a, b int) bool {
+		return devices[a].LastSeen.After(devices[b].LastSeen)
+	})
+
+	return devices, nil
+}
+
+// RevokeSession ends one session of a user, given its Device.ID.
+func (m *Manager) RevokeSession(ctx context.Context, userID int, id string) error {
+	devices, err := m.Index.ForUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range devices {
+		if d.ID == id {
+			return m.revoke(ctx, d)
+		}
+	}
+
+	return ErrUnknownSession
+}
+
+// RevokeAllForUser ends every session of a user, for example after a password reset.
+func (m *Manager) RevokeAllForUser(ctx context.Context, userID int) error {
+	return m.revokeAll(ctx, userID, "")
+}
+
+// RevokeOtherSessions logs a user out everywhere except in the session of ctx.
+func (m *Manager) RevokeOtherSessions(ctx context.Context, userID int) error {
+	return m.revokeAll(ctx, userID, m.indexToken(ctx))
+}
+
+// Track is middleware that keeps the device details and last seen time of logged in
+// sessions up to date, and ends revoked cookie sessions. It must run after the session
+// is loaded.
+func (m *Manager) Track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		token := m.indexToken(ctx)
+		if token != "" && m.Session.Exists(ctx, m.userKey()) && m.revoked(ctx, token) {
+			_ = m.Session.Destroy(ctx)
+			token = ""
+		}
+
+		if token != "" && m.Session.Exists(ctx, m.userKey()) {
+			lastSeen := time.Unix(m.Session.GetInt64(ctx, lastSeenKey), 0)
+
+			if time.Since(lastSeen) >= m.touchInterval() {
+				now := time.Now()
+				m.Session.Put(ctx, lastSeenKey, now.Unix())
+
+				_ = m.Index.Save(ctx, Device{
+					ID:        sessionID(token),
+					Token:     token,
+					UserID:    m.Session.GetInt(ctx, m.userKey()),
+					IP:        support.ClientIP(r),
+					UserAgent: r.UserAgent(),
+					LastSeen:  now,
+					Expiry:    m.expiry(),
+				})
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (m *Manager) revokeAll(ctx context.Context, userID int, keep string) error {
+	devices, err := m.Index.ForUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range devices {
+		if d.Token == keep {
+			continue
+		}
+		if err := m.revoke(ctx, d); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// revoke deletes the session of d from the store, or, for cookie sessions, puts it on
+// the revocation list of the index.
+func (m *Manager) revoke(ctx context.Context, d Device) error {
+	if _, ok := m.Session.Store.(*CookieStore); ok {
+		list, ok := m.Index.(RevocationList)
+		if !ok {
+			return ErrCannotRevoke
+		}
+
+		if err := list.Revoke(ctx, d.Token, d.Expiry); err != nil {
+			return err
+		}
+	} else if err := m.Session.Store.Delete(d.Token); err != nil {
+		return err
+	}
+
+	return m.Index.Remove(ctx, d.Token)
+}
+
+// revoked reports whether the cookie session indexed by token has been revoked.
+func (m *Manager) revoked(ctx context.Context, token string) bool {
+	if _, ok := m.Session.Store.(*CookieStore); !ok {
+		return false
+	}
+
+	list, ok := m.Index.(RevocationList)
+	if !ok {
+		return false
+	}
+
+	revoked, err := list.Revoked(ctx, token)
+
+	// refuse the session when it cannot be told whether it was revoked
+	return revoked || err != nil
+}
+
+// indexToken returns the token the session in ctx is indexed by, or "" if no session
+// is loaded.
+func (m *Manager) indexToken(ctx context.Context) string {
+	token := m.currentToken(ctx)
+	if token == "" {
+		return ""
+	}
+
+	if indexed := m.Session.GetString(ctx, indexTokenKey); indexed != "" {
+		return indexed
+	}
+
+	return token
+}
+
+// currentToken returns the token of the session in ctx, or "" if no session is loaded.
+func (m *Manager) currentToken(ctx context.Context) (token string) {
+	defer func() {
+		if recover() != nil {
+			token = ""
+		}
+	}()
+
+	return m.Session.Token(ctx)
+}
+
+func (m *Manager) expiry() time.Time {
+	return time.Now().Add(m.Session.Lifetime)
+}
+
+func (m *Manager) userKey() string {
+	if m.UserKey == "" {
+		return "userID"
+	}
+	return m.UserKey
+}
+
+func (m *Manager) touchInterval() time.Duration {
+	if m.TouchInterval == 0 {
+		return time.Minute
+	}
+	return m.TouchInterval
+}
+
+// sessionID derives the public id of a session from its token.
+func sessionID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
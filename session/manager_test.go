@@ -0,0 +1,223 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
+)
+
+func newTestManager(index Index) *Manager {
+	sm := scs.New()
+	sm.Store = memstore.New()
+
+	return &Manager{Session: sm, Index: index}
+}
+
+// login logs userID in from a new client and returns its session cookie.
+func login(t *testing.T, m *Manager, userID int, userAgent string) *http.Cookie {
+	handler := m.Session.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := m.Login(r, userID); err != nil {
+			t.Error(err)
+		}
+	}))
+
+	req := httptest.NewRequest("POST", "/login", nil)
+	req.Header.Set("User-Agent", userAgent)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	cookies := rr.Result().Cookies()
+	if len(cookies) == 0 {
+		t.Fatal("no session cookie after login")
+	}
+
+	return cookies[0]
+}
+
+func testManager(t *testing.T, m *Manager) {
+	phone := login(t, m, 1, "phone")
+	laptop := login(t, m, 1, "laptop")
+	other := login(t, m, 2, "other")
+
+	var devices []Device
+	handler := m.Session.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		devices, err = m.ListUserSessions(r.Context(), 1)
+		if err != nil {
+			t.Error(err)
+		}
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(laptop)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(devices) != 2 {
+		t.Fatalf("expected 2 sessions for user 1, got %d", len(devices))
+	}
+
+	for _, d := range devices {
+		if d.Current != (d.UserAgent == "laptop") {
+			t.Errorf("wrong current flag for %s session", d.UserAgent)
+		}
+		if d.IP != "192.0.2.1" {
+			t.Errorf("expected ip 192.0.2.1, got %q", d.IP)
+		}
+	}
+
+	// log out everywhere but the laptop
+	handler = m.Session.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := m.RevokeOtherSessions(r.Context(), 1); err != nil {
+			t.Error(err)
+		}
+	}))
+
+	req = httptest.NewRequest("POST", "/", nil)
+	req.AddCookie(laptop)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if _, found, _ := m.Session.Store.Find(phone.Value); found {
+		t.Error("phone session still in the store after revoking other sessions")
+	}
+
+	if _, found, _ := m.Session.Store.Find(laptop.Value); !found {
+		t.Error("current session revoked, and it shouldn't be")
+	}
+
+	devices, _ = m.Index.ForUser(req.Context(), 2)
+	if len(devices) != 1 {
+		t.Fatal("sessions of another user were revoked")
+	}
+
+	err := m.RevokeSession(req.Context(), 2, devices[0].ID)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if _, found, _ := m.Session.Store.Find(other.Value); found {
+		t.Error("revoked session still in the store")
+	}
+
+	err = m.RevokeSession(req.Context(), 2, devices[0].ID)
+	if err != ErrUnknownSession {
+		t.Error("expected ErrUnknownSession, got", err)
+	}
+
+	err = m.RevokeAllForUser(req.Context(), 1)
+	if err != nil {
+		t.Error(err)
+	}
+
+	devices, _ = m.Index.ForUser(req.Context(), 1)
+	if len(devices) != 0 {
+		t.Error("sessions left after revoking all for user")
+	}
+}
+
+func TestManager_Memory(t *testing.T) {
+	testManager(t, newTestManager(&MemoryIndex{}))
+}
+
+func TestManager_Redis(t *testing.T) {
+	testManager(t, newTestManager(&RedisIndex{Conn: testRedisPool, Prefix: "test:"}))
+}
+
+func TestManager_RenewID(t *testing.T) {
+	m := newTestManager(&MemoryIndex{})
+	cookie := login(t, m, 7, "browser")
+
+	var renewed string
+	handler := m.Session.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := m.RenewID(r.Context()); err != nil {
+			t.Error(err)
+		}
+		renewed = m.Session.Token(r.Context())
+	}))
+
+	req := httptest.NewRequest("POST", "/", nil)
+	req.AddCookie(cookie)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if renewed == cookie.Value {
+		t.Error("session token was not renewed")
+	}
+
+	devices, _ := m.Index.ForUser(req.Context(), 7)
+	if len(devices) != 1 || devices[0].Token != renewed || devices[0].UserAgent != "browser" {
+		t.Error("index was not moved to the renewed token")
+	}
+}
+
+func TestManager_RevokeCookieSession(t *testing.T) {
+	c := &Session{
+		CookieName:    "napoleon",
+		SessionType:   "cookie",
+		Encrypter:     testEncrypter{},
+		EncryptionKey: "abcdefghijklmnopqrstuvwxyz123456",
+	}
+	sm, err := c.InitSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := sm.Store.(*CookieStore)
+	m := &Manager{Session: sm, Index: &MemoryIndex{}}
+
+	loggedIn := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		if err := m.Login(r, 1); err != nil {
+			t.Error(err)
+		}
+	})
+	mux.HandleFunc("/check", func(w http.ResponseWriter, r *http.Request) {
+		loggedIn = m.LoggedIn(r.Context())
+		// changing the data changes the cookie, but not the session in the index
+		m.Session.Put(r.Context(), "visits", 1)
+	})
+	handler := store.LoadAndSave(sm)(m.Track(mux))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/login", nil))
+	cookie := rr.Result().Cookies()[0]
+
+	req := httptest.NewRequest("GET", "/check", nil)
+	req.AddCookie(cookie)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if !loggedIn {
+		t.Fatal("not logged in with the session cookie")
+	}
+	changed := rr.Result().Cookies()[0]
+
+	if devices, _ := m.Index.ForUser(req.Context(), 1); len(devices) != 1 {
+		t.Fatalf("expected 1 indexed session, got %d", len(devices))
+	}
+
+	if err := m.RevokeAllForUser(req.Context(), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// both the cookie from login and the one after the data changed are refused
+	for _, c := range []*http.Cookie{cookie, changed} {
+		req = httptest.NewRequest("GET", "/check", nil)
+		req.AddCookie(c)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if loggedIn {
+			t.Error("revoked cookie session still logged in")
+		}
+	}
+
+	// an index without a revocation list cannot revoke cookie sessions
+	m.Index = &RedisIndex{Conn: testRedisPool, Prefix: "cookie:"}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/login", nil))
+
+	if err := m.RevokeAllForUser(req.Context(), 1); err != ErrCannotRevoke {
+		t.Error("expected ErrCannotRevoke, got", err)
+	}
+}
@@ -2,6 +2,7 @@ package session
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
@@ -11,22 +12,42 @@ import (
 	"github.com/alexedwards/scs/postgresstore"
 	"github.com/alexedwards/scs/redisstore"
 	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
+	"github.com/dgraph-io/badger/v3"
 	"github.com/gomodule/redigo/redis"
 )
 
+// ErrNoEncryptionKey is returned by InitSession for cookie sessions without an encryption
+// key, which is what keeps the data on the client safe.
+var ErrNoEncryptionKey = errors.New("session: cookie sessions need an encryption key, set KEY or another SESSION_TYPE")
+
+// ErrInvalidEncryptionKey is returned by InitSession for cookie sessions with a key that
+// AES cannot use; KEY must be 16, 24 or 32 bytes long.
+var ErrInvalidEncryptionKey = errors.New("session: the encryption key must be 16, 24 or 32 bytes long")
+
 type Session struct {
-	CookieLifetime string
-	CookiePersist  string
-	CookieName     string
-	CookieDomain   string
-	SessionType    string
-	CookieSecure   string
-	DBPool         *sql.DB
-	RedisPool      *redis.Pool
+	CookieLifetime    string
+	CookiePersist     string
+	CookieName        string
+	CookieDomain      string
+	CookiePath        string
+	CookieSameSite    string
+	CookieHTTPOnly    string
+	CookiePartitioned string
+	SessionType       string
+	CookieSecure      string
+	DBPool            *sql.DB
+	RedisPool         *redis.Pool
+	RedisPrefix       string
+	BadgerConn        *badger.DB
+	Encrypter         Encrypter
+	EncryptionKey     string
 }
 
-func (c *Session) InitSession() *scs.SessionManager {
-	var persist, secure bool
+// InitSession creates the session manager with the store of SessionType; cookie sessions
+// are the default.
+func (c *Session) InitSession() (*scs.SessionManager, error) {
+	var persist, secure, partitioned bool
 
 	// how long should sessions last?
 	minutes, err := strconv.Atoi(c.CookieLifetime)
@@ -44,6 +65,22 @@ func (c *Session) InitSession() *scs.SessionManager {
 		secure = true
 	}
 
+	// keep cookies out of reach of javascript unless told otherwise
+	httpOnly, err := strconv.ParseBool(c.CookieHTTPOnly)
+	if err != nil {
+		httpOnly = true
+	}
+
+	// should cookies be partitioned (CHIPS) when embedded cross site?
+	if strings.ToLower(c.CookiePartitioned) == "true" {
+		partitioned = true
+	}
+
+	path := c.CookiePath
+	if path == "" {
+		path = "/"
+	}
+
 	// create session
 	session := scs.New()
 	session.Lifetime = time.Duration(minutes) * time.Minute
@@ -51,7 +88,10 @@ func (c *Session) InitSession() *scs.SessionManager {
 	session.Cookie.Name = c.CookieName
 	session.Cookie.Secure = secure
 	session.Cookie.Domain = c.CookieDomain
-	session.Cookie.SameSite = http.SameSiteLaxMode
+	session.Cookie.Path = path
+	session.Cookie.HttpOnly = httpOnly
+	session.Cookie.Partitioned = partitioned
+	session.Cookie.SameSite = sameSite(c.CookieSameSite)
 
 	// which session store?
 	switch strings.ToLower(c.SessionType) {
@@ -61,9 +101,55 @@ func (c *Session) InitSession() *scs.SessionManager {
 		session.Store = mysqlstore.New(c.DBPool)
 	case "postgres", "postgresql":
 		session.Store = postgresstore.New(c.DBPool)
+	case "badger":
+		session.Store = NewBadgerStore(c.BadgerConn)
+	case "memory":
+		session.Store = memstore.New()
 	default:
-		// cookie
+		// cookie; without an encryption key there is no way to keep the data on the
+		// client, and falling back to memory would lose every session on restart
+		if c.Encrypter == nil || c.EncryptionKey == "" {
+			return nil, ErrNoEncryptionKey
+		}
+
+		switch len(c.EncryptionKey) {
+		case 16, 24, 32:
+		default:
+			return nil, ErrInvalidEncryptionKey
+		}
+
+		session.Store = &CookieStore{
+			Encrypter: c.Encrypter,
+			Key:       []byte(c.EncryptionKey),
+		}
 	}
 
-	return session
+	return session, nil
+}
+
+// NewIndex returns the session index that matches the session store: redis and the
+// databases share the index between servers, everything else keeps it in memory.
+func (c *Session) NewIndex() Index {
+	switch strings.ToLower(c.SessionType) {
+	case "redis":
+		return &RedisIndex{Conn: c.RedisPool, Prefix: c.RedisPrefix}
+	case "mysql", "mariadb":
+		return &SQLIndex{DB: c.DBPool, DBType: "mysql"}
+	case "postgres", "postgresql":
+		return &SQLIndex{DB: c.DBPool, DBType: "postgres"}
+	default:
+		return &MemoryIndex{}
+	}
+}
+
+// sameSite converts lax, strict or none to the cookie attribute, defaulting to lax.
+func sameSite(mode string) http.SameSite {
+	switch strings.ToLower(mode) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
 }
@@ -2,8 +2,10 @@ package session
 
 import (
 	"fmt"
+	"net/http"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/alexedwards/scs/v2"
 )
@@ -16,11 +18,16 @@ func TestSession_InitSession(t *testing.T) {
 		CookieName:     "napoleon",
 		CookieDomain:   "localhost",
 		SessionType:    "cookie",
+		Encrypter:      testEncrypter{},
+		EncryptionKey:  "abcdefghijklmnopqrstuvwxyz123456",
 	}
 
 	var sm *scs.SessionManager
 
-	ses := c.InitSession()
+	ses, err := c.InitSession()
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	var sessKind reflect.Kind
 	var sessType reflect.Type
@@ -47,3 +54,78 @@ func TestSession_InitSession(t *testing.T) {
 		t.Error("wrong type returned testing cookie session. Expected", reflect.ValueOf(sm).Type(), "and got", sessType)
 	}
 }
+
+func TestSession_CookieAttributes(t *testing.T) {
+	c := &Session{
+		CookieLifetime:    "30",
+		CookieName:        "napoleon",
+		CookieDomain:      "localhost",
+		CookiePath:        "/app",
+		CookieSameSite:    "strict",
+		CookieHTTPOnly:    "false",
+		CookiePartitioned: "true",
+		CookieSecure:      "true",
+		SessionType:       "memory",
+	}
+
+	ses, err := c.InitSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ses.Cookie.Path != "/app" {
+		t.Error("wrong cookie path:", ses.Cookie.Path)
+	}
+
+	if ses.Cookie.SameSite != http.SameSiteStrictMode {
+		t.Error("wrong SameSite mode:", ses.Cookie.SameSite)
+	}
+
+	if ses.Cookie.HttpOnly {
+		t.Error("cookie is HttpOnly, but it should not be")
+	}
+
+	if !ses.Cookie.Partitioned {
+		t.Error("cookie is not partitioned, but it should be")
+	}
+
+	if !ses.Cookie.Secure {
+		t.Error("cookie is not secure, but it should be")
+	}
+
+	if ses.Lifetime != 30*time.Minute {
+		t.Error("wrong session lifetime:", ses.Lifetime)
+	}
+
+	defaults, _ := (&Session{SessionType: "memory"}).InitSession()
+
+	if defaults.Cookie.Path != "/" || defaults.Cookie.SameSite != http.SameSiteLaxMode || !defaults.Cookie.HttpOnly {
+		t.Error("wrong default cookie attributes:", defaults.Cookie)
+	}
+}
+
+func TestSession_CookieWithoutKey(t *testing.T) {
+	// cookie sessions are the default, and must not quietly end up in memory
+	if _, err := (&Session{}).InitSession(); err != ErrNoEncryptionKey {
+		t.Error("expected ErrNoEncryptionKey, got", err)
+	}
+
+	for _, key := range []string{"short", "abcdefghijklmnopqrstuvwxyz1234567"} {
+		_, err := (&Session{Encrypter: testEncrypter{}, EncryptionKey: key}).InitSession()
+		if err != ErrInvalidEncryptionKey {
+			t.Errorf("key of %d bytes: expected ErrInvalidEncryptionKey, got %v", len(key), err)
+		}
+	}
+}
+
+func TestSession_NewIndex(t *testing.T) {
+	index, ok := (&Session{SessionType: "redis", RedisPool: testRedisPool, RedisPrefix: "app:"}).NewIndex().(*RedisIndex)
+	if !ok {
+		t.Fatal("redis sessions do not get a redis index")
+	}
+
+	// apps sharing a redis server keep their indexes apart
+	if index.userKey(1) != "app:scs:user:1" {
+		t.Error("redis prefix not used by the index:", index.userKey(1))
+	}
+}
@@ -1,11 +1,49 @@
 package session
 
 import (
+	"log"
 	"os"
 	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/gomodule/redigo/redis"
 )
 
+var testBadgerConn *badger.DB
+
+var testRedisPool *redis.Pool
+
 func TestMain(m *testing.M) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+
+	testRedisPool = &redis.Pool{
+		MaxIdle:     50,
+		MaxActive:   1000,
+		IdleTimeout: 240 * time.Second,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", s.Addr())
+		},
+	}
+
+	_ = os.RemoveAll("./testdata/tmp/badger")
+
+	err = os.MkdirAll("./testdata/tmp/badger", 0755)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	testBadgerConn, _ = badger.Open(badger.DefaultOptions("./testdata/tmp/badger"))
+
+	code := m.Run()
+
+	// os.Exit skips deferred calls, so clean up first
+	s.Close()
+	_ = testBadgerConn.Close()
 
-	os.Exit(m.Run())
-}
\ No newline at end of file
+	os.Exit(code)
+}
@@ -0,0 +1,41 @@
+package napoleon
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/CloudyKit/jet/v6"
+	"github.com/alexedwards/scs/v2"
+	"github.com/hilsonxhero/napoleon/render"
+)
+
+var testApp = &Napoleon{
+	InfoLog:  log.New(io.Discard, "", 0),
+	ErrorLog: log.New(io.Discard, "", 0),
+	Render: &render.Render{
+		Renderer: "jet",
+		RootPath: "./testdata",
+		JetViews: *jet.NewSet(
+			jet.NewOSFileSystemLoader("./testdata/views"),
+			jet.InDevelopmentMode(),
+		),
+		Session: *scs.New(),
+	},
+}
+
+func TestMain(m *testing.M) {
+	os.Exit(m.Run())
+}
+
+// loadSession gives r the session pages read their flash messages from.
+func loadSession(t *testing.T, r *http.Request) *http.Request {
+	ctx, err := testApp.Render.Session.Load(r.Context(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r.WithContext(ctx)
+}
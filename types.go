@@ -1,6 +1,9 @@
 package napoleon
 
-import "database/sql"
+import (
+	"database/sql"
+	"time"
+)
 
 type initPaths struct {
 	rootPath    string
@@ -8,11 +11,15 @@ type initPaths struct {
 }
 
 type cookieConfig struct {
-	name     string
-	lifetime string
-	parsist  string
-	srcure   string
-	domain   string
+	name        string
+	lifetime    string
+	parsist     string
+	srcure      string
+	domain      string
+	path        string
+	sameSite    string
+	httpOnly    string
+	partitioned string
 }
 
 type DatabaseConfig struct {
@@ -26,7 +33,21 @@ type Database struct {
 }
 
 type RedisConfig struct {
-	host     string
-	password string
-	prefix   string
+	host             string
+	username         string
+	password         string
+	prefix           string
+	database         int
+	maxIdle          int
+	maxActive        int
+	idleTimeout      time.Duration
+	useTLS           bool
+	tlsCA            string
+	tlsCert          string
+	tlsKey           string
+	tlsSkipVerify    bool
+	sentinelAddrs    []string
+	sentinelMaster   string
+	sentinelPassword string
+	clusterAddrs     []string
 }
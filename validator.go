@@ -3,6 +3,7 @@ package napoleon
 import (
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -26,6 +27,18 @@ func (v *Validation) Valid() bool {
 	return len(v.Errors) == 0
 }
 
+// Error makes a failed validation an error, so that handlers can return it and have
+// it answered as a 422 problem with the field errors.
+func (v *Validation) Error() string {
+	fields := make([]string, 0, len(v.Errors))
+	for field := range v.Errors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	return "validation failed: " + strings.Join(fields, ", ")
+}
+
 func (v *Validation) AddError(key, message string) {
 	if _, exists := v.Errors[key]; !exists {
 		v.Errors[key] = message